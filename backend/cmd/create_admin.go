@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"student-management-system/config"
+	"student-management-system/internal/app"
 	"student-management-system/internal/models"
 	"student-management-system/internal/utils"
 )
@@ -13,7 +14,7 @@ func main() {
 	fmt.Println("=== 学生管理系统 - 创建管理员账号 ===")
 
 	// 初始化数据库
-	config.InitDB()
+	app.InitDB()
 	db := config.GetDB()
 
 	// 创建管理员账号
@@ -47,6 +48,8 @@ func main() {
 		RoleID:   adminRole.ID,
 		IsActive: true,
 		UserType: "admin",
+		// 默认密码众所周知，首次登录后必须修改才能使用其他功能
+		MustChangePassword: true,
 	}
 
 	if err := db.Create(&user).Error; err != nil {
@@ -56,5 +59,5 @@ func main() {
 	fmt.Println("\n✓ 管理员账号创建成功!")
 	fmt.Printf("  用户名: %s\n", username)
 	fmt.Printf("  密码: %s\n", password)
-	fmt.Println("\n请妥善保管账号信息，并在首次登录后修改密码。")
+	fmt.Println("\n首次登录后须先修改密码（新密码须符合密码策略），才能使用其他功能。")
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// Scenario 定义一组并发选课/退课参数
+type Scenario struct {
+	Name        string
+	Concurrency int
+	TotalOps    int
+	Capacity    int
+}
+
+// ScenarioResult 记录一次场景执行后的统计与一致性校验结果
+type ScenarioResult struct {
+	ScenarioName  string
+	Enrolled      int64
+	Rejected      int64
+	SoftDropped   int64
+	HardDropped   int64
+	Errors        int64
+	EnrolledCount int
+	ActualCount   int64
+	Capacity      int
+}
+
+// Consistent 计数是否与实际选课记录一致且未超过容量
+func (r ScenarioResult) Consistent() bool {
+	return int64(r.EnrolledCount) == r.ActualCount && r.ActualCount <= int64(r.Capacity)
+}
+
+func main() {
+	log.Println("启动选课计数并发校验...（确保已导入学生数据）")
+
+	app.InitDB()
+	db := config.GetDB()
+
+	var studentIDs []uint
+	if err := db.Model(&models.Student{}).Limit(200).Pluck("id", &studentIDs).Error; err != nil {
+		log.Fatalf("获取学生ID失败: %v", err)
+	}
+	if len(studentIDs) == 0 {
+		log.Fatal("未找到学生记录，无法进行选课压测，请先导入数据")
+	}
+	log.Printf("已加载 %d 个学生ID", len(studentIDs))
+
+	scenarios := []Scenario{
+		{Name: "c4_ops400_cap20", Concurrency: 4, TotalOps: 400, Capacity: 20},
+		{Name: "c16_ops1000_cap30", Concurrency: 16, TotalOps: 1000, Capacity: 30},
+		{Name: "c32_ops2000_cap10", Concurrency: 32, TotalOps: 2000, Capacity: 10}, // 高争用小容量
+	}
+
+	failed := false
+	for _, sc := range scenarios {
+		res, err := runScenario(db, sc, studentIDs)
+		if err != nil {
+			log.Fatalf("场景 %s 执行失败: %v", sc.Name, err)
+		}
+		status := "一致"
+		if !res.Consistent() {
+			status = "不一致"
+			failed = true
+		}
+		log.Printf("[%s] 场景=%s 选课=%d 拒绝=%d 软删除=%d 硬删除=%d 错误=%d enrolled_count=%d 实际=%d 容量=%d",
+			status, res.ScenarioName, res.Enrolled, res.Rejected, res.SoftDropped, res.HardDropped, res.Errors,
+			res.EnrolledCount, res.ActualCount, res.Capacity)
+	}
+
+	if failed {
+		log.Println("校验失败：enrolled_count 与实际选课记录不一致")
+		os.Exit(1)
+	}
+	log.Println("校验通过：所有场景的 enrolled_count 与实际选课记录一致")
+}
+
+func runScenario(db *gorm.DB, sc Scenario, studentIDs []uint) (ScenarioResult, error) {
+	course := models.Course{
+		CourseName: fmt.Sprintf("stress_enroll_%s_%d", sc.Name, time.Now().UnixNano()),
+		Capacity:   sc.Capacity,
+	}
+	if err := db.Create(&course).Error; err != nil {
+		return ScenarioResult{}, err
+	}
+	defer cleanup(db, course.ID)
+
+	tasks := make(chan struct{}, sc.TotalOps)
+	for i := 0; i < sc.TotalOps; i++ {
+		tasks <- struct{}{}
+	}
+	close(tasks)
+
+	var wg sync.WaitGroup
+	var enrolled, rejected, softDropped, hardDropped, errs atomic.Int64
+
+	for worker := 0; worker < sc.Concurrency; worker++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(workerID*997)))
+			for range tasks {
+				studentID := studentIDs[r.Intn(len(studentIDs))]
+				switch n := r.Intn(100); {
+				case n < 60:
+					switch err := service.Enroll(db, studentID, course.ID); {
+					case err == nil:
+						enrolled.Add(1)
+					case errors.Is(err, service.ErrCourseFull), errors.Is(err, service.ErrAlreadyEnrolled):
+						rejected.Add(1)
+					default:
+						errs.Add(1)
+					}
+				case n < 85:
+					// 软删除：GORM Delete 只会设置 deleted_at
+					res := db.Where("student_id = ? AND course_id = ?", studentID, course.ID).Delete(&models.Enrollment{})
+					if res.Error != nil {
+						errs.Add(1)
+					} else {
+						softDropped.Add(res.RowsAffected)
+					}
+				default:
+					// 硬删除：与通用表管理 DeleteTableData 的写法一致
+					res := db.Exec("DELETE FROM enrollments WHERE student_id = ? AND course_id = ?", studentID, course.ID)
+					if res.Error != nil {
+						errs.Add(1)
+					} else {
+						hardDropped.Add(res.RowsAffected)
+					}
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	var reloaded models.Course
+	if err := db.First(&reloaded, course.ID).Error; err != nil {
+		return ScenarioResult{}, err
+	}
+	var actual int64
+	if err := db.Model(&models.Enrollment{}).Where("course_id = ?", course.ID).Count(&actual).Error; err != nil {
+		return ScenarioResult{}, err
+	}
+
+	return ScenarioResult{
+		ScenarioName:  sc.Name,
+		Enrolled:      enrolled.Load(),
+		Rejected:      rejected.Load(),
+		SoftDropped:   softDropped.Load(),
+		HardDropped:   hardDropped.Load(),
+		Errors:        errs.Load(),
+		EnrolledCount: reloaded.EnrolledCount,
+		ActualCount:   actual,
+		Capacity:      reloaded.Capacity,
+	}, nil
+}
+
+func cleanup(db *gorm.DB, courseID uint) {
+	db.Exec("DELETE FROM enrollments WHERE course_id = ?", courseID)
+	db.Unscoped().Delete(&models.Course{}, courseID)
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"student-management-system/config"
+	"student-management-system/internal/app"
 	"student-management-system/internal/models"
 
 	"gorm.io/gorm"
@@ -48,7 +49,7 @@ func main() {
 	log.Println("启动成绩表压测...（确保已导入初始数据）")
 
 	// 初始化数据库连接
-	config.InitDB()
+	app.InitDB()
 	db := config.GetDB()
 
 	// 准备可用的 Enrollment ID
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"student-management-system/internal/utils"
+)
+
+// 管理 JWT 签名密钥目录（JWT_KEYS_DIR）。
+// 轮换步骤：
+//  1. 生成新密钥：go run ./cmd/jwt_keys -dir ./keys [-alg EdDSA|RS256] [-kid 2026-10]
+//     未指定 JWT_ACTIVE_KID 时 kid 最大的私钥用于签名，重启后新令牌即用新密钥签发，旧密钥仍可验证。
+//  2. 等待超过访问令牌有效期后，将旧密钥降为仅验证：go run ./cmd/jwt_keys -dir ./keys -retire <旧kid>
+//  3. 确认其他服务已刷新 JWKS 后删除 <旧kid>.pub.pem。
+func main() {
+	dir := flag.String("dir", "./keys", "密钥目录")
+	alg := flag.String("alg", utils.AlgEdDSA, "签名算法：EdDSA 或 RS256")
+	kid := flag.String("kid", time.Now().Format("2006-01-02"), "新密钥的 kid（建议用日期，按字典序最大的私钥默认用于签名）")
+	retire := flag.String("retire", "", "将该 kid 的私钥替换为公钥（仅用于验证，不再签发）")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatalf("创建密钥目录失败: %v", err)
+	}
+
+	if *retire != "" {
+		ks, err := utils.LoadKeySet(*dir, *retire)
+		if err != nil {
+			log.Fatalf("加载密钥失败: %v", err)
+		}
+		pub, err := ks.Active.PublicKeyPEM()
+		if err != nil {
+			log.Fatalf("导出公钥失败: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(*dir, *retire+".pub.pem"), pub, 0o644); err != nil {
+			log.Fatalf("写入公钥失败: %v", err)
+		}
+		if err := os.Remove(filepath.Join(*dir, *retire+".pem")); err != nil {
+			log.Fatalf("删除私钥失败: %v", err)
+		}
+		fmt.Printf("密钥 %s 已降为仅验证（%s.pub.pem）\n", *retire, *retire)
+		return
+	}
+
+	path := filepath.Join(*dir, *kid+".pem")
+	if _, err := os.Stat(path); err == nil {
+		log.Fatalf("密钥 %s 已存在", path)
+	}
+	key, err := utils.GenerateSigningKey(*kid, *alg)
+	if err != nil {
+		log.Fatalf("生成密钥失败: %v", err)
+	}
+	data, err := key.PrivateKeyPEM()
+	if err != nil {
+		log.Fatalf("导出私钥失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Fatalf("写入私钥失败: %v", err)
+	}
+	fmt.Printf("已生成 %s 密钥 %s\n", key.Alg, path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+)
+
+// 测试目录的结构与账号
+const (
+	userDN          = "ou=people,dc=example,dc=edu"
+	groupDN         = "ou=groups,dc=example,dc=edu"
+	serviceDN       = "cn=sms-reader,dc=example,dc=edu"
+	servicePassword = "reader-secret"
+	userPassword    = "ldap123"
+)
+
+// directoryUsers 用户名 → 所属组（cn）
+var directoryUsers = map[string][]string{
+	"zhanglaoshi": {"teachers"},
+	"lizhuren":    {"teachers", "head-teachers"},
+	"wangadmin":   {"teachers", "it-admins"},
+	"zhaoke":      nil,
+}
+
+// 本地测试用的 LDAP 目录（内嵌 gldap 测试目录，不启用 TLS），供本地后端登录联调。
+// 只用于开发和联调，切勿部署到生产环境。目录认证的自动化测试见 internal/service/ldap_auth_test.go。
+// 用法：go run ./cmd/ldap_stub [-addr localhost:10389]
+//
+// 目录中的用户 zhanglaoshi、lizhuren、wangadmin、zhaoke 的密码都是 ldap123。
+// 已有的本地账号须由管理员关联目录条目（provider 为 ldap，subject 为 uid=<用户名>,ou=people,dc=example,dc=edu）后才能通过目录登录。
+func main() {
+	addr := flag.String("addr", "localhost:10389", "监听地址")
+	flag.Parse()
+
+	host, portStr, err := net.SplitHostPort(*addr)
+	if err != nil {
+		log.Fatalf("无效的监听地址 %q: %v", *addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		log.Fatalf("无效的端口 %q", portStr)
+	}
+	d := startDirectory(host, port)
+	defer d.Stop()
+
+	fmt.Printf("测试 LDAP 目录已启动：ldap://%s（用户密码均为 %s）\n", *addr, userPassword)
+	fmt.Println("后端配置示例：")
+	fmt.Printf("  LDAP_ENABLED=true LDAP_URL=ldap://%s LDAP_BIND_DN=%s LDAP_BIND_PASSWORD=%s\n", *addr, serviceDN, servicePassword)
+	fmt.Printf("  LDAP_USER_BASE_DN=%s LDAP_GROUP_ROLES=\"it-admins:admin;teachers:teacher\" LDAP_AUTO_PROVISION=true\n", userDN)
+	fmt.Println("  AUTH_ORDER=local,ldap AUTH_ORDER_TEACHER=ldap,local")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+}
+
+// startDirectory 启动内嵌的测试目录
+func startDirectory(host string, port int) *testdirectory.Directory {
+	logger := hclog.New(&hclog.LoggerOptions{Name: "ldap-stub", Level: hclog.Warn})
+	t, err := testdirectory.NewLogger(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var users []*gldap.Entry
+	members := make(map[string][]string)
+	for name, groups := range directoryUsers {
+		dn := fmt.Sprintf("uid=%s,%s", name, userDN)
+		attrs := map[string][]string{
+			"uid":      {name},
+			"cn":       {name},
+			"mail":     {name + "@example.edu.cn"},
+			"password": {userPassword},
+		}
+		for _, g := range groups {
+			attrs["memberOf"] = append(attrs["memberOf"], fmt.Sprintf("cn=%s,%s", g, groupDN))
+			members[g] = append(members[g], dn)
+		}
+		users = append(users, gldap.NewEntry(dn, attrs))
+	}
+	users = append(users, gldap.NewEntry(serviceDN, map[string][]string{"password": {servicePassword}}))
+
+	var groups []*gldap.Entry
+	for g, dns := range members {
+		groups = append(groups, gldap.NewEntry(fmt.Sprintf("cn=%s,%s", g, groupDN), map[string][]string{"cn": {g}, "member": dns}))
+	}
+
+	return testdirectory.Start(t,
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithHost(t, host),
+		testdirectory.WithPort(t, port),
+		testdirectory.WithLogger(t, logger),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{
+			UserAttr:  "uid",
+			GroupAttr: "cn",
+			UserDN:    userDN,
+			GroupDN:   groupDN,
+			Users:     users,
+			Groups:    groups,
+		}),
+	)
+}
@@ -1,28 +1,70 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"student-management-system/config"
 	"student-management-system/internal/api"
-	"student-management-system/internal/utils"
+	"student-management-system/internal/app"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
 )
 
 func main() {
-	// 设置JWT密钥
-	utils.SetJWTSecret("your_jwt_secret_key_change_in_production")
+	// 加载配置（默认值 → 配置文件 → 环境变量 → 命令行参数），校验失败时拒绝启动
+	cfg, err := app.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	// 初始化数据库（含默认数据与内置角色的默认权限）
+	app.InitDB()
+
+	// 设置路由；路由引用的权限即权限目录，同步到权限表（新增缺少的权限，标记废弃的权限）
+	router, routes := api.BuildRouter()
+	app.SyncPermissions(permission.Models(routes.Catalog()))
+
+	// 接收其他实例的角色权限缓存失效通知（配置了 permission_cache.bus 时）
+	if err := app.Permissions.Listen(); err != nil {
+		log.Fatalf("订阅权限缓存失效通知失败: %v", err)
+	}
 
-	// 初始化数据库
-	config.InitDB()
+	// 收到 SIGINT/SIGTERM 时停止后台任务并关闭服务器
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// 设置路由
-	router := api.SetupRouter()
+	// 每天定时运行学业预警规则
+	var alertsDone <-chan struct{}
+	if cfg.Features.AlertScheduler {
+		alertsDone = service.StartAlertScheduler(ctx, config.GetDB(), cfg.Features.AlertHour, service.DefaultGradeScale)
+	}
 
 	// 启动服务器
-	port := ":8080"
-	fmt.Printf("服务器启动成功，监听端口 %s\n", port)
-	if err := router.Run(port); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+	addr := cfg.Server.Addr()
+	server := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务器启动失败: %v", err)
+		}
+	}()
+	fmt.Printf("服务器启动成功（%s），监听地址 %s\n", cfg.Env, addr)
+
+	<-ctx.Done()
+	log.Println("正在关闭服务器...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("关闭服务器失败: %v", err)
+	}
+	if alertsDone != nil {
+		<-alertsDone
 	}
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"student-management-system/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authCode 已签发、尚未兑换的授权码
+type authCode struct {
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	username    string
+	expires     time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *utils.SigningKey
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>测试身份提供方</title></head>
+<body style="font-family: sans-serif; max-width: 360px; margin: 80px auto">
+<h3>测试身份提供方（任意用户名即可登录）</h3>
+<form method="post">
+{{range $k, $v := .}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+<p><input name="username" placeholder="用户名" autofocus required style="width: 100%; padding: 6px"></p>
+<p><button type="submit">登录</button></p>
+</form></body></html>`))
+
+// 本地测试用的 OpenID Connect 身份提供方：不校验密码，输入任意用户名即登录成功。
+// 只用于开发和联调统一身份认证，切勿部署到生产环境。
+// 用法：go run ./cmd/oidc_stub [-addr :9000] [-client-id sms] [-client-secret sms-secret]
+// 后端配置：OIDC_ENABLED=true OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=sms
+// OIDC_CLIENT_SECRET=sms-secret OIDC_REDIRECT_URL=http://localhost:5173/login
+// 签发的 ID Token 中 sub 为 "stub|<用户名>"，preferred_username 为用户名，email 为 <用户名>@example.edu.cn。
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer（须与后端 OIDC_ISSUER 一致）")
+	clientID := flag.String("client-id", "sms", "允许的 client_id")
+	clientSecret := flag.String("client-secret", "sms-secret", "client_secret")
+	flag.Parse()
+
+	key, err := utils.GenerateSigningKey("stub-"+time.Now().Format("20060102150405"), utils.AlgRS256)
+	if err != nil {
+		log.Fatalf("生成密钥失败: %v", err)
+	}
+	ks, err := utils.NewKeySet(key)
+	if err != nil {
+		log.Fatalf("生成密钥失败: %v", err)
+	}
+	p := &provider{issuer: *issuer, clientID: *clientID, clientSecret: *clientSecret, key: key, codes: make(map[string]authCode)}
+
+	http.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.issuer,
+			"authorization_endpoint":                p.issuer + "/authorize",
+			"token_endpoint":                        p.issuer + "/token",
+			"jwks_uri":                              p.issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{key.Alg},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	})
+	http.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": ks.JWKS()})
+	})
+	http.HandleFunc("/authorize", p.authorize)
+	http.HandleFunc("/token", p.token)
+
+	fmt.Printf("测试身份提供方已启动：%s（client_id=%s）\n", p.issuer, p.clientID)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// authorize 显示登录表单；提交用户名后带授权码重定向回 redirect_uri
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+	if q.Get("client_id") != p.clientID || q.Get("response_type") != "code" || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = loginPage.Execute(w, r.URL.Query())
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = authCode{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		username:    q.Get("username"),
+		expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	back, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	v := back.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	back.RawQuery = v.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+// token 用授权码换取 ID Token（校验 client_secret、redirect_uri 与 PKCE）
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	p.mu.Lock()
+	ac, ok := p.codes[r.Form.Get("code")]
+	delete(p.codes, r.Form.Get("code"))
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	switch {
+	case !ok || time.Now().After(ac.expires):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "授权码无效或已过期"})
+		return
+	case r.Form.Get("client_id") != p.clientID || r.Form.Get("client_secret") != p.clientSecret:
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	case r.Form.Get("redirect_uri") != ac.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "redirect_uri 不一致"})
+		return
+	case ac.challenge != "" && base64.RawURLEncoding.EncodeToString(verifier[:]) != ac.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier 不匹配"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := p.key.SignWith(jwt.MapClaims{
+		"iss":                p.issuer,
+		"aud":                ac.clientID,
+		"sub":                "stub|" + ac.username,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              ac.nonce,
+		"preferred_username": ac.username,
+		"email":              ac.username + "@example.edu.cn",
+		"name":               ac.username,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
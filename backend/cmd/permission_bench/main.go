@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+)
+
+// Scenario 一组并发查询参数
+type Scenario struct {
+	Name        string
+	Concurrency int
+	TotalOps    int
+}
+
+// Result 一种查询方式在一个场景下的统计
+type Result struct {
+	Mode         string
+	Scenario     string
+	Errors       int
+	Duration     time.Duration
+	Throughput   float64
+	AvgLatencyUs float64
+	P95LatencyUs float64
+	MaxLatencyUs float64
+}
+
+// 对比 AuthMiddleware 获取角色权限的两种方式：每次查询数据库 与 进程内缓存。
+// 用法：go run ./cmd/permission_bench [-role admin] [-ttl 1m]
+func main() {
+	roleName := flag.String("role", "admin", "查询权限的角色名")
+	ttl := flag.Duration("ttl", time.Minute, "缓存有效期")
+	flag.Parse()
+
+	log.Println("启动角色权限查询基准测试...")
+	app.InitDB()
+	db := config.GetDB()
+
+	var role models.Role
+	if err := db.Where("role_name = ?", *roleName).First(&role).Error; err != nil {
+		log.Fatalf("角色 %s 不存在: %v", *roleName, err)
+	}
+	set, err := service.LoadPermissionSet(db, role.ID)
+	if err != nil {
+		log.Fatalf("查询权限失败: %v", err)
+	}
+	log.Printf("角色 %s（ID=%d）继承链 %d 层，授权与拒绝规则 %d 条", role.RoleName, role.ID, len(set.Roles()), len(set.Grants()))
+
+	scenarios := []Scenario{
+		{Name: "c1_2000", Concurrency: 1, TotalOps: 2000},     // 单请求基线
+		{Name: "c8_8000", Concurrency: 8, TotalOps: 8000},     // 中等并发
+		{Name: "c32_16000", Concurrency: 32, TotalOps: 16000}, // 高并发（超过连接池时数据库查询开始排队）
+	}
+
+	cache := service.NewPermissionCache(*ttl)
+	modes := []struct {
+		name string
+		load func() error
+	}{
+		{"db", func() error { _, err := service.LoadPermissionSet(db, role.ID); return err }},
+		{"cache", func() error { _, err := cache.Permissions(db, role.ID); return err }},
+	}
+
+	for _, sc := range scenarios {
+		var results []Result
+		for _, m := range modes {
+			res := run(sc, m.name, m.load)
+			results = append(results, res)
+			log.Printf("[完成] 场景=%s 方式=%-5s 错误=%d 耗时=%.2fs 吞吐=%.0f ops/s 平均=%.1fµs P95=%.1fµs 最大=%.1fµs",
+				res.Scenario, res.Mode, res.Errors, res.Duration.Seconds(), res.Throughput, res.AvgLatencyUs, res.P95LatencyUs, res.MaxLatencyUs)
+		}
+		if results[1].AvgLatencyUs > 0 {
+			log.Printf("[对比] 场景=%s 缓存平均延迟为数据库查询的 1/%.0f", sc.Name, results[0].AvgLatencyUs/results[1].AvgLatencyUs)
+		}
+	}
+
+	// 失效后第一次查询回到数据库，之后再次命中缓存
+	cache.Invalidate(role.ID)
+	start := time.Now()
+	_, _ = cache.Permissions(db, role.ID)
+	miss := time.Since(start)
+	start = time.Now()
+	_, _ = cache.Permissions(db, role.ID)
+	hit := time.Since(start)
+	log.Printf("[失效] 失效后首次查询 %.1fµs，再次查询 %.1fµs", float64(miss.Nanoseconds())/1000, float64(hit.Nanoseconds())/1000)
+	log.Println("基准测试结束")
+}
+
+func run(sc Scenario, mode string, load func() error) Result {
+	tasks := make(chan struct{}, sc.TotalOps)
+	for i := 0; i < sc.TotalOps; i++ {
+		tasks <- struct{}{}
+	}
+	close(tasks)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, sc.TotalOps)
+	errors := 0
+	start := time.Now()
+	for w := 0; w < sc.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make([]time.Duration, 0, sc.TotalOps/sc.Concurrency+1)
+			localErrors := 0
+			for range tasks {
+				opStart := time.Now()
+				if err := load(); err != nil {
+					localErrors++
+				}
+				local = append(local, time.Since(opStart))
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			errors += localErrors
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	duration := time.Since(start)
+
+	avg, p95, max := latencyStats(latencies)
+	return Result{
+		Mode:         mode,
+		Scenario:     sc.Name,
+		Errors:       errors,
+		Duration:     duration,
+		Throughput:   float64(len(latencies)) / duration.Seconds(),
+		AvgLatencyUs: avg,
+		P95LatencyUs: p95,
+		MaxLatencyUs: max,
+	}
+}
+
+// latencyStats 平均、P95 与最大延迟（微秒）
+func latencyStats(latencies []time.Duration) (avg, p95, max float64) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	idx := int(float64(len(sorted)) * 0.95)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	us := func(d time.Duration) float64 { return float64(d.Nanoseconds()) / 1000 }
+	return us(total) / float64(len(sorted)), us(sorted[idx]), us(sorted[len(sorted)-1])
+}
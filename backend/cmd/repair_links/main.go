@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/service"
+)
+
+// 修复账号（users.user_id / user_type）与学生、教师、家长档案（*.user_id）之间不一致的关联。
+// 用法：go run ./cmd/repair_links [-dry-run]
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只报告问题，不修改数据")
+	flag.Parse()
+
+	// 初始化数据库连接
+	app.InitDB()
+	db := config.GetDB()
+
+	issues, err := service.RepairAccountLinks(db, *dryRun)
+	if err != nil {
+		log.Fatalf("修复账号关联失败: %v", err)
+	}
+
+	if len(issues) == 0 {
+		fmt.Println("账号与档案关联一致，无需修复")
+		return
+	}
+	for _, is := range issues {
+		fmt.Printf("[%s] 账号=%d 档案=%d  %s -> %s\n", is.UserType, is.UserID, is.ProfileID, is.Problem, is.Action)
+	}
+	if *dryRun {
+		fmt.Printf("\n共发现 %d 处不一致（试运行，未修改数据）\n", len(issues))
+		return
+	}
+	fmt.Printf("\n共修复 %d 处不一致\n", len(issues))
+}
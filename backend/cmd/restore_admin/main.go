@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+)
+
+// 应急恢复管理员权限：在所有管理员账号被删除、禁用或失去权限，无法再通过管理接口恢复时使用。
+// 直接修改数据库：admin 角色拥有全部未废弃的权限、删除其拒绝规则和上级角色；指定账号启用并使用 admin 角色，解除其登录锁定。
+// 用法：
+//
+//	go run ./cmd/restore_admin -user admin                   恢复已有账号（已删除的一并恢复）
+//	go run ./cmd/restore_admin -user ops -password '<新密码>'  重置密码（首次登录须修改）；账号不存在时新建
+//
+// 所在角色要求两步验证而账号尚未启用时，同时签发设置码（首次登录凭设置码设置两步验证）。
+// 数据库等配置与主程序相同（.env、环境变量、APP_CONFIG 指定的配置文件）。
+// 配置了数据库失效广播（permission_cache.bus）时通知运行中的实例立即刷新权限缓存，否则在缓存过期（permission_cache.ttl）后生效。
+func main() {
+	username := flag.String("user", "admin", "恢复为管理员的账号")
+	password := flag.String("password", "", "重置的密码（须符合密码策略，首次登录须修改）；账号不存在时必填")
+	flag.Parse()
+
+	// 初始化数据库连接（同时按配置设置权限缓存失效广播）
+	app.InitDB()
+
+	result, err := service.RestoreAdministrator(config.GetDB(), *username, *password, app.PasswordPolicy)
+	if err != nil {
+		log.Fatalf("恢复管理员权限失败: %v", err)
+	}
+	// 所有角色的权限缓存失效，发布到其他实例
+	app.Permissions.InvalidateAll()
+
+	fmt.Printf("admin 角色（ID %d）：补上 %d 个权限", result.RoleID, result.Granted)
+	if len(result.RemovedDeny) > 0 {
+		fmt.Printf("，删除拒绝规则 %s", strings.Join(result.RemovedDeny, ", "))
+	}
+	if result.DetachedRole {
+		fmt.Print("，解除上级角色")
+	}
+	fmt.Println()
+
+	switch {
+	case result.CreatedUser:
+		fmt.Printf("已新建管理员账号 %s（ID %d）\n", *username, result.UserID)
+	case result.RestoredUser:
+		fmt.Printf("已恢复已删除的账号 %s（ID %d）并设为管理员\n", *username, result.UserID)
+	default:
+		fmt.Printf("已启用账号 %s（ID %d）并设为管理员，已签发的令牌失效\n", *username, result.UserID)
+	}
+	if result.PasswordReset || result.CreatedUser {
+		fmt.Println("首次登录后须先修改密码")
+	}
+	fmt.Printf("当前可用的管理员账号：%d 个\n", result.Administrators)
+
+	// 没有其他可用的管理员为其签发两步验证设置码，在此签发
+	var user models.User
+	if err := config.GetDB().First(&user, result.UserID).Error; err != nil {
+		log.Fatalf("获取账号失败: %v", err)
+	}
+	if user.TOTPEnabled {
+		return
+	}
+	required, err := service.TwoFactorRequired(config.GetDB(), user.RoleID, config.TwoFactorRequiredPermissions)
+	if err != nil {
+		log.Fatalf("检查两步验证要求失败: %v", err)
+	}
+	if required {
+		code, expires, err := service.IssueEnrollmentCode(config.GetDB(), user.ID)
+		if err != nil {
+			log.Fatalf("签发两步验证设置码失败: %v", err)
+		}
+		fmt.Printf("两步验证设置码：%s（%s 前有效，登录后凭此设置两步验证）\n", code, expires.Format("2006-01-02 15:04"))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"student-management-system/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 列出全部路由及其访问控制与权限目录，并检查访问控制表，可在 CI 中运行（不连接数据库）。
+// 用法：
+//
+//	go run ./cmd/routes            以表格列出路由与权限目录，发现问题时退出码为 1
+//	go run ./cmd/routes -json      以 JSON 输出，便于与上一版本比较
+//
+// 检查项：所有路由都经访问控制表注册（没有遗漏声明访问控制的路由）、权限标识合法且定义一致、
+// 内置角色的默认权限清单只引用权限目录中的权限。
+// 路由表受功能开关影响（如 FEATURE_SQL_CONSOLE），CI 中应使用与生产一致的配置。
+func main() {
+	asJSON := flag.Bool("json", false, "以 JSON 输出")
+	flag.Parse()
+
+	gin.SetMode(gin.ReleaseMode)
+	engine, table := api.BuildRouter()
+	routes := table.Routes()
+	catalog := table.Catalog()
+	problems := table.Verify(engine)
+
+	if *asJSON {
+		type entry struct {
+			Permission string `json:"permission"`
+			Name       string `json:"name"`
+			Group      string `json:"group"`
+		}
+		out := struct {
+			Routes   []api.RouteGuard `json:"routes"`
+			Catalog  []entry          `json:"catalog"`
+			Problems []string         `json:"problems"`
+		}{Routes: routes, Catalog: make([]entry, 0, len(catalog)), Problems: problems}
+		for _, p := range catalog {
+			out.Catalog = append(out.Catalog, entry{Permission: p.Code, Name: p.Name, Group: p.Group})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "METHOD\tPATH\tGUARD\tPERMISSION\tCHECKS")
+		for _, r := range routes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Method, r.Path, r.Guard, dash(r.Permission), dash(strings.Join(r.Checks, ",")))
+		}
+		w.Flush()
+
+		fmt.Printf("\n权限目录（%d 个）：\n", len(catalog))
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, p := range catalog {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Code, p.Name, p.Group)
+		}
+		w.Flush()
+
+		fmt.Printf("\n共 %d 条路由\n", len(routes))
+		for _, p := range problems {
+			fmt.Println("[问题]", p)
+		}
+	}
+
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
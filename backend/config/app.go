@@ -0,0 +1,551 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// 运行环境
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+	EnvTest        = "test"
+)
+
+// AppConfig 应用配置
+// 加载顺序（后者覆盖前者）：默认值 → 配置文件（JSON）→ 环境变量（含 .env）→ 命令行参数
+type AppConfig struct {
+	Env      string         `json:"env"` // development / production / test
+	Server   ServerConfig   `json:"server"`
+	Database DatabaseConfig `json:"database"`
+	JWT      JWTConfig      `json:"jwt"`
+	Security SecurityConfig `json:"security"`
+	CORS     CORSConfig     `json:"cors"`
+	Features FeatureConfig  `json:"features"`
+
+	PermissionCache PermissionCacheConfig `json:"permission_cache"`
+
+	OIDC OIDCConfig `json:"oidc"` // 统一身份认证（OpenID Connect）
+	LDAP LDAPConfig `json:"ldap"` // LDAP 目录认证
+	Auth AuthOrder  `json:"auth"` // 密码登录依次尝试的认证方式（local、ldap）
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// Addr 监听地址
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// DatabaseConfig 数据库连接与连接池配置
+type DatabaseConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+
+	MaxOpenConns    int      `json:"max_open_conns"`     // 最大连接数，0 表示不限制
+	MaxIdleConns    int      `json:"max_idle_conns"`     // 最大空闲连接数
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`  // 连接最长存活时间，0 表示不限制
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"` // 空闲连接最长保留时间，0 表示不限制
+}
+
+// DSN MySQL 连接串
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		d.User, d.Password, d.Host, d.Port, d.Name)
+}
+
+// JWTConfig 令牌配置
+type JWTConfig struct {
+	KeysDir     string   `json:"keys_dir"`      // 签名密钥目录（<kid>.pem 私钥、<kid>.pub.pem 仅验证的公钥），为空时开发环境使用临时密钥
+	ActiveKeyID string   `json:"active_key_id"` // 签发令牌使用的 kid，为空时使用 kid 最大的私钥
+	AccessTTL   Duration `json:"access_ttl"`    // 访问令牌有效期
+	RefreshTTL  Duration `json:"refresh_ttl"`   // 刷新令牌有效期，每次刷新重新计算
+}
+
+// SecurityConfig 数据加密配置
+type SecurityConfig struct {
+	EncryptionKey string `json:"encryption_key"` // 加密存储两步验证密钥等敏感数据的密钥，为空时开发环境使用固定的开发密钥
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowOrigins []string `json:"allow_origins"`
+}
+
+// FeatureConfig 功能开关
+type FeatureConfig struct {
+	SQLConsole     bool `json:"sql_console"`     // 数据库管理中的 SQL 执行接口（POST /database/execute）
+	AlertScheduler bool `json:"alert_scheduler"` // 每天定时运行学业预警规则
+	AlertHour      int  `json:"alert_hour"`      // 学业预警运行时间（0-23 点）
+}
+
+// PermissionCacheConfig 角色权限缓存配置
+type PermissionCacheConfig struct {
+	TTL          Duration `json:"ttl"`           // 缓存有效期，0 表示不缓存（每个请求都查询数据库）
+	Bus          string   `json:"bus"`           // 多实例之间广播失效的方式：空（仅本实例）或 db（通过 permission_invalidations 表轮询）
+	PollInterval Duration `json:"poll_interval"` // bus 为 db 时的轮询间隔
+}
+
+// Duration 配置文件中以 "15m"、"168h" 形式书写的时长
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON 解析 "15m" 形式的字符串（也接受以纳秒为单位的数字）
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("无效的时长 %s", b)
+		}
+		d.Duration = time.Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("无效的时长 %q", s)
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON 输出 "15m0s" 形式的字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// IsProduction 是否为生产环境
+func (c *AppConfig) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// DefaultAppConfig 默认配置（适用于本地开发）
+func DefaultAppConfig() *AppConfig {
+	return &AppConfig{
+		Env:    EnvDevelopment,
+		Server: ServerConfig{Host: "0.0.0.0", Port: 8080},
+		Database: DatabaseConfig{
+			Host:            "127.0.0.1",
+			Port:            3306,
+			User:            "root",
+			Name:            "student_db",
+			MaxOpenConns:    50,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration{time.Hour},
+			ConnMaxIdleTime: Duration{10 * time.Minute},
+		},
+		JWT: JWTConfig{
+			AccessTTL:  Duration{15 * time.Minute},
+			RefreshTTL: Duration{7 * 24 * time.Hour},
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"http://localhost:5173", "http://localhost:8081", "http://localhost:3000"},
+		},
+		Features: FeatureConfig{
+			SQLConsole:     true,
+			AlertScheduler: true,
+			AlertHour:      2,
+		},
+		PermissionCache: PermissionCacheConfig{
+			TTL:          Duration{time.Minute},
+			PollInterval: Duration{2 * time.Second},
+		},
+		OIDC: OIDCConfig{
+			Provider:        "campus",
+			DisplayName:     "统一身份认证",
+			Scopes:          []string{"openid", "profile", "email"},
+			UsernameClaim:   "preferred_username",
+			DefaultRole:     "student",
+			DefaultUserType: "student",
+		},
+		LDAP: LDAPConfig{
+			TimeoutSeconds:  5,
+			UserFilter:      "(uid=%s)",
+			UsernameAttr:    "uid",
+			EmailAttr:       "mail",
+			GroupAttr:       "memberOf",
+			GroupFilter:     "(member=%s)",
+			DefaultUserType: "teacher",
+		},
+		Auth: AuthOrder{
+			Default: []string{"local"}, // 本地密码
+		},
+	}
+}
+
+// ConfigError 配置校验失败，Problems 列出全部问题
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "配置无效：" + strings.Join(e.Problems, "；")
+}
+
+// App 当前生效的配置，由 Load 设置
+var App *AppConfig
+
+// Current 当前配置；尚未加载时按默认值、.env 和环境变量加载（不解析命令行参数），加载失败时退出
+func Current() *AppConfig {
+	if App == nil {
+		if _, err := Load(nil); err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+	}
+	return App
+}
+
+// Load 加载并校验配置，成功后设置 App 及依赖配置的全局变量
+// args 为命令行参数（不含程序名），支持：
+//
+//	-config 配置文件路径（也可用环境变量 APP_CONFIG 指定）
+//	-env    运行环境
+//	-host、-port 监听地址
+func Load(args []string) (*AppConfig, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := fs.String("config", "", "配置文件路径（JSON）")
+	env := fs.String("env", "", "运行环境：development / production / test")
+	host := fs.String("host", "", "监听地址")
+	port := fs.Int("port", 0, "监听端口")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("警告: .env 文件未找到，将使用默认配置")
+	}
+
+	cfg := DefaultAppConfig()
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("APP_CONFIG")
+	}
+	if path != "" {
+		if err := loadConfigFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []string
+	applyEnv(cfg, &problems)
+
+	if *env != "" {
+		cfg.Env = *env
+	}
+	if *host != "" {
+		cfg.Server.Host = *host
+	}
+	if *port != 0 {
+		cfg.Server.Port = *port
+	}
+
+	problems = append(problems, cfg.Validate()...)
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+
+	if err := loadSigningKeys(cfg); err != nil {
+		return nil, err
+	}
+	if err := loadEncryptionKey(cfg); err != nil {
+		return nil, err
+	}
+
+	App = cfg
+	AccessTokenTTL = cfg.JWT.AccessTTL.Duration
+	RefreshTokenTTL = cfg.JWT.RefreshTTL.Duration
+	loadTwoFactorSettings()
+	return cfg, nil
+}
+
+// loadConfigFile 读取 JSON 配置文件，文件中未出现的字段保留默认值
+func loadConfigFile(path string, cfg *AppConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 用环境变量覆盖配置，无法解析的值记入 problems
+func applyEnv(cfg *AppConfig, problems *[]string) {
+	str := func(key string, target *string) {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			*target = v
+		}
+	}
+	num := func(key string, target *int) {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s=%q 不是整数", key, v))
+				return
+			}
+			*target = n
+		}
+	}
+	boolean := func(key string, target *bool) {
+		if v := os.Getenv(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s=%q 不是布尔值", key, v))
+				return
+			}
+			*target = b
+		}
+	}
+	duration := func(key string, unit time.Duration, target *Duration) {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				*problems = append(*problems, fmt.Sprintf("%s=%q 须为非负整数", key, v))
+				return
+			}
+			target.Duration = time.Duration(n) * unit
+		}
+	}
+
+	str("APP_ENV", &cfg.Env)
+	str("SERVER_HOST", &cfg.Server.Host)
+	num("SERVER_PORT", &cfg.Server.Port)
+
+	str("DB_HOST", &cfg.Database.Host)
+	num("DB_PORT", &cfg.Database.Port)
+	str("DB_USER", &cfg.Database.User)
+	str("DB_PASSWORD", &cfg.Database.Password)
+	str("DB_NAME", &cfg.Database.Name)
+	num("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	num("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME_MINUTES", time.Minute, &cfg.Database.ConnMaxLifetime)
+	duration("DB_CONN_MAX_IDLE_MINUTES", time.Minute, &cfg.Database.ConnMaxIdleTime)
+
+	str("JWT_KEYS_DIR", &cfg.JWT.KeysDir)
+	str("JWT_ACTIVE_KID", &cfg.JWT.ActiveKeyID)
+	duration("JWT_ACCESS_TTL_MINUTES", time.Minute, &cfg.JWT.AccessTTL)
+	duration("JWT_REFRESH_TTL_HOURS", time.Hour, &cfg.JWT.RefreshTTL)
+	str("ENCRYPTION_KEY", &cfg.Security.EncryptionKey)
+
+	list := func(key string, target *[]string) {
+		if v := os.Getenv(key); v != "" {
+			var items []string
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*target = items
+		}
+	}
+
+	list("CORS_ALLOW_ORIGINS", &cfg.CORS.AllowOrigins)
+
+	boolean("OIDC_ENABLED", &cfg.OIDC.Enabled)
+	str("OIDC_PROVIDER", &cfg.OIDC.Provider)
+	str("OIDC_DISPLAY_NAME", &cfg.OIDC.DisplayName)
+	str("OIDC_ISSUER", &cfg.OIDC.Issuer)
+	str("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	str("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	str("OIDC_REDIRECT_URL", &cfg.OIDC.RedirectURL)
+	str("OIDC_STATE_SECRET", &cfg.OIDC.StateSecret)
+	str("OIDC_USERNAME_CLAIM", &cfg.OIDC.UsernameClaim)
+	boolean("OIDC_AUTO_PROVISION", &cfg.OIDC.AutoProvision)
+	str("OIDC_DEFAULT_ROLE", &cfg.OIDC.DefaultRole)
+	str("OIDC_DEFAULT_USER_TYPE", &cfg.OIDC.DefaultUserType)
+
+	boolean("LDAP_ENABLED", &cfg.LDAP.Enabled)
+	str("LDAP_URL", &cfg.LDAP.URL)
+	boolean("LDAP_START_TLS", &cfg.LDAP.StartTLS)
+	str("LDAP_CA_CERT_FILE", &cfg.LDAP.CACertFile)
+	boolean("LDAP_INSECURE_SKIP_VERIFY", &cfg.LDAP.InsecureSkipVerify)
+	num("LDAP_TIMEOUT_SECONDS", &cfg.LDAP.TimeoutSeconds)
+	str("LDAP_BIND_DN", &cfg.LDAP.BindDN)
+	str("LDAP_BIND_PASSWORD", &cfg.LDAP.BindPassword)
+	str("LDAP_USER_BASE_DN", &cfg.LDAP.UserBaseDN)
+	str("LDAP_USER_FILTER", &cfg.LDAP.UserFilter)
+	str("LDAP_USERNAME_ATTR", &cfg.LDAP.UsernameAttr)
+	str("LDAP_EMAIL_ATTR", &cfg.LDAP.EmailAttr)
+	str("LDAP_GROUP_ATTR", &cfg.LDAP.GroupAttr)
+	str("LDAP_GROUP_BASE_DN", &cfg.LDAP.GroupBaseDN)
+	str("LDAP_GROUP_FILTER", &cfg.LDAP.GroupFilter)
+	if v := os.Getenv("LDAP_GROUP_ROLES"); v != "" {
+		rules, err := ParseLDAPGroupRoles(v)
+		if err != nil {
+			*problems = append(*problems, "LDAP_GROUP_ROLES："+err.Error())
+		} else {
+			cfg.LDAP.GroupRoles = rules
+		}
+	}
+	str("LDAP_DEFAULT_ROLE", &cfg.LDAP.DefaultRole)
+	str("LDAP_DEFAULT_USER_TYPE", &cfg.LDAP.DefaultUserType)
+	boolean("LDAP_AUTO_PROVISION", &cfg.LDAP.AutoProvision)
+	boolean("LDAP_SYNC_ROLE", &cfg.LDAP.SyncRole)
+
+	// AUTH_ORDER 为默认顺序，AUTH_ORDER_<TYPE>（如 AUTH_ORDER_TEACHER）按 user_type 覆盖
+	list("AUTH_ORDER", &cfg.Auth.Default)
+	for _, userType := range []string{"admin", "teacher", "student", "parent"} {
+		var methods []string
+		list("AUTH_ORDER_"+strings.ToUpper(userType), &methods)
+		if len(methods) > 0 {
+			if cfg.Auth.ByUserType == nil {
+				cfg.Auth.ByUserType = make(map[string][]string)
+			}
+			cfg.Auth.ByUserType[userType] = methods
+		}
+	}
+
+	boolean("FEATURE_SQL_CONSOLE", &cfg.Features.SQLConsole)
+	boolean("FEATURE_ALERT_SCHEDULER", &cfg.Features.AlertScheduler)
+	num("ALERT_HOUR", &cfg.Features.AlertHour)
+
+	duration("PERMISSION_CACHE_TTL_SECONDS", time.Second, &cfg.PermissionCache.TTL)
+	str("PERMISSION_CACHE_BUS", &cfg.PermissionCache.Bus)
+	duration("PERMISSION_CACHE_POLL_SECONDS", time.Second, &cfg.PermissionCache.PollInterval)
+}
+
+// Validate 校验配置，返回全部问题（为空表示有效）
+func (c *AppConfig) Validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Env {
+	case EnvDevelopment, EnvProduction, EnvTest:
+	default:
+		add("env 须为 development、production 或 test，当前为 %q", c.Env)
+	}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		add("server.port 须在 1-65535 之间")
+	}
+
+	if c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "" {
+		add("database.host、database.user、database.name 不能为空")
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		add("database.port 须在 1-65535 之间")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		add("数据库连接数不能为负数")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns 不能大于 max_open_conns")
+	}
+	if c.Database.ConnMaxLifetime.Duration < 0 || c.Database.ConnMaxIdleTime.Duration < 0 {
+		add("数据库连接存活时间不能为负数")
+	}
+
+	if c.JWT.AccessTTL.Duration <= 0 || c.JWT.RefreshTTL.Duration <= 0 {
+		add("令牌有效期须大于 0")
+	} else if c.JWT.AccessTTL.Duration >= c.JWT.RefreshTTL.Duration {
+		add("jwt.access_ttl 须小于 jwt.refresh_ttl")
+	}
+
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			add("cors.allow_origins 不能使用 *（接口需要携带凭据），请列出具体来源")
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			add("cors.allow_origins 中的 %q 不是有效的来源（形如 https://example.com）", origin)
+		}
+	}
+
+	if c.OIDC.Enabled {
+		if c.OIDC.Provider == "" || c.OIDC.Issuer == "" || c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" {
+			add("启用统一身份认证时 oidc.provider、oidc.issuer、oidc.client_id、oidc.redirect_url 不能为空")
+		}
+		if c.OIDC.StateSecret == "" && c.OIDC.ClientSecret == "" {
+			add("启用统一身份认证时 oidc.state_secret 与 oidc.client_secret 不能都为空（用于签名登录状态 Cookie）")
+		}
+		if c.OIDC.AutoProvision && c.OIDC.DefaultRole == "" {
+			add("开启自动创建用户时须设置 oidc.default_role")
+		}
+		if c.IsProduction() && strings.HasPrefix(c.OIDC.Issuer, "http://") {
+			add("生产环境的 oidc.issuer 须使用 https")
+		}
+	}
+
+	if c.LDAP.Enabled {
+		if u, err := url.Parse(c.LDAP.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+			add("ldap.url 须为 ldap:// 或 ldaps:// 地址，当前为 %q", c.LDAP.URL)
+		} else if c.IsProduction() && u.Scheme == "ldap" && !c.LDAP.StartTLS {
+			add("生产环境的 LDAP 连接须使用 ldaps:// 或开启 ldap.start_tls，避免明文传输密码")
+		}
+		if c.LDAP.UserBaseDN == "" || strings.Count(c.LDAP.UserFilter, "%s") != 1 {
+			add("启用 LDAP 时须设置 ldap.user_base_dn，ldap.user_filter 须包含且只包含一个 %%s")
+		}
+		if c.LDAP.GroupBaseDN != "" && strings.Count(c.LDAP.GroupFilter, "%s") != 1 {
+			add("ldap.group_filter 须包含且只包含一个 %%s")
+		}
+		if c.LDAP.TimeoutSeconds < 0 {
+			add("ldap.timeout_seconds 不能为负数")
+		}
+		if c.IsProduction() && c.LDAP.InsecureSkipVerify {
+			add("生产环境不能开启 ldap.insecure_skip_verify")
+		}
+		for _, rule := range c.LDAP.GroupRoles {
+			if rule.Group == "" || rule.Role == "" {
+				add("ldap.group_roles 中的组和角色都不能为空")
+				break
+			}
+		}
+	}
+
+	if c.Features.AlertHour < 0 || c.Features.AlertHour > 23 {
+		add("features.alert_hour 须在 0-23 之间")
+	}
+
+	if c.PermissionCache.TTL.Duration < 0 {
+		add("permission_cache.ttl 不能为负数")
+	}
+	switch c.PermissionCache.Bus {
+	case "", "db":
+		if c.PermissionCache.Bus == "db" && c.PermissionCache.PollInterval.Duration <= 0 {
+			add("permission_cache.poll_interval 须大于 0")
+		}
+	default:
+		add("permission_cache.bus 须为空或 db，当前为 %q", c.PermissionCache.Bus)
+	}
+
+	// 生产环境禁止使用临时密钥和弱配置
+	if c.IsProduction() {
+		if c.JWT.KeysDir == "" {
+			add("生产环境须配置 JWT 签名密钥目录（jwt.keys_dir 或 JWT_KEYS_DIR），不能使用临时密钥")
+		}
+		if len(c.Security.EncryptionKey) < 32 {
+			add("生产环境须设置至少 32 个字符的数据加密密钥（security.encryption_key 或 ENCRYPTION_KEY）")
+		}
+		if c.Database.Password == "" {
+			add("生产环境须设置数据库密码")
+		}
+		for _, origin := range c.CORS.AllowOrigins {
+			if strings.Contains(origin, "localhost") || strings.Contains(origin, "127.0.0.1") {
+				add("生产环境的 cors.allow_origins 不应包含本地地址 %q", origin)
+			}
+		}
+	}
+	return problems
+}
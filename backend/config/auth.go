@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 认证相关配置：统一身份认证（OIDC）、LDAP 目录与密码登录的认证顺序，由 internal/service 中对应的认证实现使用
+
+// OIDCConfig 身份提供方配置
+type OIDCConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Provider     string   `json:"provider"`     // 提供方标识，记录在 user_identities.provider
+	DisplayName  string   `json:"display_name"` // 登录页按钮上显示的名称
+	Issuer       string   `json:"issuer"`       // 如 https://idp.example.edu.cn，从 /.well-known/openid-configuration 获取各端点
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"` // 登录完成后回到的前端地址（如 http://localhost:5173/login）
+	Scopes       []string `json:"scopes"`       // 默认 openid profile email
+	StateSecret  string   `json:"state_secret"` // 签名登录状态 Cookie 的密钥，为空时由 client_secret 派生
+
+	UsernameClaim   string `json:"username_claim"`    // 用于匹配本系统用户名的声明，如 preferred_username、email、sub
+	AutoProvision   bool   `json:"auto_provision"`    // 找不到对应用户时是否自动创建
+	DefaultRole     string `json:"default_role"`      // 自动创建用户的角色名（roles.role_name）
+	DefaultUserType string `json:"default_user_type"` // 自动创建用户的 user_type
+}
+
+// LDAPConfig 目录服务配置
+type LDAPConfig struct {
+	Enabled            bool   `json:"enabled"`
+	URL                string `json:"url"`                  // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool   `json:"start_tls"`            // ldap:// 连接后升级为 TLS
+	CACertFile         string `json:"ca_cert_file"`         // 校验服务器证书的 CA（PEM），为空时使用系统根证书
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 不校验服务器证书，仅用于测试
+	TimeoutSeconds     int    `json:"timeout_seconds"`      // 连接与每次请求的超时
+
+	BindDN       string `json:"bind_dn"` // 查找用户的服务账号，为空时匿名查找
+	BindPassword string `json:"bind_password"`
+
+	UserBaseDN   string `json:"user_base_dn"`  // 如 ou=people,dc=example,dc=edu
+	UserFilter   string `json:"user_filter"`   // %s 替换为转义后的用户名，如 (uid=%s)、(sAMAccountName=%s)
+	UsernameAttr string `json:"username_attr"` // 条目中的用户名属性，须与登录名一致（忽略大小写）
+	EmailAttr    string `json:"email_attr"`
+
+	GroupAttr   string `json:"group_attr"`    // 用户条目上列出所属组 DN 的属性，如 memberOf，为空时不读取
+	GroupBaseDN string `json:"group_base_dn"` // 设置后另按 GroupFilter 查找用户所属的组
+	GroupFilter string `json:"group_filter"`  // %s 替换为转义后的用户 DN，如 (member=%s)
+
+	GroupRoles      []LDAPGroupRole `json:"group_roles"`       // 组到角色的映射，按顺序取第一个匹配的组
+	DefaultRole     string          `json:"default_role"`      // 不属于任何已映射的组时使用的角色，为空时不允许自动创建
+	DefaultUserType string          `json:"default_user_type"` // 自动创建用户的 user_type（角色为内置角色时与角色名一致）
+	AutoProvision   bool            `json:"auto_provision"`    // 本地没有同名用户时是否自动创建
+	SyncRole        bool            `json:"sync_role"`         // 每次登录按组映射更新已有用户的角色
+}
+
+// LDAPGroupRole 一条组到角色的映射
+type LDAPGroupRole struct {
+	Group string `json:"group"` // 组的完整 DN 或 cn（忽略大小写）
+	Role  string `json:"role"`  // roles.role_name
+}
+
+// ParseLDAPGroupRoles 解析 "组:角色;组:角色" 形式的映射（组可以是 cn 或完整 DN）
+func ParseLDAPGroupRoles(s string) ([]LDAPGroupRole, error) {
+	var rules []LDAPGroupRole
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, ":")
+		if i <= 0 || i == len(item)-1 {
+			return nil, fmt.Errorf("无效的组映射 %q（格式为 组:角色）", item)
+		}
+		rules = append(rules, LDAPGroupRole{Group: strings.TrimSpace(item[:i]), Role: strings.TrimSpace(item[i+1:])})
+	}
+	return rules, nil
+}
+
+// AuthOrder 认证方式的尝试顺序；用户自己的 auth_methods 优先，其次按 user_type，最后使用默认顺序
+type AuthOrder struct {
+	Default    []string            `json:"default"`      // 默认顺序，也用于尚无本地账号的用户名（如首次登录的目录账号）
+	ByUserType map[string][]string `json:"by_user_type"` // 按 user_type 覆盖，如 {"teacher": ["ldap", "local"]}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 选课人数维护相关的数据库对象
+// 说明：courses.enrolled_count 由 enrollments 表上的触发器统一维护，
+// 无论是存储过程选课、通用表管理的增删改，还是软删除（更新 deleted_at），
+// 都会在同一事务内同步修改计数，避免计数与实际选课记录不一致。
+// 与 docs/init_complete_database.sql 中的定义保持一致。
+var enrollmentCountObjects = []string{
+	`DROP TRIGGER IF EXISTS trg_enrollment_count_insert`,
+	`CREATE TRIGGER trg_enrollment_count_insert
+AFTER INSERT ON enrollments
+FOR EACH ROW
+BEGIN
+    IF NEW.deleted_at IS NULL THEN
+        UPDATE courses SET enrolled_count = enrolled_count + 1 WHERE id = NEW.course_id;
+    END IF;
+END`,
+
+	`DROP TRIGGER IF EXISTS trg_enrollment_count_update`,
+	`CREATE TRIGGER trg_enrollment_count_update
+AFTER UPDATE ON enrollments
+FOR EACH ROW
+BEGIN
+    -- 原记录有效，且被软删除或换了课程：原课程人数 -1
+    IF OLD.deleted_at IS NULL AND (NEW.deleted_at IS NOT NULL OR NEW.course_id <> OLD.course_id) THEN
+        UPDATE courses SET enrolled_count = GREATEST(enrolled_count - 1, 0) WHERE id = OLD.course_id;
+    END IF;
+    -- 新记录有效，且是从软删除恢复或换了课程：新课程人数 +1
+    IF NEW.deleted_at IS NULL AND (OLD.deleted_at IS NOT NULL OR NEW.course_id <> OLD.course_id) THEN
+        UPDATE courses SET enrolled_count = enrolled_count + 1 WHERE id = NEW.course_id;
+    END IF;
+END`,
+
+	`DROP TRIGGER IF EXISTS trg_enrollment_count_delete`,
+	`CREATE TRIGGER trg_enrollment_count_delete
+AFTER DELETE ON enrollments
+FOR EACH ROW
+BEGIN
+    IF OLD.deleted_at IS NULL THEN
+        UPDATE courses SET enrolled_count = GREATEST(enrolled_count - 1, 0) WHERE id = OLD.course_id;
+    END IF;
+END`,
+
+	`DROP PROCEDURE IF EXISTS sp_enroll_student`,
+	`CREATE PROCEDURE sp_enroll_student(
+    IN p_student_id BIGINT UNSIGNED,
+    IN p_course_id BIGINT UNSIGNED,
+    OUT p_status INT,       -- 0: 成功, 1: 已选/其他失败, 2: 先修未满足, 3: 课程已满
+    OUT p_message VARCHAR(255)
+)
+BEGIN
+    DECLARE v_capacity INT;
+    DECLARE v_enrolled INT;
+    DECLARE v_already_enrolled INT;
+    DECLARE v_prereq_count INT;
+    DECLARE v_prereq_met INT;
+    DECLARE v_deleted_id BIGINT UNSIGNED DEFAULT NULL;
+
+    DECLARE EXIT HANDLER FOR SQLEXCEPTION
+    BEGIN
+        ROLLBACK;
+        SET p_status = 1;
+        SET p_message = '选课失败';
+    END;
+
+    START TRANSACTION;
+
+    SELECT capacity, enrolled_count INTO v_capacity, v_enrolled
+    FROM courses
+    WHERE id = p_course_id
+    FOR UPDATE;
+
+    SELECT COUNT(*) INTO v_already_enrolled
+    FROM enrollments
+    WHERE student_id = p_student_id AND course_id = p_course_id AND deleted_at IS NULL;
+
+    IF v_already_enrolled > 0 THEN
+        SET p_status = 1;
+        SET p_message = '已经选过该课程';
+        ROLLBACK;
+    ELSE
+        SELECT COUNT(*) INTO v_prereq_count
+        FROM course_prerequisites
+        WHERE course_id = p_course_id;
+
+        SELECT COUNT(DISTINCT cp.prereq_id) INTO v_prereq_met
+        FROM course_prerequisites cp
+        JOIN enrollments e ON cp.prereq_id = e.course_id
+        JOIN grades g ON e.id = g.enrollment_id
+        WHERE cp.course_id = p_course_id
+          AND e.student_id = p_student_id
+          AND g.score >= 60
+          AND e.deleted_at IS NULL;
+
+        IF v_prereq_met < v_prereq_count THEN
+            SET p_status = 2;
+            SET p_message = '未完成先修课程要求';
+            ROLLBACK;
+        ELSEIF v_enrolled >= v_capacity THEN
+            SET p_status = 3;
+            SET p_message = '课程已满';
+            ROLLBACK;
+        ELSE
+            -- 曾经退选（软删除）的记录直接恢复，避免触发唯一索引冲突
+            SELECT id INTO v_deleted_id
+            FROM enrollments
+            WHERE student_id = p_student_id AND course_id = p_course_id AND deleted_at IS NOT NULL
+            LIMIT 1;
+
+            IF v_deleted_id IS NOT NULL THEN
+                UPDATE enrollments SET deleted_at = NULL, updated_at = NOW(3) WHERE id = v_deleted_id;
+            ELSE
+                INSERT INTO enrollments (created_at, updated_at, student_id, course_id)
+                VALUES (NOW(3), NOW(3), p_student_id, p_course_id);
+            END IF;
+
+            -- 已选人数由 trg_enrollment_count_* 触发器维护
+            SET p_status = 0;
+            SET p_message = '选课成功';
+            COMMIT;
+        END IF;
+    END IF;
+END`,
+}
+
+// 选课记录所属学期由课程决定，插入或换课时自动填充 enrollments.semester_id
+var enrollmentSemesterObjects = []string{
+	`DROP TRIGGER IF EXISTS trg_enrollment_semester_insert`,
+	`CREATE TRIGGER trg_enrollment_semester_insert
+BEFORE INSERT ON enrollments
+FOR EACH ROW
+BEGIN
+    SET NEW.semester_id = COALESCE((SELECT semester_id FROM courses WHERE id = NEW.course_id), 0);
+END`,
+
+	`DROP TRIGGER IF EXISTS trg_enrollment_semester_update`,
+	`CREATE TRIGGER trg_enrollment_semester_update
+BEFORE UPDATE ON enrollments
+FOR EACH ROW
+BEGIN
+    IF NEW.course_id <> OLD.course_id THEN
+        SET NEW.semester_id = COALESCE((SELECT semester_id FROM courses WHERE id = NEW.course_id), 0);
+    END IF;
+END`,
+}
+
+// 家长表中的主要关联学生 (parents.student_id) 自动同步到 student_guardians，
+// 兼容仍按单一学生维护家长信息的旧接口与通用表管理
+var guardianObjects = []string{
+	`DROP TRIGGER IF EXISTS trg_parent_guardian_insert`,
+	`CREATE TRIGGER trg_parent_guardian_insert
+AFTER INSERT ON parents
+FOR EACH ROW
+BEGIN
+    IF NEW.student_id > 0 AND NEW.deleted_at IS NULL THEN
+        INSERT IGNORE INTO student_guardians (student_id, parent_id, relation, created_at)
+        VALUES (NEW.student_id, NEW.id, NEW.relation, NOW(3));
+    END IF;
+END`,
+
+	`DROP TRIGGER IF EXISTS trg_parent_guardian_update`,
+	`CREATE TRIGGER trg_parent_guardian_update
+AFTER UPDATE ON parents
+FOR EACH ROW
+BEGIN
+    -- 更换主要关联学生：移除原关联，建立新关联（其他子女的关联不受影响）
+    IF NEW.student_id <> OLD.student_id THEN
+        DELETE FROM student_guardians WHERE student_id = OLD.student_id AND parent_id = OLD.id;
+    END IF;
+    IF NEW.student_id > 0 AND NEW.deleted_at IS NULL THEN
+        INSERT IGNORE INTO student_guardians (student_id, parent_id, relation, created_at)
+        VALUES (NEW.student_id, NEW.id, NEW.relation, NOW(3));
+    END IF;
+    -- 家长被软删除时移除其全部监护关系
+    IF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        DELETE FROM student_guardians WHERE parent_id = NEW.id;
+    END IF;
+END`,
+
+	`DROP TRIGGER IF EXISTS trg_parent_guardian_delete`,
+	`CREATE TRIGGER trg_parent_guardian_delete
+AFTER DELETE ON parents
+FOR EACH ROW
+BEGIN
+    DELETE FROM student_guardians WHERE parent_id = OLD.id;
+END`,
+}
+
+// InitDatabaseObjects 在 db 上创建/更新触发器与存储过程，并校正已选人数（InitDB 调用，测试也用它初始化测试库）
+// 创建触发器或存储过程失败时返回错误；之后的数据校正尽力而为，失败只记录日志
+func InitDatabaseObjects(db *gorm.DB) error {
+	objects := append(append([]string{}, enrollmentCountObjects...), enrollmentSemesterObjects...)
+	objects = append(objects, guardianObjects...)
+	for _, stmt := range objects {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// 课程调整学期后，同步已有选课记录的学期
+	if err := db.Exec(`UPDATE enrollments e JOIN courses c ON c.id = e.course_id
+        SET e.semester_id = c.semester_id WHERE e.semester_id <> c.semester_id`).Error; err != nil {
+		log.Printf("警告: 同步选课记录学期失败: %v", err)
+	}
+
+	// 迁移旧数据：家长表中的单一关联学生补录为监护关系
+	if err := db.Exec(`INSERT IGNORE INTO student_guardians (student_id, parent_id, relation, created_at)
+        SELECT student_id, id, relation, NOW(3) FROM parents WHERE student_id > 0 AND deleted_at IS NULL`).Error; err != nil {
+		log.Printf("警告: 同步家长监护关系失败: %v", err)
+	}
+
+	// 以实际选课记录为准重新计算一次，修正历史数据中的偏差
+	result := db.Exec(`UPDATE courses c SET enrolled_count = (
+        SELECT COUNT(*) FROM enrollments e WHERE e.course_id = c.id AND e.deleted_at IS NULL
+    )`)
+	if result.Error != nil {
+		log.Printf("警告: 校正课程已选人数失败: %v", result.Error)
+		return nil
+	}
+
+	log.Printf("选课计数触发器初始化完成，已校正 %d 门课程的已选人数", result.RowsAffected)
+	return nil
+}
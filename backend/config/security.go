@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"student-management-system/internal/utils"
+)
+
+// loadSigningKeys 加载 JWT 签名密钥；未配置密钥目录时（仅限非生产环境）生成临时 Ed25519 密钥，重启后已签发的访问令牌失效
+func loadSigningKeys(cfg *AppConfig) error {
+	if cfg.JWT.KeysDir == "" {
+		key, err := utils.GenerateSigningKey("dev-"+time.Now().Format("20060102150405"), utils.AlgEdDSA)
+		if err != nil {
+			return err
+		}
+		ks, err := utils.NewKeySet(key)
+		if err != nil {
+			return err
+		}
+		log.Println("警告: 未配置 JWT_KEYS_DIR，使用临时签名密钥，仅限开发环境")
+		utils.SetKeySet(ks)
+		return nil
+	}
+
+	ks, err := utils.LoadKeySet(cfg.JWT.KeysDir, cfg.JWT.ActiveKeyID)
+	if err != nil {
+		return fmt.Errorf("加载 JWT 签名密钥失败: %w", err)
+	}
+	log.Printf("JWT 签名密钥: %s（%s），共 %d 个验证密钥", ks.Active.KID, ks.Active.Alg, len(ks.Keys()))
+	utils.SetKeySet(ks)
+	return nil
+}
+
+// devEncryptionKey 未配置数据加密密钥时（仅限非生产环境）使用的固定密钥，保证重启后仍能解密已存储的数据
+const devEncryptionKey = "student-management-system-development-only"
+
+// loadEncryptionKey 设置加密存储敏感数据（两步验证密钥）的密钥；更换密钥后用旧密钥加密的数据无法解密
+func loadEncryptionKey(cfg *AppConfig) error {
+	key := cfg.Security.EncryptionKey
+	if key == "" {
+		log.Println("警告: 未配置 ENCRYPTION_KEY，使用固定的开发密钥加密敏感数据，仅限开发环境")
+		key = devEncryptionKey
+	}
+	return utils.SetSecretKey(key)
+}
+
+// 令牌有效期，由 Load 按配置（jwt.access_ttl / jwt.refresh_ttl，环境变量 JWT_ACCESS_TTL_MINUTES / JWT_REFRESH_TTL_HOURS）设置
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// 两步验证设置，由环境变量覆盖默认值：
+//   - TWO_FACTOR_REQUIRED_PERMISSIONS  拥有其中任一权限的角色必须启用两步验证，逗号分隔，支持 * 后缀（默认 admin:*，为空表示不强制）
+var (
+	TwoFactorRequiredPermissions = []string{"admin:*"}
+	TwoFactorIssuer              = "学生管理系统" // 验证器 App 中显示的发行方
+	PartialTokenTTL              = 5 * time.Minute
+)
+
+// loadTwoFactorSettings 从环境变量加载两步验证设置
+func loadTwoFactorSettings() {
+	v, ok := os.LookupEnv("TWO_FACTOR_REQUIRED_PERMISSIONS")
+	if !ok {
+		return
+	}
+	patterns := []string{}
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	TwoFactorRequiredPermissions = patterns
+}
@@ -1,67 +1,212 @@
 package api
 
 import (
+	"student-management-system/config"
 	v1 "student-management-system/internal/api/v1"
 	"student-management-system/internal/middleware"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRouter 设置路由
 func SetupRouter() *gin.Engine {
+	r, _ := BuildRouter()
+	return r
+}
+
+// BuildRouter 设置路由，并返回路由访问控制表（权限目录由其生成）
+// 每条路由都经访问控制表注册：public 无需登录，login 登录即可，partial 接受两步验证临时令牌，require 须拥有指定权限
+func BuildRouter() (*gin.Engine, *RouteTable) {
 	r := gin.Default()
+	table := newRouteTable()
 
 	// CORS中间件
 	r.Use(middleware.CORSMiddleware())
 
+	// JWT 验证公钥（无需认证）
+	table.publicGroup(&r.RouterGroup).public("GET", "/.well-known/jwks.json", v1.GetJWKS)
+
 	// API v1
 	apiV1 := r.Group("/api/v1")
 	{
-		// 认证相关（无需认证）
-		auth := apiV1.Group("/auth")
+		// 认证相关（登录、刷新等无需认证）
+		auth := table.publicGroup(apiV1.Group("/auth"))
 		{
-			auth.POST("/login", v1.Login)
-			auth.POST("/logout", v1.Logout)
-			auth.GET("/me", middleware.AuthMiddleware(), v1.GetCurrentUser)
-			auth.PUT("/password", middleware.AuthMiddleware(), v1.UpdatePassword)
+			auth.public("POST", "/login", v1.Login)
+			auth.public("POST", "/refresh", v1.RefreshToken)
+			auth.public("POST", "/logout", v1.Logout)
+
+			// 统一身份认证（OIDC 授权码模式）
+			auth.public("GET", "/oidc/config", v1.GetOIDCConfig)
+			auth.public("GET", "/oidc/authorize", v1.OIDCAuthorize)
+			auth.public("POST", "/oidc/callback", v1.OIDCCallback)
+
+			auth.login("GET", "/me", v1.GetCurrentUser)
+			auth.login("PUT", "/password", v1.UpdatePassword)
+			auth.login("GET", "/sessions", v1.GetMySessions)
+			auth.login("DELETE", "/sessions", v1.RevokeMyOtherSessions)
+			auth.login("DELETE", "/sessions/:id", v1.RevokeMySession)
+
+			// 两步验证：verify 只接受登录返回的临时令牌；setup、enable 还接受须设置两步验证的临时令牌，
+			// 用该临时令牌调用时须提交管理员签发的设置码（见 /admin/users/:id/2fa/enrollment）
+			auth.partial("POST", "/2fa/verify", middleware.PartialAuthMiddleware(false, utils.PurposeTwoFactor), v1.VerifyTwoFactor)
+			auth.login("GET", "/2fa", v1.GetTwoFactorStatus)
+			auth.partial("POST", "/2fa/setup", middleware.PartialAuthMiddleware(true, utils.PurposeTwoFactorEnroll), v1.SetupTwoFactor)
+			auth.partial("POST", "/2fa/enable", middleware.PartialAuthMiddleware(true, utils.PurposeTwoFactorEnroll), v1.EnableTwoFactor)
+			auth.login("POST", "/2fa/disable", v1.DisableTwoFactor)
+			auth.login("POST", "/2fa/backup-codes", v1.RegenerateBackupCodes)
 		}
 
 		// 管理员模块（需要认证 和 特定权限）
-		admin := apiV1.Group("/admin")
-		admin.Use(middleware.AuthMiddleware()) // AuthMiddleware 必须在前面
+		// AuthMiddleware 必须在前面，数据范围依赖登录用户
+		admin := table.authGroup(apiV1.Group("/admin")).use(middleware.DataScopeMiddleware(), permission.DataScopeAll)
 		{
 			// 用户管理
-			admin.GET("/users", middleware.PermissionMiddleware("admin:user:read"), v1.AdminListUsers)
-			admin.POST("/users", middleware.PermissionMiddleware("admin:user:create"), v1.AdminCreateUser)
-			admin.PUT("/users/:id", middleware.PermissionMiddleware("admin:user:update"), v1.AdminUpdateUser)
-			admin.DELETE("/users/:id", middleware.PermissionMiddleware("admin:user:delete"), v1.AdminDeleteUser)
+			admin.require("GET", "/users", permission.UserRead, v1.AdminListUsers)
+			admin.require("POST", "/users", permission.UserCreate, v1.AdminCreateUser)
+			admin.require("PUT", "/users/:id", permission.UserUpdate, v1.AdminUpdateUser)
+			admin.require("DELETE", "/users/:id", permission.UserDelete, v1.AdminDeleteUser)
+			admin.require("POST", "/classes/:id/accounts", permission.UserCreate, v1.AdminProvisionClassAccounts)
+			admin.require("GET", "/credential-sheets/:token", permission.UserCreate, v1.AdminDownloadCredentialSheet)
+
+			// 登录会话管理
+			admin.require("GET", "/sessions", permission.SessionRead, v1.AdminListSessions)
+			admin.require("DELETE", "/sessions/:id", permission.SessionDelete, v1.AdminRevokeSession)
+			admin.require("DELETE", "/users/:id/sessions", permission.SessionDelete, v1.AdminRevokeUserSessions)
+
+			// 登录审计与锁定
+			admin.require("GET", "/login-attempts", permission.LoginAuditRead, v1.AdminListLoginAttempts)
+			admin.require("GET", "/login-locks", permission.LoginAuditRead, v1.AdminListLoginLocks)
+			admin.require("POST", "/login-locks/unlock", permission.LoginAuditUnlock, v1.AdminUnlockLogin)
+			admin.require("POST", "/users/:id/unlock", permission.LoginAuditUnlock, v1.AdminUnlockUser)
+			admin.require("DELETE", "/users/:id/2fa", permission.UserUpdate, v1.AdminResetTwoFactor)
+			admin.require("POST", "/users/:id/2fa/enrollment", permission.UserUpdate, v1.AdminIssueTwoFactorEnrollment)
+
+			// 外部身份关联（统一身份认证只能登录已关联的账号）
+			admin.require("GET", "/users/:id/identities", permission.UserRead, v1.AdminListUserIdentities)
+			admin.require("POST", "/users/:id/identities", permission.UserUpdate, v1.AdminLinkUserIdentity)
+			admin.require("DELETE", "/users/:id/identities/:identity_id", permission.UserUpdate, v1.AdminUnlinkUserIdentity)
 
 			// 角色管理
-			admin.GET("/roles", middleware.PermissionMiddleware("admin:role:read"), v1.AdminListRoles)
-			admin.POST("/roles", middleware.PermissionMiddleware("admin:role:create"), v1.AdminCreateRole)
-			admin.PUT("/roles/:id", middleware.PermissionMiddleware("admin:role:update"), v1.AdminUpdateRole)
-			admin.DELETE("/roles/:id", middleware.PermissionMiddleware("admin:role:delete"), v1.AdminDeleteRole)
+			admin.require("GET", "/roles", permission.RoleRead, v1.AdminListRoles)
+			admin.require("POST", "/roles", permission.RoleCreate, v1.AdminCreateRole)
+			admin.require("PUT", "/roles/:id", permission.RoleUpdate, v1.AdminUpdateRole)
+			admin.require("DELETE", "/roles/:id", permission.RoleDelete, v1.AdminDeleteRole)
 
 			// 权限管理 (新 API)
-			admin.GET("/permissions", middleware.PermissionMiddleware("admin:role:read"), v1.AdminListPermissions)
-			admin.GET("/roles/:id/permissions", middleware.PermissionMiddleware("admin:role:read"), v1.AdminGetRolePermissions)
-			admin.POST("/roles/:id/permissions", middleware.PermissionMiddleware("admin:role:update"), v1.AdminUpdateRolePermissions)
+			admin.require("GET", "/permissions", permission.RoleRead, v1.AdminListPermissions)
+			admin.require("GET", "/roles/:id/permissions", permission.RoleRead, v1.AdminGetRolePermissions)
+			admin.require("POST", "/roles/:id/permissions", permission.RoleUpdate, v1.AdminUpdateRolePermissions)
+			admin.require("GET", "/roles/:id/permission-diff", permission.RoleRead, v1.AdminGetRolePermissionDiff)
+			admin.require("POST", "/roles/:id/reset-permissions", permission.RoleUpdate, v1.AdminResetRolePermissions)
+			admin.require("GET", "/users/:id/effective-permissions", permission.RoleRead, v1.AdminGetUserEffectivePermissions)
+
+			// 候补名单管理
+			admin.require("GET", "/courses/:id/waitlist", permission.WaitlistRead, v1.AdminGetWaitlist)
+			admin.require("PUT", "/courses/:id/waitlist", permission.WaitlistUpdate, v1.AdminReorderWaitlist)
+			admin.require("POST", "/courses/:id/waitlist/promote", permission.WaitlistUpdate, v1.AdminPromoteWaitlist)
+
+			// 学期管理
+			admin.require("GET", "/semesters", permission.SemesterRead, v1.AdminListSemesters)
+			admin.require("POST", "/semesters", permission.SemesterCreate, v1.AdminCreateSemester)
+			admin.require("PUT", "/semesters/:id", permission.SemesterUpdate, v1.AdminUpdateSemester)
+			admin.require("DELETE", "/semesters/:id", permission.SemesterDelete, v1.AdminDeleteSemester)
+			admin.require("PUT", "/semesters/:id/courses", permission.SemesterUpdate, v1.AdminSetSemesterCourses)
+
+			// 学业预警
+			admin.require("GET", "/alert-rules", permission.AlertRead, v1.AdminListAlertRules)
+			admin.require("PUT", "/alert-rules/:id", permission.AlertUpdate, v1.AdminUpdateAlertRule)
+			admin.require("GET", "/alerts", permission.AlertRead, v1.AdminListAlerts)
+			admin.require("PUT", "/alerts/:id/status", permission.AlertUpdate, v1.AdminUpdateAlertStatus)
+			admin.require("POST", "/alerts/run", permission.AlertRun, v1.AdminRunAlerts)
+
+			// 家长监护关系
+			admin.require("GET", "/parents/:id/children", permission.GuardianRead, v1.AdminGetParentChildren)
+			admin.require("POST", "/parents/:id/children", permission.GuardianUpdate, v1.AdminAddParentChild)
+			admin.require("DELETE", "/parents/:id/children/:student_id", permission.GuardianUpdate, v1.AdminRemoveParentChild)
+		}
+
+		// 选课管理（需要认证和选课权限；有 enrollment:override 时可在选课时间外加退选）
+		enrollments := table.authGroup(apiV1.Group("/enrollments")).use(middleware.DataScopeMiddleware(), permission.DataScopeAll)
+		{
+			enrollments.require("POST", "", permission.EnrollmentCreate, v1.CreateEnrollment).checks(permission.EnrollmentOverride)
+			enrollments.require("DELETE", "/:id", permission.EnrollmentDelete, v1.DeleteEnrollment).checks(permission.EnrollmentOverride)
+		}
+
+		// 学期与开课信息（登录即可查看）
+		semesters := table.authGroup(apiV1.Group("/semesters"))
+		{
+			semesters.login("GET", "/current", v1.GetCurrentSemester)
+			semesters.login("GET", "/:id/courses", v1.GetSemesterCourses)
+		}
+
+		// 学生学业数据（需要认证，处理器内校验本人/家长/教职工权限）
+		students := table.authGroup(apiV1.Group("/students")).use(middleware.DataScopeMiddleware(), permission.DataScopeAll)
+		{
+			students.login("GET", "/:id/transcript", v1.GetStudentTranscript).checks(permission.TranscriptRead)
+		}
+
+		// 排名（需要认证；无 ranking:read 权限的学生只能看到本人名次）
+		rankings := table.authGroup(apiV1.Group("/rankings")).use(middleware.DataScopeMiddleware(), permission.DataScopeAll)
+		{
+			rankings.login("GET", "/classes/:id", v1.GetClassRanking).checks(permission.RankingRead)
+			rankings.login("GET", "/courses/:id", v1.GetCourseRanking).checks(permission.RankingRead)
+			rankings.login("GET", "/school", v1.GetSchoolRanking).checks(permission.RankingRead)
+		}
+
+		// 班主任的学业预警（需要认证，仅限指派给本人的记录）
+		alerts := table.authGroup(apiV1.Group("/alerts"))
+		{
+			alerts.login("GET", "/assigned", v1.GetAssignedAlerts)
+			alerts.login("PUT", "/:id/status", v1.UpdateAssignedAlertStatus)
+		}
+
+		// 学生自助（需要认证，仅学生账号，权限默认授予 student 角色；数据范围固定为本人）
+		me := table.authGroup(apiV1.Group("/me")).use(middleware.SelfStudentMiddleware())
+		{
+			me.require("GET", "/timetable", permission.SelfRecordRead, v1.GetMyTimetable)
+			me.require("GET", "/courses", permission.SelfRecordRead, v1.GetMyCourses)
+			me.require("GET", "/grades", permission.SelfRecordRead, v1.GetScopedStudentGrades)
+			me.require("GET", "/attendance", permission.SelfRecordRead, v1.GetScopedStudentAttendance)
+			me.require("GET", "/rewards", permission.SelfRecordRead, v1.GetScopedStudentRewards)
+			me.require("GET", "/notifications", permission.SelfRecordRead, v1.GetScopedStudentNotifications)
+			me.require("GET", "/transcript", permission.SelfRecordRead, v1.GetMyTranscript)
+			me.require("POST", "/enrollments", permission.SelfEnrollment, v1.CreateMyEnrollment)
+			me.require("DELETE", "/enrollments/:course_id", permission.SelfEnrollment, v1.DeleteMyEnrollment)
+		}
+
+		// 家长门户（需要认证，仅家长账号，权限默认授予 parent 角色；子女数据经监护关系行级校验）
+		parent := table.authGroup(apiV1.Group("/parent")).use(middleware.ParentMiddleware())
+		{
+			parent.require("GET", "/children", permission.ChildRecordRead, v1.GetParentChildren)
+
+			child := parent.subgroup("/children/:id", middleware.GuardianMiddleware())
+			{
+				child.require("GET", "/grades", permission.ChildRecordRead, v1.GetScopedStudentGrades)
+				child.require("GET", "/attendance", permission.ChildRecordRead, v1.GetScopedStudentAttendance)
+				child.require("GET", "/schedule", permission.ChildRecordRead, v1.GetScopedStudentSchedule)
+				child.require("GET", "/rewards", permission.ChildRecordRead, v1.GetScopedStudentRewards)
+				child.require("GET", "/notifications", permission.ChildRecordRead, v1.GetScopedStudentNotifications)
+			}
 		}
 
 		// 数据库管理（需要认证和管理员权限）
-		database := apiV1.Group("/database")
-		database.Use(middleware.AuthMiddleware())
+		database := table.authGroup(apiV1.Group("/database")).use(middleware.DataScopeMiddleware(), permission.DataScopeAll)
 		{
-			database.GET("/tables", middleware.PermissionMiddleware("admin:user:read"), v1.GetTableList)
-			database.GET("/tables/:table", middleware.PermissionMiddleware("admin:user:read"), v1.GetTableData)
-			database.GET("/tables/:table/schema", middleware.PermissionMiddleware("admin:user:read"), v1.GetTableSchema)
-			database.POST("/tables/:table", middleware.PermissionMiddleware("admin:user:create"), v1.CreateTableData)
-			database.PUT("/tables/:table/:id", middleware.PermissionMiddleware("admin:user:update"), v1.UpdateTableData)
-			database.DELETE("/tables/:table/:id", middleware.PermissionMiddleware("admin:user:delete"), v1.DeleteTableData)
-			database.GET("/tables/:table/export", middleware.PermissionMiddleware("admin:user:read"), v1.ExportTableData)
-			database.POST("/execute", middleware.PermissionMiddleware("admin:user:create"), v1.ExecuteSQL)
+			database.require("GET", "/tables", permission.UserRead, v1.GetTableList)
+			database.require("GET", "/tables/:table", permission.UserRead, v1.GetTableData)
+			database.require("GET", "/tables/:table/schema", permission.UserRead, v1.GetTableSchema)
+			database.require("POST", "/tables/:table", permission.UserCreate, v1.CreateTableData)
+			database.require("PUT", "/tables/:table/:id", permission.UserUpdate, v1.UpdateTableData)
+			database.require("DELETE", "/tables/:table/:id", permission.UserDelete, v1.DeleteTableData)
+			database.require("GET", "/tables/:table/export", permission.UserRead, v1.ExportTableData)
+			if config.Current().Features.SQLConsole { // 可通过 features.sql_console / FEATURE_SQL_CONSOLE 关闭
+				database.require("POST", "/execute", permission.UserCreate, v1.ExecuteSQL)
+			}
 		}
 	}
 
-	return r
+	return r, table
 }
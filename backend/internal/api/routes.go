@@ -0,0 +1,240 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 路由访问控制表：每条路由在注册时声明一次访问控制（由此挂上对应的中间件），权限目录由该表生成
+
+// 路由的访问控制方式
+const (
+	GuardPublic     = "public"     // 无需登录
+	GuardLogin      = "login"      // 登录即可（处理器或中间件内另行校验本人、家长等数据范围）
+	GuardPartial    = "partial"    // 两步验证流程的临时令牌
+	GuardPermission = "permission" // 须拥有指定权限
+)
+
+// RouteGuard 一条路由及其访问控制
+type RouteGuard struct {
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Guard      string   `json:"guard"`
+	Permission string   `json:"permission,omitempty"` // Guard 为 permission 时要求的权限
+	Checks     []string `json:"checks,omitempty"`     // 处理器或中间件内判断的其他权限（如 data:scope:all）
+}
+
+// RouteTable 路由访问控制表
+type RouteTable struct {
+	routes    []*RouteGuard
+	catalog   []permission.Permission
+	seen      map[string]permission.Permission
+	conflicts []string // 同一权限标识的名称或分组不一致
+}
+
+func newRouteTable() *RouteTable {
+	return &RouteTable{seen: make(map[string]permission.Permission)}
+}
+
+// Routes 全部路由，按注册顺序
+func (t *RouteTable) Routes() []RouteGuard {
+	routes := make([]RouteGuard, len(t.routes))
+	for i, r := range t.routes {
+		routes[i] = *r
+	}
+	return routes
+}
+
+// Catalog 路由引用的全部权限（权限目录），按首次引用的顺序
+func (t *RouteTable) Catalog() []permission.Permission {
+	return append([]permission.Permission(nil), t.catalog...)
+}
+
+func (t *RouteTable) reference(perms ...permission.Permission) []string {
+	codes := make([]string, 0, len(perms))
+	for _, p := range perms {
+		if first, ok := t.seen[p.Code]; !ok {
+			t.seen[p.Code] = p
+			t.catalog = append(t.catalog, p)
+		} else if first != p {
+			t.conflicts = append(t.conflicts, fmt.Sprintf("权限 %s 的定义不一致：%q/%q 与 %q/%q", p.Code, first.Name, first.Group, p.Name, p.Group))
+		}
+		codes = append(codes, p.Code)
+	}
+	return codes
+}
+
+// Verify 检查路由访问控制表，返回发现的问题：
+// 所有路由都须经访问控制表注册（直接在 gin 上注册的路由没有声明访问控制），
+// 权限标识须为不含通配符的合法标识，同一标识的名称与分组须一致
+func (t *RouteTable) Verify(engine *gin.Engine) []string {
+	problems := append([]string(nil), t.conflicts...)
+	declared := make(map[string]bool, len(t.routes))
+	for _, r := range t.routes {
+		key := r.Method + " " + r.Path
+		if declared[key] {
+			problems = append(problems, "路由重复登记："+key)
+		}
+		declared[key] = true
+	}
+	registered := make(map[string]bool)
+	for _, info := range engine.Routes() {
+		key := info.Method + " " + info.Path
+		registered[key] = true
+		if !declared[key] {
+			problems = append(problems, "路由未声明访问控制："+key)
+		}
+	}
+	for key := range declared {
+		if !registered[key] {
+			problems = append(problems, "登记的路由未注册到 gin："+key)
+		}
+	}
+	for _, p := range t.catalog {
+		if err := service.ValidatePermissionPattern(p.Code); err != nil {
+			problems = append(problems, err.Error())
+		} else if service.IsPermissionWildcard(p.Code) {
+			problems = append(problems, "路由要求的权限不能是通配符："+p.Code)
+		}
+		if p.Name == "" || p.Group == "" {
+			problems = append(problems, "权限 "+p.Code+" 缺少名称或分组")
+		}
+		if def, ok := permission.Lookup(p.Code); !ok || def != p {
+			problems = append(problems, "权限 "+p.Code+" 未加入 permission.All 或定义不一致")
+		}
+	}
+	problems = append(problems, verifyDefaults(t.catalog)...)
+	sort.Strings(problems)
+	return problems
+}
+
+// verifyDefaults 检查内置角色的默认权限清单：格式合法、精确的权限标识在权限目录中、同一项不同时授权和拒绝
+func verifyDefaults(catalog []permission.Permission) []string {
+	var problems []string
+	known := make(map[string]bool, len(catalog))
+	for _, p := range catalog {
+		known[p.Code] = true
+	}
+	for _, d := range permission.Defaults {
+		allowed := make(map[string]bool, len(d.Allow))
+		for _, p := range d.Allow {
+			allowed[p] = true
+		}
+		for _, list := range [][]string{d.Allow, d.Deny} {
+			for _, p := range list {
+				if err := service.ValidatePermissionPattern(p); err != nil {
+					problems = append(problems, "角色 "+d.Role+" 的默认权限不合法："+err.Error())
+				} else if !service.IsPermissionWildcard(p) && !known[p] {
+					problems = append(problems, "角色 "+d.Role+" 的默认权限不在权限目录中："+p)
+				}
+			}
+		}
+		for _, p := range d.Deny {
+			if allowed[p] {
+				problems = append(problems, "角色 "+d.Role+" 的默认权限同时授权和拒绝："+p)
+			}
+		}
+	}
+	return problems
+}
+
+// routes 在路由组上注册路由并登记访问控制
+type routes struct {
+	table         *RouteTable
+	group         *gin.RouterGroup
+	authenticated bool                    // 路由组已挂 AuthMiddleware
+	checks        []permission.Permission // 路由组中间件内判断的权限
+}
+
+// publicGroup 无需登录的路由组（其中的路由可单独要求登录）
+func (t *RouteTable) publicGroup(group *gin.RouterGroup) routes {
+	return routes{table: t, group: group}
+}
+
+// authGroup 须登录的路由组（挂 AuthMiddleware）
+func (t *RouteTable) authGroup(group *gin.RouterGroup) routes {
+	group.Use(middleware.AuthMiddleware())
+	return routes{table: t, group: group, authenticated: true}
+}
+
+// use 为路由组挂中间件；checks 为该中间件内判断的权限
+func (r routes) use(handler gin.HandlerFunc, checks ...permission.Permission) routes {
+	r.group.Use(handler)
+	r.checks = append(append([]permission.Permission(nil), r.checks...), checks...)
+	return r
+}
+
+// subgroup 子路由组，沿用上级的访问控制
+func (r routes) subgroup(relativePath string, handlers ...gin.HandlerFunc) routes {
+	r.group = r.group.Group(relativePath, handlers...)
+	return r
+}
+
+// route 已注册的路由，可继续登记处理器内判断的权限
+type route struct {
+	guard *RouteGuard
+	table *RouteTable
+}
+
+// checks 登记处理器内判断的其他权限（如有该权限时放宽限制）
+func (r route) checks(perms ...permission.Permission) {
+	r.guard.Checks = append(r.guard.Checks, r.table.reference(perms...)...)
+}
+
+// public 无需登录的路由
+func (r routes) public(method, relativePath string, handlers ...gin.HandlerFunc) route {
+	return r.handle(method, relativePath, GuardPublic, nil, handlers)
+}
+
+// partial 两步验证流程的路由（handlers 须以 PartialAuthMiddleware 开头）
+func (r routes) partial(method, relativePath string, handlers ...gin.HandlerFunc) route {
+	return r.handle(method, relativePath, GuardPartial, nil, handlers)
+}
+
+// login 登录即可访问的路由
+func (r routes) login(method, relativePath string, handlers ...gin.HandlerFunc) route {
+	return r.handle(method, relativePath, GuardLogin, nil, handlers)
+}
+
+// require 须拥有权限 p 的路由
+func (r routes) require(method, relativePath string, p permission.Permission, handlers ...gin.HandlerFunc) route {
+	return r.handle(method, relativePath, GuardPermission, &p, handlers)
+}
+
+func (r routes) handle(method, relativePath, guard string, p *permission.Permission, handlers []gin.HandlerFunc) route {
+	var chain []gin.HandlerFunc
+	if guard != GuardPublic && guard != GuardPartial && !r.authenticated {
+		chain = append(chain, middleware.AuthMiddleware())
+	}
+	guarded := &RouteGuard{Method: method, Path: joinPath(r.group.BasePath(), relativePath), Guard: guard}
+	if p != nil {
+		guarded.Permission = r.table.reference(*p)[0]
+		chain = append(chain, middleware.PermissionMiddleware(p.Code))
+	}
+	if guard != GuardPublic && guard != GuardPartial && len(r.checks) > 0 {
+		guarded.Checks = r.table.reference(r.checks...)
+	}
+	r.group.Handle(method, relativePath, append(chain, handlers...)...)
+	r.table.routes = append(r.table.routes, guarded)
+	return route{guard: guarded, table: r.table}
+}
+
+// joinPath 与 gin 计算完整路径的方式一致
+func joinPath(base, relativePath string) string {
+	if relativePath == "" {
+		return base
+	}
+	joined := path.Join(base, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		return joined + "/"
+	}
+	return joined
+}
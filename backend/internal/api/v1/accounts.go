@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkAccountRequest 批量开通账号请求
+type BulkAccountRequest struct {
+	Students       *bool  `json:"students"`        // 为学生开通，默认 true
+	Parents        *bool  `json:"parents"`         // 为家长开通，默认 true
+	StudentPattern string `json:"student_pattern"` // 学生用户名模板，默认 {student_code}
+	ParentPattern  string `json:"parent_pattern"`  // 家长用户名模板，默认 {student_code}_p{seq}
+	PasswordLength int    `json:"password_length" binding:"omitempty,min=8,max=32"`
+}
+
+// AdminProvisionClassAccounts 为班级学生及其家长批量开通账号
+// 响应中不含明文密码，初始密码清单需凭 sheet_token 下载，且只能下载一次
+func AdminProvisionClassAccounts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req BulkAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	if !middleware.GetDataScope(c).Allows(db, "classes", uint(id), true) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权操作该班级"})
+		return
+	}
+
+	opts := service.BulkAccountOptions{
+		ClassID:        uint(id),
+		Students:       req.Students == nil || *req.Students,
+		Parents:        req.Parents == nil || *req.Parents,
+		StudentPattern: req.StudentPattern,
+		ParentPattern:  req.ParentPattern,
+		PasswordLength: req.PasswordLength,
+	}
+	result, err := service.ProvisionClassAccounts(db, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrRoleMissing) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "开通账号失败", "error": err.Error()})
+		return
+	}
+
+	data := gin.H{"created": result.Created, "skipped": result.Skipped}
+	if len(result.Created) > 0 {
+		token, expires, err := service.StoreCredentialSheet(db, result.Created)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成密码清单失败", "error": err.Error()})
+			return
+		}
+		data["sheet_token"] = token
+		data["sheet_expires_at"] = expires
+		data["sheet_url"] = "/api/v1/admin/credential-sheets/" + token
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": fmt.Sprintf("已开通 %d 个账号", len(result.Created)), "data": data})
+}
+
+// AdminDownloadCredentialSheet 下载初始密码清单（CSV），下载后清单即销毁
+func AdminDownloadCredentialSheet(c *gin.Context) {
+	rows, err := service.TakeCredentialSheet(config.GetDB(), c.Param("token"))
+	if err != nil {
+		if errors.Is(err, service.ErrCredentialSheetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "读取密码清单失败", "error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF") // UTF-8 BOM，便于 Excel 正确识别中文
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"类型", "姓名", "学号", "用户名", "初始密码"})
+	for _, r := range rows {
+		userType := "学生"
+		if r.UserType == "parent" {
+			userType = "家长"
+		}
+		w.Write([]string{userType, r.Name, r.StudentCode, r.Username, r.Password})
+	}
+	w.Flush()
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", "attachment; filename=credentials.csv")
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
@@ -1,60 +1,37 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"student-management-system/config"
+	"student-management-system/internal/app"
 	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// 定义系统中所有可用的权限
-var AllPermissions = []models.Permission{
-	// 管理员权限
-	{Name: "查看用户", Permission: "admin:user:read", Group: "admin"},
-	{Name: "创建用户", Permission: "admin:user:create", Group: "admin"},
-	{Name: "修改用户", Permission: "admin:user:update", Group: "admin"},
-	{Name: "删除用户", Permission: "admin:user:delete", Group: "admin"},
-	{Name: "查看角色", Permission: "admin:role:read", Group: "admin"},
-	{Name: "创建角色", Permission: "admin:role:create", Group: "admin"},
-	{Name: "修改角色", Permission: "admin:role:update", Group: "admin"},
-	{Name: "删除角色", Permission: "admin:role:delete", Group: "admin"},
-	{Name: "查看统计", Permission: "admin:stats:read", Group: "admin"},
-}
-
 // AdminListPermissions 获取所有可用的权限列表
+// 权限表由路由表在启动时同步（见 app.SyncPermissions），已不被任何路由引用的权限带有 obsolete 标记
 func AdminListPermissions(c *gin.Context) {
 	db := config.GetDB()
 
-	// 从数据库获取所有权限（如果数据库中没有，则初始化）
 	var permissions []models.Permission
-	if err := db.Find(&permissions).Error; err != nil {
+	if err := db.Order("id ASC").Find(&permissions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
 		return
 	}
 
-	// 如果数据库中没有权限，则初始化
-	if len(permissions) == 0 {
-		for _, perm := range AllPermissions {
-			var existing models.Permission
-			if err := db.Where("permission = ?", perm.Permission).First(&existing).Error; err != nil {
-				// 权限不存在，创建它
-				db.Create(&perm)
-				permissions = append(permissions, perm)
-			} else {
-				permissions = append(permissions, existing)
-			}
-		}
-		// 重新查询
-		db.Find(&permissions)
-	}
-
-	// 按分组组织权限
+	// 按分组组织权限（不含已废弃的权限）
 	grouped := make(map[string][]models.Permission)
 	for _, perm := range permissions {
-		grouped[perm.Group] = append(grouped[perm.Group], perm)
+		if !perm.Obsolete {
+			grouped[perm.Group] = append(grouped[perm.Group], perm)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -67,7 +44,7 @@ func AdminListPermissions(c *gin.Context) {
 	})
 }
 
-// AdminGetRolePermissions 获取特定角色的权限列表
+// AdminGetRolePermissions 获取特定角色的权限列表（本角色直接设置的授权与拒绝，不含继承的权限和已废弃的权限）
 func AdminGetRolePermissions(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -78,15 +55,15 @@ func AdminGetRolePermissions(c *gin.Context) {
 
 	db := config.GetDB()
 	var role models.Role
-	if err := db.Preload("Permissions").First(&role, id).Error; err != nil {
+	if err := db.First(&role, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "角色不存在"})
 		return
 	}
-
-	// 返回权限标识列表
-	permissions := make([]string, 0)
-	for _, perm := range role.Permissions {
-		permissions = append(permissions, perm.Permission)
+	// 权限标识列表（精确授权在前，通配符在后）与拒绝列表
+	grants, err := service.LoadRoleGrants(db, role.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -95,14 +72,17 @@ func AdminGetRolePermissions(c *gin.Context) {
 		"data": gin.H{
 			"role_id":     role.ID,
 			"role_name":   role.RoleName,
-			"permissions": permissions,
+			"parent_id":   role.ParentID,
+			"permissions": grants.Permissions,
+			"deny":        grants.Deny,
 		},
 	})
 }
 
 // UpdateRolePermissionsRequest 更新角色权限的请求体
 type UpdateRolePermissionsRequest struct {
-	Permissions []string `json:"permissions" binding:"required"` // 权限标识数组
+	Permissions []string  `json:"permissions" binding:"required"` // 授权的权限标识或通配符（如 admin:*、db:grades:*）
+	Deny        *[]string `json:"deny"`                           // 显式拒绝的权限标识或通配符，优先于本角色和上级角色的任何授权；省略时保持不变
 }
 
 // AdminUpdateRolePermissions 更新特定角色的权限
@@ -129,31 +109,188 @@ func AdminUpdateRolePermissions(c *gin.Context) {
 		return
 	}
 
-	// 验证权限标识是否存在
-	var permissions []models.Permission
-	if err := db.Where("permission IN ?", req.Permissions).Find(&permissions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询权限失败", "error": err.Error()})
+	// 更新角色的授权与通配符、拒绝规则（省略 deny 时保留原有的拒绝规则）
+	// 修改后不能没有可用的管理员账号
+	err = service.WithAdminGuard(db, func(tx *gorm.DB) error {
+		return service.SetRoleGrants(tx, role.ID, req.Permissions, req.Deny)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidGrant) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新权限失败", "error": err.Error()})
 		return
 	}
+	// 权限缓存立即失效（含继承该角色的下级角色），修改对后续请求即时生效
+	app.Permissions.Invalidate(role.ID)
 
-	if len(permissions) != len(req.Permissions) {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "部分权限标识不存在"})
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新成功",
+		"data": gin.H{
+			"role_id":     role.ID,
+			"role_name":   role.RoleName,
+			"permissions": req.Permissions,
+			"deny":        req.Deny,
+		},
+	})
+}
+
+// AdminGetRolePermissionDiff 比较内置角色当前的授权与默认权限清单（见 internal/permission/defaults.go）
+func AdminGetRolePermissionDiff(c *gin.Context) {
+	role, defaults, ok := roleWithDefaults(c)
+	if !ok {
 		return
 	}
+	db := config.GetDB()
+	grants, err := service.LoadRoleGrants(db, role.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	diff := service.DiffRoleGrants(grants, defaults)
 
-	// 更新角色的权限（使用 Association 替换）
-	if err := db.Model(&role).Association("Permissions").Replace(permissions); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新权限失败", "error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"role_id":   role.ID,
+			"role_name": role.RoleName,
+			"current":   grants,
+			"defaults":  service.RoleGrants{Permissions: defaults.Allow, Deny: defaults.Deny},
+			"missing":   diff.Missing,
+			"extra":     diff.Extra,
+			"in_sync":   diff.Empty(),
+		},
+	})
+}
+
+// AdminResetRolePermissions 把内置角色的授权与拒绝恢复为默认权限清单（不改变上级角色）
+func AdminResetRolePermissions(c *gin.Context) {
+	role, defaults, ok := roleWithDefaults(c)
+	if !ok {
+		return
+	}
+	db := config.GetDB()
+	err := service.WithAdminGuard(db, func(tx *gorm.DB) error {
+		return service.ResetRoleToDefaults(tx, role.ID, defaults)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidGrant) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "重置权限失败", "error": err.Error()})
 		return
 	}
+	app.Permissions.Invalidate(role.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "更新成功",
+		"message": "已恢复默认权限",
 		"data": gin.H{
 			"role_id":     role.ID,
 			"role_name":   role.RoleName,
-			"permissions": req.Permissions,
+			"permissions": defaults.Allow,
+			"deny":        defaults.Deny,
+		},
+	})
+}
+
+// roleWithDefaults 查询路径参数中的角色及其默认权限清单，失败时已写入响应
+func roleWithDefaults(c *gin.Context) (models.Role, permission.RoleDefault, bool) {
+	var role models.Role
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return role, permission.RoleDefault{}, false
+	}
+	if err := config.GetDB().First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "角色不存在"})
+		return role, permission.RoleDefault{}, false
+	}
+	defaults, ok := permission.DefaultsFor(role.RoleName)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "角色 " + role.RoleName + " 没有默认权限清单"})
+		return role, permission.RoleDefault{}, false
+	}
+	return role, defaults, true
+}
+
+// EffectivePermission 用户的一项有效权限及其来源
+type EffectivePermission struct {
+	Permission string                   `json:"permission"`
+	Name       string                   `json:"name"`
+	Group      string                   `json:"group"`
+	Granted    bool                     `json:"granted"`   // 授权且未被拒绝
+	Source     *service.PermissionGrant `json:"source"`    // 匹配的授权（继承链上最近的一条），没有授权时为 null
+	DeniedBy   *service.PermissionGrant `json:"denied_by"` // 匹配的拒绝，没有拒绝时为 null
+}
+
+// AdminGetUserEffectivePermissions 查看用户的有效权限及每项权限的来源（直接从数据库计算，不经过缓存）
+// 可用 ?permission=xxx 只查看一项权限（可以是不在权限表中的标识）
+func AdminGetUserEffectivePermissions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.Preload("Role").First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	set, err := service.LoadPermissionSet(db, user.RoleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	var catalog []models.Permission
+	if p := c.Query("permission"); p != "" {
+		catalog = append(catalog, models.Permission{Permission: p})
+		db.Where("permission = ?", p).Limit(1).Find(&catalog[0])
+	} else if err := db.Where("obsolete = ?", false).Order("`group` ASC, id ASC").Find(&catalog).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	permissions := make([]EffectivePermission, 0, len(catalog))
+	for _, perm := range catalog {
+		allow, deny := set.Explain(perm.Permission)
+		permissions = append(permissions, EffectivePermission{
+			Permission: perm.Permission,
+			Name:       perm.Name,
+			Group:      perm.Group,
+			Granted:    allow != nil && deny == nil,
+			Source:     allow,
+			DeniedBy:   deny,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"user_id":     user.ID,
+			"username":    user.Username,
+			"is_active":   user.IsActive, // 已禁用的用户无法登录，但仍按角色显示权限
+			"role_id":     user.RoleID,
+			"role_name":   user.Role.RoleName,
+			"roles":       set.Roles(),  // 继承链，角色本身在前
+			"grants":      set.Grants(), // 继承链上的全部授权与拒绝规则
+			"permissions": permissions,
 		},
 	})
 }
@@ -1,17 +1,29 @@
 package v1
 
 import (
+    "encoding/json"
+    "errors"
     "net/http"
     "strconv"
 
     "student-management-system/config"
+    "student-management-system/internal/app"
     "student-management-system/internal/models"
+    "student-management-system/internal/service"
 
     "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
 )
 
 type CreateRoleRequest struct {
     RoleName string `json:"role_name" binding:"required"`
+    ParentID *uint  `json:"parent_id"` // 继承的上级角色，省略或为 null 表示不继承
+}
+
+// UpdateRoleRequest 更新角色的请求体；parent_id 省略时保持原上级角色不变，为 null 时取消继承
+type UpdateRoleRequest struct {
+    RoleName string          `json:"role_name" binding:"required"`
+    ParentID json.RawMessage `json:"parent_id"` // 保留原始值以区分省略与 null
 }
 
 // AdminListRoles 角色列表（分页）
@@ -60,15 +72,23 @@ func AdminCreateRole(c *gin.Context) {
         return
     }
 
-    role := models.Role{RoleName: req.RoleName}
-    if err := config.GetDB().Create(&role).Error; err != nil {
+    db := config.GetDB()
+    if req.ParentID != nil {
+        if err := service.ValidateRoleParent(db, 0, *req.ParentID); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+            return
+        }
+    }
+
+    role := models.Role{RoleName: req.RoleName, ParentID: req.ParentID}
+    if err := db.Create(&role).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建失败", "error": err.Error()})
         return
     }
     c.JSON(http.StatusOK, gin.H{"code": 200, "message": "创建成功", "data": role})
 }
 
-// AdminUpdateRole 更新角色名
+// AdminUpdateRole 更新角色名与上级角色
 func AdminUpdateRole(c *gin.Context) {
     idStr := c.Param("id")
     id, err := strconv.Atoi(idStr)
@@ -76,7 +96,7 @@ func AdminUpdateRole(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
         return
     }
-    var req CreateRoleRequest
+    var req UpdateRoleRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
         return
@@ -88,10 +108,35 @@ func AdminUpdateRole(c *gin.Context) {
         return
     }
     role.RoleName = req.RoleName
-    if err := db.Save(&role).Error; err != nil {
+    if len(req.ParentID) > 0 {
+        // 请求中出现了 parent_id：null 取消继承，否则更换上级角色
+        var parentID *uint
+        if err := json.Unmarshal(req.ParentID, &parentID); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "parent_id 须为角色ID或 null"})
+            return
+        }
+        if parentID != nil {
+            if err := service.ValidateRoleParent(db, role.ID, *parentID); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+                return
+            }
+        }
+        role.ParentID = parentID
+    }
+    // 更换上级角色可能使管理员失去继承的权限，修改后不能没有可用的管理员账号
+    err = service.WithAdminGuard(db, func(tx *gorm.DB) error {
+        return tx.Save(&role).Error
+    })
+    if errors.Is(err, service.ErrLastAdmin) {
+        c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+        return
+    }
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新失败", "error": err.Error()})
         return
     }
+    // 上级角色可能变化，该角色及其下级角色的权限缓存失效
+    app.Permissions.Invalidate(role.ID)
     c.JSON(http.StatusOK, gin.H{"code": 200, "message": "更新成功", "data": role})
 }
 
@@ -103,10 +148,30 @@ func AdminDeleteRole(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
         return
     }
-    if err := config.GetDB().Delete(&models.Role{}, id).Error; err != nil {
+    db := config.GetDB()
+    // 仍被其他角色继承时不能删除，否则下级角色会静默失去继承的权限
+    var children int64
+    if err := db.Model(&models.Role{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除失败", "error": err.Error()})
+        return
+    }
+    if children > 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "有角色继承该角色，请先修改其上级角色"})
+        return
+    }
+    // 删除角色后其用户不再有任何权限，删除后不能没有可用的管理员账号
+    err = service.WithAdminGuard(db, func(tx *gorm.DB) error {
+        return tx.Delete(&models.Role{}, id).Error
+    })
+    if errors.Is(err, service.ErrLastAdmin) {
+        c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+        return
+    }
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除失败", "error": err.Error()})
         return
     }
+    app.Permissions.Invalidate(uint(id))
     c.JSON(http.StatusOK, gin.H{"code": 200, "message": "删除成功"})
 }
 
@@ -1,14 +1,19 @@
 package v1
 
 import (
+    "errors"
     "net/http"
     "strconv"
+    "strings"
 
     "student-management-system/config"
+    "student-management-system/internal/app"
     "student-management-system/internal/models"
+    "student-management-system/internal/service"
     "student-management-system/internal/utils"
 
     "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
 )
 
 type CreateUserRequest struct {
@@ -16,12 +21,25 @@ type CreateUserRequest struct {
     Password string `json:"password" binding:"required"`
     RoleID   uint   `json:"role_id" binding:"required"`
     IsActive *bool  `json:"is_active"`
+    // 首次登录是否须修改密码，默认 true
+    MustChangePassword *bool `json:"must_change_password"`
+
+    // 关联档案（可选，二选一）：profile_id 关联已有档案，或按用户类型提供 student/teacher/parent 同时创建档案
+    ProfileID uint            `json:"profile_id"`
+    Student   *models.Student `json:"student"`
+    Teacher   *models.Teacher `json:"teacher"`
+    Parent    *models.Parent  `json:"parent"`
 }
 
 type UpdateUserRequest struct {
-    Password *string `json:"password"`
-    RoleID   *uint   `json:"role_id"`
-    IsActive *bool   `json:"is_active"`
+    Password  *string `json:"password"`
+    RoleID    *uint   `json:"role_id"`
+    IsActive  *bool   `json:"is_active"`
+    ProfileID *uint   `json:"profile_id"` // 重新关联档案，0 表示解除关联
+    // 下次登录是否须修改密码；重置密码时默认 true
+    MustChangePassword *bool `json:"must_change_password"`
+    // 认证方式顺序（逗号分隔，如 "ldap,local"），空字符串表示按用户类型或默认顺序
+    AuthMethods *string `json:"auth_methods"`
 }
 
 // AdminListUsers 列出用户（分页、可筛选）
@@ -77,6 +95,10 @@ func AdminCreateUser(c *gin.Context) {
         return
     }
 
+    if err := app.PasswordPolicy.Validate(req.Username, req.Password); err != nil {
+        respondProvisionError(c, err, "创建失败")
+        return
+    }
     hashed, err := utils.HashPassword(req.Password)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "密码处理失败"})
@@ -112,9 +134,28 @@ func AdminCreateUser(c *gin.Context) {
     if req.IsActive != nil {
         user.IsActive = *req.IsActive
     }
+    // 管理员设置的密码默认要求用户首次登录后修改
+    user.MustChangePassword = req.MustChangePassword == nil || *req.MustChangePassword
+
+    // 新建档案
+    var profile interface{}
+    count := 0
+    if req.Student != nil {
+        profile, count = req.Student, count+1
+    }
+    if req.Teacher != nil {
+        profile, count = req.Teacher, count+1
+    }
+    if req.Parent != nil {
+        profile, count = req.Parent, count+1
+    }
+    if count > 1 || (count == 1 && req.ProfileID > 0) {
+        c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "只能关联一个档案"})
+        return
+    }
 
-    if err := config.GetDB().Create(&user).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建失败", "error": err.Error()})
+    if err := service.CreateAccount(config.GetDB(), &user, req.ProfileID, profile); err != nil {
+        respondProvisionError(c, err, "创建失败")
         return
     }
 
@@ -142,14 +183,18 @@ func AdminUpdateUser(c *gin.Context) {
         return
     }
 
+    mustChange := user.MustChangePassword
     if req.Password != nil {
-        hashed, err := utils.HashPassword(*req.Password)
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "密码处理失败"})
+        if err := app.PasswordPolicy.Validate(user.Username, *req.Password); err != nil {
+            respondProvisionError(c, err, "更新失败")
             return
         }
-        user.Password = hashed
+        mustChange = true
     }
+    if req.MustChangePassword != nil {
+        mustChange = *req.MustChangePassword
+    }
+    userType, roleID, wasActive := user.UserType, user.RoleID, user.IsActive
     if req.RoleID != nil {
         user.RoleID = *req.RoleID
         // 更新角色时，同时更新 UserType
@@ -170,11 +215,51 @@ func AdminUpdateUser(c *gin.Context) {
     if req.IsActive != nil {
         user.IsActive = *req.IsActive
     }
+    if req.AuthMethods != nil {
+        if err := app.Authenticators.ValidateMethods(*req.AuthMethods); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+            return
+        }
+        user.AuthMethods = strings.Join(service.ParseAuthMethods(*req.AuthMethods), ",")
+    }
 
-    if err := db.Save(&user).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新失败", "error": err.Error()})
+    // 账号字段与档案关联在同一事务中更新；用户类型变化时原档案不再适用，先解除关联
+    // 禁用账号或变更角色后不能没有可用的管理员账号
+    err = service.WithAdminGuard(db, func(tx *gorm.DB) error {
+        if user.UserType != userType {
+            if err := service.UnlinkProfile(tx, user.ID); err != nil {
+                return err
+            }
+        }
+        if req.Password != nil {
+            if err := service.SetPassword(tx, &user, *req.Password, app.PasswordPolicy, mustChange); err != nil {
+                return err
+            }
+        }
+        user.MustChangePassword = mustChange
+        // user_id 由档案关联维护，token_version 由令牌撤销维护，都不随账号字段保存
+        if err := tx.Omit("user_id", "token_version").Save(&user).Error; err != nil {
+            return err
+        }
+        // 禁用账号或变更角色后，已签发的令牌立即失效
+        if (wasActive && !user.IsActive) || user.RoleID != roleID {
+            if err := service.RevokeUserTokens(tx, user.ID); err != nil {
+                return err
+            }
+        }
+        if req.ProfileID == nil {
+            return nil
+        }
+        if *req.ProfileID == 0 {
+            return service.UnlinkProfile(tx, user.ID)
+        }
+        return service.LinkProfile(tx, user.ID, *req.ProfileID)
+    })
+    if err != nil {
+        respondProvisionError(c, err, "更新失败")
         return
     }
+    db.First(&user, user.ID)
 
     c.JSON(http.StatusOK, gin.H{"code": 200, "message": "更新成功", "data": user})
 }
@@ -187,11 +272,41 @@ func AdminDeleteUser(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
         return
     }
-    if err := config.GetDB().Delete(&models.User{}, id).Error; err != nil {
+    // 不能删除最后一个可用的管理员账号（包括自己）
+    err = service.WithAdminGuard(config.GetDB(), func(tx *gorm.DB) error {
+        return service.DeleteAccount(tx, uint(id))
+    })
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+            return
+        }
+        if errors.Is(err, service.ErrLastAdmin) {
+            c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除失败", "error": err.Error()})
         return
     }
     c.JSON(http.StatusOK, gin.H{"code": 200, "message": "删除成功"})
 }
 
+// respondProvisionError 输出账号开通失败（档案关联、密码策略）的响应
+func respondProvisionError(c *gin.Context, err error, message string) {
+    var policyErr *service.PasswordPolicyError
+    switch {
+    case errors.Is(err, service.ErrProfileAlreadyBound), errors.Is(err, service.ErrLastAdmin):
+        c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+    case errors.Is(err, service.ErrProfileNotFound):
+        c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+    case errors.Is(err, service.ErrNoProfileType), errors.Is(err, service.ErrProfileTypeMismatch),
+        errors.Is(err, service.ErrPasswordReused):
+        c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+    case errors.As(err, &policyErr):
+        c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error(), "data": gin.H{"violations": policyErr.Violations}})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": message, "error": err.Error()})
+    }
+}
+
 
@@ -0,0 +1,201 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AlertRuleRequest 修改预警规则请求
+type AlertRuleRequest struct {
+	Name       string  `json:"name"`
+	Threshold  float64 `json:"threshold" binding:"min=0"`
+	WindowDays int     `json:"window_days" binding:"min=0"`
+	Severity   string  `json:"severity" binding:"required,oneof=info warning critical"`
+	Enabled    bool    `json:"enabled"`
+}
+
+// AlertStatusRequest 更新预警处理状态请求
+type AlertStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=open acknowledged resolved"`
+}
+
+// AdminListAlertRules 预警规则列表
+func AdminListAlertRules(c *gin.Context) {
+	var rules []models.AlertRule
+	if err := config.GetDB().Order("id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": rules, "total": len(rules)}})
+}
+
+// AdminUpdateAlertRule 修改预警规则的阈值、统计范围、级别与启用状态
+func AdminUpdateAlertRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var rule models.AlertRule
+	if err := db.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "预警规则不存在"})
+		return
+	}
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	rule.Threshold = req.Threshold
+	rule.WindowDays = req.WindowDays
+	rule.Severity = req.Severity
+	rule.Enabled = req.Enabled
+	if err := db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "更新成功", "data": rule})
+}
+
+// AdminRunAlerts 立即执行一次预警规则
+func AdminRunAlerts(c *gin.Context) {
+	result, err := service.RunAlerts(config.GetDB(), service.DefaultGradeScale)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "执行预警失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "执行完成", "data": result})
+}
+
+// AdminListAlerts 预警记录列表（支持按状态、规则、学生、班主任筛选）
+func AdminListAlerts(c *gin.Context) {
+	query, ok := middleware.GetDataScope(c).Apply(config.GetDB().Model(&models.AcademicAlert{}), "academic_alerts", false)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权访问"})
+		return
+	}
+	if teacherID := c.Query("teacher_id"); teacherID != "" {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	listAlerts(c, query)
+}
+
+// AdminUpdateAlertStatus 更新预警处理状态
+func AdminUpdateAlertStatus(c *gin.Context) {
+	scope, ok := middleware.GetDataScope(c).Apply(config.GetDB(), "academic_alerts", true)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权操作"})
+		return
+	}
+	updateAlertStatus(c, scope)
+}
+
+// GetAssignedAlerts 班主任查看指派给自己的预警
+func GetAssignedAlerts(c *gin.Context) {
+	db := config.GetDB()
+	teacherID := currentTeacherID(c, db)
+	if teacherID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "仅教师可查看"})
+		return
+	}
+	listAlerts(c, db.Model(&models.AcademicAlert{}).Where("teacher_id = ?", teacherID))
+}
+
+// UpdateAssignedAlertStatus 班主任更新指派给自己的预警的处理状态
+func UpdateAssignedAlertStatus(c *gin.Context) {
+	db := config.GetDB()
+	teacherID := currentTeacherID(c, db)
+	if teacherID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "仅教师可操作"})
+		return
+	}
+	updateAlertStatus(c, db.Where("teacher_id = ?", teacherID))
+}
+
+// currentTeacherID 当前登录用户关联的教师ID，非教师账号返回 0
+func currentTeacherID(c *gin.Context, db *gorm.DB) uint {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil || user.UserType != "teacher" {
+		return 0
+	}
+	return user.UserID
+}
+
+// listAlerts 在给定范围内分页查询预警记录
+func listAlerts(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if ruleCode := c.Query("rule_code"); ruleCode != "" {
+		query = query.Where("rule_code = ?", ruleCode)
+	}
+	if studentID := c.Query("student_id"); studentID != "" {
+		query = query.Where("student_id = ?", studentID)
+	}
+
+	var total int64
+	var alerts []models.AcademicAlert
+	if err := query.Count(&total).Preload("Student").Order("id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"list":      alerts,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// updateAlertStatus 在给定范围内更新一条预警的状态，范围外的记录视为不存在
+func updateAlertStatus(c *gin.Context, scope *gorm.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req AlertStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	var alert models.AcademicAlert
+	if err := scope.Where("id = ?", id).First(&alert).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "预警不存在"})
+		return
+	}
+	if err := config.GetDB().Model(&alert).Update("status", req.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "更新成功"})
+}
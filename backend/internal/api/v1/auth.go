@@ -1,13 +1,22 @@
 package v1
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"student-management-system/config"
+	"student-management-system/internal/app"
 	"student-management-system/internal/models"
+	"student-management-system/internal/service"
 	"student-management-system/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // LoginRequest 登录请求
@@ -35,28 +44,70 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 查询用户
-	var user models.User
 	db := config.GetDB()
-	if err := db.Where("username = ?", req.Username).Preload("Role").First(&user).Error; err != nil {
+	ip := c.ClientIP()
+	audit := func(userID uint, result string) {
+		recordLoginAudit(c, db, req.Username, userID, result)
+	}
+	fail := func(userID uint, result string) {
+		if err := service.RecordLoginFailure(db, app.LoginPolicy, req.Username, ip); err != nil {
+			log.Printf("记录登录失败次数失败: %v", err)
+		}
+		audit(userID, result)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code":    401,
 			"message": "用户名或密码错误",
 		})
+	}
+
+	// 暴力破解防护：用户名或 IP 失败过多时暂时拒绝登录（不再校验密码）
+	if !loginAllowed(c, db, req.Username) {
 		return
 	}
 
-	// 验证密码
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户名或密码错误",
+	// 按用户适用的顺序依次尝试各认证方式（本地密码、LDAP 等）
+	user, err := app.Authenticators.Authenticate(db, req.Username, req.Password)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrBadCredentials):
+		if user == nil {
+			fail(0, service.LoginUnknownUser)
+		} else {
+			fail(user.ID, service.LoginBadPassword)
+		}
+		return
+	case errors.Is(err, service.ErrAuthUnavailable):
+		// 无法确认密码是否正确，不计入失败次数
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrLDAPNoAccount):
+		audit(0, service.LoginUnknownUser)
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": err.Error(),
+		})
+		return
+	default:
+		log.Printf("登录认证失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "登录失败",
 		})
 		return
 	}
 
+	continueLogin(c, db, user, req.Username)
+}
+
+// continueLogin 第一步认证（密码或统一身份认证）通过后：检查账号状态，按需进入两步验证，否则完成登录
+// loginName 为用于审计和失败限制的登录名
+func continueLogin(c *gin.Context, db *gorm.DB, user *models.User, loginName string) {
 	// 检查用户状态
 	if !user.IsActive {
+		recordLoginAudit(c, db, loginName, user.ID, service.LoginInactive)
 		c.JSON(http.StatusForbidden, gin.H{
 			"code":    403,
 			"message": "用户已被禁用",
@@ -64,8 +115,101 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 生成token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.RoleID)
+	// 已启用两步验证：返回只能用于提交验证码的临时令牌
+	if user.TOTPEnabled {
+		respondPartialLogin(c, user, utils.PurposeTwoFactor, "two_factor_required", "请输入两步验证码")
+		return
+	}
+	// 所在角色要求两步验证但尚未启用：返回只能用于设置两步验证的临时令牌，设置时还须提交管理员签发的设置码，
+	// 只知道密码不能自行设置
+	required, err := service.TwoFactorRequired(db, user.RoleID, config.TwoFactorRequiredPermissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "登录失败",
+		})
+		return
+	}
+	if required {
+		respondPartialLogin(c, user, utils.PurposeTwoFactorEnroll, "two_factor_setup_required", "所在角色要求启用两步验证，请使用管理员签发的设置码完成设置")
+		return
+	}
+
+	if err := service.RecordLoginSuccess(db, loginName); err != nil {
+		log.Printf("清除登录失败次数失败: %v", err)
+	}
+	recordLoginAudit(c, db, loginName, user.ID, service.LoginSuccess)
+	completeLogin(c, db, user, "登录成功", nil)
+}
+
+// respondPartialLogin 密码验证通过但还须两步验证时，签发临时令牌（flag 告知前端下一步）
+func respondPartialLogin(c *gin.Context, user *models.User, purpose, flag, message string) {
+	token, err := utils.GenerateToken(utils.Claims{
+		UserID:       user.ID,
+		Username:     user.Username,
+		RoleID:       user.RoleID,
+		TokenVersion: user.TokenVersion,
+		Purpose:      purpose,
+	}, config.PartialTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Token生成失败",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": message,
+		"data": gin.H{
+			flag:            true,
+			"partial_token": token,
+			"expires_in":    int(config.PartialTokenTTL.Seconds()),
+		},
+	})
+}
+
+// loginAllowed 用户名或 IP 失败过多时返回 429（附 Retry-After）并记录审计
+func loginAllowed(c *gin.Context, db *gorm.DB, username string) bool {
+	err := service.CheckLoginAllowed(db, app.LoginPolicy, username, c.ClientIP())
+	if err == nil {
+		return true
+	}
+	var blocked *service.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "登录失败",
+		})
+		return false
+	}
+	recordLoginAudit(c, db, username, 0, blocked.Result)
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code":    429,
+		"message": blocked.Error(),
+	})
+	return false
+}
+
+// recordLoginAudit 写入登录审计记录（失败只记日志，不影响登录）
+func recordLoginAudit(c *gin.Context, db *gorm.DB, username string, userID uint, result string) {
+	attempt := models.LoginAttempt{
+		Username:  username,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Result:    result,
+	}
+	if err := service.RecordLoginAttempt(db, attempt); err != nil {
+		log.Printf("记录登录审计失败: %v", err)
+	}
+}
+
+// completeLogin 创建登录会话并返回令牌、用户信息与权限列表（extra 中的字段一并返回）
+func completeLogin(c *gin.Context, db *gorm.DB, user *models.User, message string, extra map[string]interface{}) {
+	// 创建登录会话并生成令牌
+	tokens, err := issueTokens(c, db, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -76,17 +220,14 @@ func Login(c *gin.Context) {
 
 	// 获取权限列表
 	var permissionList []string
-	var role models.Role
-	if err := db.Preload("Permissions").First(&role, user.RoleID).Error; err == nil {
-		for _, perm := range role.Permissions {
-			permissionList = append(permissionList, perm.Permission)
-		}
+	if set, err := app.Permissions.Permissions(db, user.RoleID); err == nil {
+		permissionList = effectivePermissionList(db, set)
 	}
 
 	// 根据角色获取详细信息
 	response := LoginResponse{
-		Token: token,
-		User:  user,
+		Token: tokens.Token,
+		User:  *user,
 	}
 
 	// 根据用户类型和角色返回对应的详细信息
@@ -109,9 +250,11 @@ func Login(c *gin.Context) {
 		case "parent":
 			var parent models.Parent
 			if err := db.Preload("Student").Preload("Student.Class").First(&parent, user.UserID).Error; err == nil {
+				children, _ := service.GuardianChildren(db, parent.ID)
 				response.UserInfo = map[string]interface{}{
-					"parent":  parent,
-					"student": parent.Student, // 包含关联的学生信息
+					"parent":   parent,
+					"student":  parent.Student, // 包含关联的学生信息
+					"children": children,       // 监护的全部学生
 				}
 			}
 		}
@@ -119,19 +262,37 @@ func Login(c *gin.Context) {
 
 	// 将权限列表添加到响应中
 	responseData := map[string]interface{}{
-		"token":       response.Token,
-		"user":        response.User,
-		"user_info":   response.UserInfo,
-		"permissions": permissionList,
+		"token":              response.Token,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"user":               response.User,
+		"user_info":          response.UserInfo,
+		"permissions":        permissionList,
+
+		"must_change_password": user.MustChangePassword, // 为 true 时须先修改密码才能访问其他接口
+	}
+	for k, v := range extra {
+		responseData[k] = v
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "登录成功",
+		"message": message,
 		"data":    responseData,
 	})
 }
 
+// effectivePermissionList 有效权限展开为具体的权限标识列表（通配符按权限表展开，已拒绝的权限不在其中）
+func effectivePermissionList(db *gorm.DB, set *service.PermissionSet) []string {
+	catalog, err := service.PermissionCatalog(db)
+	if err != nil {
+		log.Printf("查询权限表失败: %v", err)
+		return nil
+	}
+	return set.Effective(catalog)
+}
+
 // GetCurrentUser 获取当前登录用户信息
 func GetCurrentUser(c *gin.Context) {
 	userID, _ := c.Get("user_id")
@@ -150,17 +311,11 @@ func GetCurrentUser(c *gin.Context) {
 	permissions, exists := c.Get("permissions")
 	var permissionList []string
 	if exists {
-		permissionMap := permissions.(map[string]bool)
-		for perm := range permissionMap {
-			permissionList = append(permissionList, perm)
-		}
+		permissionList = effectivePermissionList(db, permissions.(*service.PermissionSet))
 	} else {
 		// 如果context中没有，从数据库查询
-		var role models.Role
-		if err := db.Preload("Permissions").First(&role, user.RoleID).Error; err == nil {
-			for _, perm := range role.Permissions {
-				permissionList = append(permissionList, perm.Permission)
-			}
+		if set, err := app.Permissions.Permissions(db, user.RoleID); err == nil {
+			permissionList = effectivePermissionList(db, set)
 		}
 	}
 
@@ -168,6 +323,8 @@ func GetCurrentUser(c *gin.Context) {
 	response := map[string]interface{}{
 		"user":        user,
 		"permissions": permissionList,
+
+		"must_change_password": user.MustChangePassword, // 为 true 时须先修改密码才能访问其他接口
 	}
 
 	// 根据用户类型返回对应的详细信息
@@ -188,6 +345,9 @@ func GetCurrentUser(c *gin.Context) {
 			if err := db.Preload("Student").Preload("Student.Class").Preload("Student.Class.Teacher").First(&parent, user.UserID).Error; err == nil {
 				response["parent"] = parent
 				response["student"] = parent.Student // 包含关联的学生信息
+				if children, err := service.GuardianChildren(db, parent.ID); err == nil {
+					response["children"] = children // 监护的全部学生
+				}
 			}
 		}
 	}
@@ -199,20 +359,149 @@ func GetCurrentUser(c *gin.Context) {
 	})
 }
 
-// Logout 用户登出
+// Logout 用户登出：撤销当前会话，访问令牌和刷新令牌立即失效
+// 优先按请求体中的 refresh_token 撤销（访问令牌已过期时也能登出），否则按 Authorization 中访问令牌所属的会话撤销
 func Logout(c *gin.Context) {
-	// JWT是无状态的，登出通常在前端处理（删除token）
-	// 如果需要服务端登出，可以使用Redis黑名单机制
+	db := config.GetDB()
+
+	var req RefreshTokenRequest
+	if c.ShouldBindJSON(&req) == nil && req.RefreshToken != "" {
+		if err := service.RevokeSessionByRefreshToken(db, req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "登出失败",
+			})
+			return
+		}
+	} else if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+		if claims, err := utils.ParseToken(parts[1]); err == nil && claims.SessionID > 0 {
+			if err := service.RevokeSession(db, claims.SessionID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"code":    500,
+					"message": "登出失败",
+				})
+				return
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "登出成功",
 	})
 }
 
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenPair 登录或刷新后签发的令牌
+type TokenPair struct {
+	Token            string    `json:"token"`              // 访问令牌
+	RefreshToken     string    `json:"refresh_token"`      // 刷新令牌，每次使用后轮换
+	ExpiresIn        int       `json:"expires_in"`         // 访问令牌有效期（秒）
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"` // 刷新令牌过期时间
+}
+
+// issueTokens 为用户创建新会话（记录请求的 User-Agent 与 IP）并签发令牌
+func issueTokens(c *gin.Context, db *gorm.DB, user *models.User) (*TokenPair, error) {
+	client := service.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	session, refreshToken, err := service.CreateSession(db, user, client, config.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return sessionTokens(user, session, refreshToken)
+}
+
+// sessionTokens 为会话签发访问令牌
+func sessionTokens(user *models.User, session *models.Session, refreshToken string) (*TokenPair, error) {
+	token, err := utils.GenerateToken(utils.Claims{
+		UserID:       user.ID,
+		Username:     user.Username,
+		RoleID:       user.RoleID,
+		SessionID:    session.ID,
+		TokenVersion: user.TokenVersion,
+	}, config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int(config.AccessTokenTTL.Seconds()),
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// RefreshToken 用刷新令牌换取新的访问令牌和刷新令牌（旧刷新令牌随即作废）
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	db := config.GetDB()
+	session, user, refreshToken, err := service.RotateSession(db, req.RefreshToken, config.RefreshTokenTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrUserInactive):
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "刷新令牌失败",
+			})
+		}
+		return
+	}
+
+	// 会话建立后所在角色才被要求两步验证时，须重新登录并完成设置
+	if !user.TOTPEnabled {
+		required, err := service.TwoFactorRequired(db, user.RoleID, config.TwoFactorRequiredPermissions)
+		if err != nil || required {
+			_ = service.RevokeSession(db, session.ID)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "所在角色要求启用两步验证，请重新登录",
+				"data":    gin.H{"two_factor_setup_required": true},
+			})
+			return
+		}
+	}
+
+	tokens, err := sessionTokens(user, session, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Token生成失败",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "刷新成功",
+		"data":    tokens,
+	})
+}
+
 // UpdatePasswordRequest 修改密码请求
 type UpdatePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"` // 须符合密码策略（app.PasswordPolicy）
 }
 
 // UpdatePassword 修改密码
@@ -248,21 +537,36 @@ func UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	// 更新密码
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "密码加密失败",
-		})
+	// 按密码策略更新密码，同时清除须修改密码标记
+	if err := service.SetPassword(db, &user, req.NewPassword, app.PasswordPolicy, false); err != nil {
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+				"data":    gin.H{"violations": policyErr.Violations},
+			})
+		case errors.Is(err, service.ErrPasswordReused):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "密码更新失败",
+			})
+		}
 		return
 	}
 
-	user.Password = hashedPassword
-	if err := db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "密码更新失败",
+	// 修改密码会使所有会话失效，为当前客户端签发新的令牌
+	tokens, err := issueTokens(c, db, &user)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "密码修改成功，请重新登录",
 		})
 		return
 	}
@@ -270,5 +574,6 @@ func UpdatePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "密码修改成功",
+		"data":    tokens,
 	})
 }
@@ -1,12 +1,20 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // TableInfo 表信息
@@ -38,18 +46,29 @@ func GetTableList(c *gin.Context) {
 		{Name: "notifications", Label: "通知表"},
 		{Name: "schedules", Label: "课程表(排课)"},
 		{Name: "grade_audit_logs", Label: "成绩审计日志"},
+		{Name: "course_waitlists", Label: "课程候补表"},
+		{Name: "semesters", Label: "学期表"},
+		{Name: "grade_point_scales", Label: "绩点对照表"},
+		{Name: "alert_rules", Label: "学业预警规则"},
+		{Name: "academic_alerts", Label: "学业预警记录"},
 		{Name: "vw_class_performance", Label: "班级成绩视图", IsView: true},
 		{Name: "vw_student_full_profile", Label: "学生档案视图", IsView: true},
 	}
 
-	// 获取每个表的记录数
-	for i := range tables {
-		if !tables[i].IsView {
-			var count int64
-			db.Table(tables[i].Name).Count(&count)
-			tables[i].Count = count
+	// 获取每个表的记录数（受数据范围限制的用户只列出可访问的表，并按范围计数）
+	scope := middleware.GetDataScope(c)
+	visible := tables[:0]
+	for _, table := range tables {
+		query, ok := scope.Apply(db.Table(table.Name), table.Name, false)
+		if !ok {
+			continue
 		}
+		if !table.IsView {
+			query.Count(&table.Count)
+		}
+		visible = append(visible, table)
 	}
+	tables = visible
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -84,6 +103,8 @@ func GetTableData(c *gin.Context) {
 		"grades": true, "attendances": true, "reward_punishments": true,
 		"notifications": true, "schedules": true, "grade_audit_logs": true,
 		"vw_class_performance": true, "vw_student_full_profile": true,
+		"course_waitlists": true, "semesters": true, "grade_point_scales": true, "alert_rules": true,
+		"academic_alerts": true,
 	}
 
 	if !validTables[tableName] {
@@ -94,13 +115,22 @@ func GetTableData(c *gin.Context) {
 		return
 	}
 
+	scope := middleware.GetDataScope(c)
+	if _, ok := scope.Apply(db, tableName, false); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权访问该表"})
+		return
+	}
+
 	// 获取总数
 	var total int64
-	db.Table(tableName).Count(&total)
+	countQuery, _ := scope.Apply(db.Table(tableName), tableName, false)
+	countQuery.Count(&total)
 
 	// 获取数据
 	var results []map[string]interface{}
-	db.Table(tableName).Limit(pageSize).Offset(offset).Find(&results)
+	dataQuery, _ := scope.Apply(db.Table(tableName), tableName, false)
+	dataQuery.Limit(pageSize).Offset(offset).Find(&results)
+	stripSecretColumns(tableName, results)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -125,6 +155,8 @@ func CreateTableData(c *gin.Context) {
 		"classes": true, "courses": true, "enrollments": true,
 		"grades": true, "attendances": true, "reward_punishments": true,
 		"notifications": true, "schedules": true,
+		"course_waitlists": true, "semesters": true, "grade_point_scales": true, "alert_rules": true,
+		"academic_alerts": true,
 	}
 
 	if !validTables[tableName] {
@@ -143,9 +175,41 @@ func CreateTableData(c *gin.Context) {
 		})
 		return
 	}
+	stripDerivedColumns(tableName, data)
+	if touchesColumns(data, protectedColumns[tableName]) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "两步验证相关字段不能通过通用表接口修改"})
+		return
+	}
 
 	db := config.DB
-	if err := db.Table(tableName).Create(&data).Error; err != nil {
+	scope := middleware.GetDataScope(c)
+	if _, ok := scope.Apply(db, tableName, true); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权修改该表"})
+		return
+	}
+	err := writeTransaction(tableName, func(tx *gorm.DB) error {
+		if err := tx.Table(tableName).Create(&data).Error; err != nil {
+			return err
+		}
+		// 新记录必须落在调用者的数据范围内
+		var newID uint
+		if err := tx.Raw("SELECT LAST_INSERT_ID()").Scan(&newID).Error; err != nil {
+			return err
+		}
+		if !scope.Allows(tx, tableName, newID, true) {
+			return errOutOfScope
+		}
+		return nil
+	})
+	if errors.Is(err, errOutOfScope) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": err.Error(),
@@ -153,6 +217,8 @@ func CreateTableData(c *gin.Context) {
 		return
 	}
 
+	invalidatePermissionCache(tableName)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "创建成功",
@@ -175,8 +241,8 @@ func UpdateTableData(c *gin.Context) {
 	}
 
 	// 尝试将ID转换为整数以验证其有效性
-	_, err := strconv.Atoi(id)
-	if err != nil {
+	parsedID, err := strconv.Atoi(id)
+	if err != nil || parsedID <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
 			"message": "ID必须是有效的数字",
@@ -191,6 +257,8 @@ func UpdateTableData(c *gin.Context) {
 		"classes": true, "courses": true, "enrollments": true,
 		"grades": true, "attendances": true, "reward_punishments": true,
 		"notifications": true, "schedules": true,
+		"course_waitlists": true, "semesters": true, "grade_point_scales": true, "alert_rules": true,
+		"academic_alerts": true,
 	}
 
 	if !validTables[tableName] {
@@ -209,15 +277,57 @@ func UpdateTableData(c *gin.Context) {
 		})
 		return
 	}
+	stripDerivedColumns(tableName, data)
+	if touchesColumns(data, protectedColumns[tableName]) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "两步验证相关字段不能通过通用表接口修改"})
+		return
+	}
 
 	db := config.DB
-	if err := db.Table(tableName).Where("id = ?", id).Updates(data).Error; err != nil {
+	scope := middleware.GetDataScope(c)
+	recordID := uint(parsedID)
+	if !scope.Allows(db, tableName, recordID, true) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权修改该记录"})
+		return
+	}
+
+	courseID := seatCourseID(tableName, id)
+	err = writeTransaction(tableName, func(tx *gorm.DB) error {
+		query, _ := scope.Apply(tx.Table(tableName), tableName, true)
+		if err := query.Where("id = ?", id).Updates(data).Error; err != nil {
+			return err
+		}
+		// 修改后的记录仍须在数据范围内（例如不能把成绩挪到他人课程）
+		if !scope.Allows(tx, tableName, recordID, true) {
+			return errOutOfScope
+		}
+		// 变更账号的角色、启用状态、密码或令牌版本后，已签发的令牌立即失效（与用户管理接口一致）
+		if tableName == "users" && touchesColumns(data, tokenColumns) {
+			return service.RevokeUserTokens(tx, recordID)
+		}
+		// 课程调整学期后，已有选课记录随之调整（与学期管理接口一致）
+		if tableName == "courses" && touchesColumns(data, []string{"semester_id"}) {
+			return service.SyncEnrollmentSemesters(tx, recordID)
+		}
+		return nil
+	})
+	if errors.Is(err, errOutOfScope) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": err.Error(),
 		})
 		return
 	}
+	promoteWaitlist(courseID)
+	invalidatePermissionCache(tableName)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -240,8 +350,8 @@ func DeleteTableData(c *gin.Context) {
 	}
 
 	// 尝试将ID转换为整数以验证其有效性
-	_, err := strconv.Atoi(id)
-	if err != nil {
+	parsedID, err := strconv.Atoi(id)
+	if err != nil || parsedID <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
 			"message": "ID必须是有效的数字",
@@ -256,6 +366,8 @@ func DeleteTableData(c *gin.Context) {
 		"classes": true, "courses": true, "enrollments": true,
 		"grades": true, "attendances": true, "reward_punishments": true,
 		"notifications": true, "schedules": true,
+		"course_waitlists": true, "semesters": true, "grade_point_scales": true, "alert_rules": true,
+		"academic_alerts": true,
 	}
 
 	if !validTables[tableName] {
@@ -267,25 +379,49 @@ func DeleteTableData(c *gin.Context) {
 	}
 
 	db := config.DB
-
-	// 使用Exec执行删除操作
-	result := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", tableName), id)
-	if result.Error != nil {
+	scope := middleware.GetDataScope(c)
+	cond, condArgs, ok := scope.Condition(tableName, true)
+	if !ok || !scope.Allows(db, tableName, uint(parsedID), true) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权删除该记录"})
+		return
+	}
+	courseID := seatCourseID(tableName, id)
+
+	// 使用Exec执行删除操作（附带数据范围条件）
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE id = ?", tableName)
+	args := []interface{}{id}
+	if cond != "" {
+		deleteSQL += " AND (" + cond + ")"
+		args = append(args, condArgs...)
+	}
+	var deleted int64
+	err = writeTransaction(tableName, func(tx *gorm.DB) error {
+		result := tx.Exec(deleteSQL, args...)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if errors.Is(err, service.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": result.Error.Error(),
+			"message": err.Error(),
 		})
 		return
 	}
 
 	// 检查是否有记录被删除
-	if result.RowsAffected == 0 {
+	if deleted == 0 {
 		c.JSON(http.StatusNotFound, gin.H{
 			"code":    404,
 			"message": "记录不存在",
 		})
 		return
 	}
+	promoteWaitlist(courseID)
+	invalidatePermissionCache(tableName)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -293,6 +429,98 @@ func DeleteTableData(c *gin.Context) {
 	})
 }
 
+// guardedTables 修改后可能影响管理员账号的表（账号、角色、权限）
+var guardedTables = map[string]bool{"users": true, "roles": true, "permissions": true}
+
+// writeTransaction 在事务中执行通用表接口的写操作；账号、角色与权限表的修改在 service.WithAdminGuard 中执行，
+// 不能让系统失去最后一个可用的管理员账号（与用户、角色管理接口一致）
+func writeTransaction(tableName string, fn func(tx *gorm.DB) error) error {
+	if guardedTables[tableName] {
+		return service.WithAdminGuard(config.DB, fn)
+	}
+	return config.DB.Transaction(fn)
+}
+
+// invalidatePermissionCache 通过通用表接口修改角色或权限表后，角色权限缓存全部失效
+func invalidatePermissionCache(tableName string) {
+	if tableName == "roles" || tableName == "permissions" {
+		app.Permissions.InvalidateAll()
+	}
+}
+
+// errOutOfScope 写入后的记录超出调用者的数据范围
+var errOutOfScope = errors.New("记录超出本人的数据范围")
+
+// derivedColumns 由数据库触发器维护的派生字段，不允许通过通用表接口直接写入
+var derivedColumns = map[string][]string{
+	"courses": {"enrolled_count"},
+}
+
+// tokenColumns 修改后须使该用户已签发的令牌失效的 users 字段
+var tokenColumns = []string{"role_id", "is_active", "password", "user_type", "token_version", "deleted_at"}
+
+// secretColumns 密码哈希、两步验证密钥等凭据字段，通用表接口读取和导出时不返回
+var secretColumns = map[string][]string{
+	"users":             {"password", "totp_secret", "totp_enroll_code"},
+	"credential_sheets": {"token_hash", "data"},
+}
+
+// protectedColumns 只能通过两步验证接口修改的字段（启用、关闭、重置须经验证码或管理员重置接口）
+var protectedColumns = map[string][]string{
+	"users": {"totp_secret", "totp_enabled", "totp_last_step", "totp_enroll_code", "totp_enroll_expires_at"},
+}
+
+// stripSecretColumns 移除查询结果中的凭据字段
+func stripSecretColumns(tableName string, rows []map[string]interface{}) {
+	for _, row := range rows {
+		for _, col := range secretColumns[tableName] {
+			delete(row, col)
+		}
+	}
+}
+
+// touchesColumns 请求数据是否包含任一指定字段（MySQL 列名不区分大小写，比较时同样忽略大小写）
+func touchesColumns(data map[string]interface{}, columns []string) bool {
+	for key := range data {
+		for _, col := range columns {
+			if strings.EqualFold(strings.Trim(key, "` "), col) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripDerivedColumns 移除请求数据中的派生字段
+func stripDerivedColumns(tableName string, data map[string]interface{}) {
+	for _, col := range derivedColumns[tableName] {
+		delete(data, col)
+	}
+}
+
+// seatCourseID 返回写操作后可能空出名额的课程ID（修改课程容量、删除或修改选课记录）
+func seatCourseID(tableName, id string) uint {
+	var courseID uint
+	switch tableName {
+	case "courses":
+		parsed, _ := strconv.Atoi(id)
+		courseID = uint(parsed)
+	case "enrollments":
+		config.DB.Table("enrollments").Select("course_id").Where("id = ?", id).Limit(1).Scan(&courseID)
+	}
+	return courseID
+}
+
+// promoteWaitlist 名额变化后让候补学生递补
+func promoteWaitlist(courseID uint) {
+	if courseID == 0 {
+		return
+	}
+	if _, err := service.PromoteWaitlist(config.DB, courseID); err != nil {
+		log.Printf("课程 %d 候补递补失败: %v", courseID, err)
+	}
+}
+
 // ExportTableData 导出表数据
 func ExportTableData(c *gin.Context) {
 	tableName := c.Param("table")
@@ -305,6 +533,8 @@ func ExportTableData(c *gin.Context) {
 		"grades": true, "attendances": true, "reward_punishments": true,
 		"notifications": true, "schedules": true, "grade_audit_logs": true,
 		"vw_class_performance": true, "vw_student_full_profile": true,
+		"course_waitlists": true, "semesters": true, "grade_point_scales": true, "alert_rules": true,
+		"academic_alerts": true,
 	}
 
 	if !validTables[tableName] {
@@ -316,8 +546,14 @@ func ExportTableData(c *gin.Context) {
 	}
 
 	db := config.DB
+	query, ok := middleware.GetDataScope(c).Apply(db.Table(tableName), tableName, false)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权访问该表"})
+		return
+	}
 	var results []map[string]interface{}
-	db.Table(tableName).Find(&results)
+	query.Find(&results)
+	stripSecretColumns(tableName, results)
 
 	// 简单返回 JSON 格式（实际应用中应该生成 Excel 文件）
 	c.Header("Content-Type", "application/json")
@@ -325,6 +561,18 @@ func ExportTableData(c *gin.Context) {
 	c.JSON(http.StatusOK, results)
 }
 
+// ddlKeyword 在语句任意位置（包括注释中，MySQL 会执行 /*! ... */ 中的内容）出现的 DDL 关键字；
+// 不只匹配语句开头，否则 /**/ DROP TABLE users 或以 -- 注释开头的语句可以绕过
+var (
+	ddlKeyword  = regexp.MustCompile(`(?i)\b(alter|create|drop|rename|truncate)\b`)
+	adminTables = regexp.MustCompile(`(?i)\b(users|roles|permissions|role_permissions|role_permission_rules)\b`)
+)
+
+// isAdminDDL 语句是否可能对账号、角色与权限相关的表执行 DDL
+func isAdminDDL(sql string) bool {
+	return ddlKeyword.MatchString(sql) && adminTables.MatchString(sql)
+}
+
 // ExecuteSQL 执行 SQL 查询（仅用于开发环境，生产环境应禁用）
 func ExecuteSQL(c *gin.Context) {
 	var req struct {
@@ -339,16 +587,41 @@ func ExecuteSQL(c *gin.Context) {
 		return
 	}
 
+	// 任意 SQL 无法注入行级条件，仅允许不受数据范围限制的用户执行
+	if !middleware.GetDataScope(c).All {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权执行 SQL"})
+		return
+	}
+
+	// DDL 语句会隐式提交事务，无法由管理员账号检查回滚，不允许用于账号、角色与权限相关的表（同时出现 DDL 关键字与这些表名即拒绝）
+	if isAdminDDL(req.SQL) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "不允许对账号、角色与权限相关的表执行 DDL 语句"})
+		return
+	}
+
 	db := config.DB
 	var results []map[string]interface{}
 
-	if err := db.Raw(req.SQL).Scan(&results).Error; err != nil {
+	// 任意 SQL 都可能修改账号或角色权限，执行后不能没有可用的管理员账号
+	err := service.WithAdminGuard(db, func(tx *gorm.DB) error {
+		return tx.Raw(req.SQL).Scan(&results).Error
+	})
+	if errors.Is(err, service.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": err.Error(),
 		})
 		return
 	}
+	// 任意 SQL 都可能修改角色权限或课程所属学期
+	app.Permissions.InvalidateAll()
+	if err := service.SyncEnrollmentSemesters(db); err != nil {
+		log.Printf("同步选课记录学期失败: %v", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -369,6 +642,8 @@ func GetTableSchema(c *gin.Context) {
 		"grades": true, "attendances": true, "reward_punishments": true,
 		"notifications": true, "schedules": true, "grade_audit_logs": true,
 		"vw_class_performance": true, "vw_student_full_profile": true,
+		"course_waitlists": true, "semesters": true, "grade_point_scales": true, "alert_rules": true,
+		"academic_alerts": true,
 	}
 
 	if !validTables[tableName] {
@@ -379,6 +654,11 @@ func GetTableSchema(c *gin.Context) {
 		return
 	}
 
+	if _, _, ok := middleware.GetDataScope(c).Condition(tableName, false); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权访问该表"})
+		return
+	}
+
 	db := config.DB
 	var columns []map[string]interface{}
 
@@ -0,0 +1,26 @@
+package v1
+
+import "testing"
+
+func TestIsAdminDDL(t *testing.T) {
+	cases := map[string]bool{
+		"DROP TABLE users":                         true,
+		"  alter table roles add column x int":     true,
+		"/**/ DROP TABLE users":                    true,
+		"-- x\nALTER TABLE roles DROP COLUMN x":    true,
+		"(DROP TABLE permissions)":                 true,
+		"/*!40101 DROP TABLE role_permissions */":  true,
+		"TRUNCATE `role_permission_rules`":         true,
+		"RENAME TABLE tmp TO student_db.users":     true,
+		"SELECT * FROM users":                      false,
+		"UPDATE users SET is_active = 1":           false,
+		"SELECT created_at FROM roles":             false,
+		"DROP TABLE tmp_import":                    false,
+		"CREATE INDEX idx_name ON students (name)": false,
+	}
+	for sql, want := range cases {
+		if got := isAdminDDL(sql); got != want {
+			t.Errorf("isAdminDDL(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
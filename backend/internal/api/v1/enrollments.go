@@ -0,0 +1,218 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateEnrollmentRequest 选课请求
+type CreateEnrollmentRequest struct {
+	StudentID uint  `json:"student_id" binding:"required"`
+	CourseID  uint  `json:"course_id" binding:"required"`
+	Waitlist  *bool `json:"waitlist"` // 课程已满时是否加入候补名单，默认 true
+}
+
+// ReorderWaitlistRequest 调整候补顺序请求
+type ReorderWaitlistRequest struct {
+	EntryIDs []uint `json:"entry_ids" binding:"required"` // 按新顺序排列的候补记录ID
+}
+
+// CreateEnrollment 选课（课程已满时加入候补名单）
+func CreateEnrollment(c *gin.Context) {
+	var req CreateEnrollmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	// 学生账号只能为自己选课
+	if studentID := currentStudentID(c, config.GetDB()); studentID != 0 && studentID != req.StudentID {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "只能为本人选课"})
+		return
+	}
+	// 教师只能为本人授课的课程办理选课
+	if !middleware.GetDataScope(c).AllowsCourse(req.CourseID) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权为该课程办理选课"})
+		return
+	}
+	enrollStudent(c, req.StudentID, req.CourseID, req.Waitlist)
+}
+
+// enrollStudent 校验选课时间窗口后选课，waitlist 为 nil 或 true 时课程已满自动加入候补
+func enrollStudent(c *gin.Context, studentID, courseID uint, waitlist *bool) {
+	db := config.GetDB()
+	if !middleware.HasPermission(c, permission.EnrollmentOverride.Code) {
+		if err := service.CheckEnrollWindow(db, courseID); err != nil {
+			respondEnrollment(c, nil, err)
+			return
+		}
+	}
+
+	var entry *models.CourseWaitlist
+	var err error
+	if waitlist == nil || *waitlist {
+		entry, err = service.EnrollOrWaitlist(db, studentID, courseID)
+	} else {
+		err = service.Enroll(db, studentID, courseID)
+	}
+	respondEnrollment(c, entry, err)
+}
+
+// respondEnrollment 统一输出选课/候补结果
+func respondEnrollment(c *gin.Context, entry *models.CourseWaitlist, err error) {
+	switch {
+	case err == nil && entry != nil:
+		c.JSON(http.StatusAccepted, gin.H{"code": 202, "message": "课程已满，已加入候补名单", "data": entry})
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"code": 200, "message": "选课成功"})
+	case errors.Is(err, service.ErrAlreadyEnrolled), errors.Is(err, service.ErrAlreadyWaitlisted),
+		errors.Is(err, service.ErrCourseFull):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+	case errors.Is(err, service.ErrPrereqNotMet):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+	case errors.Is(err, service.ErrEnrollNotOpen), errors.Is(err, service.ErrEnrollClosed), errors.Is(err, service.ErrAddDropClosed):
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": err.Error()})
+	case errors.Is(err, service.ErrCourseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "选课失败", "error": err.Error()})
+	}
+}
+
+// DeleteEnrollment 退课，释放的名额自动分配给候补学生
+func DeleteEnrollment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	db := config.GetDB()
+	var enrollment models.Enrollment
+	if err := db.First(&enrollment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "选课记录不存在"})
+		return
+	}
+
+	if studentID := currentStudentID(c, db); studentID != 0 && studentID != enrollment.StudentID {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "只能退选本人的课程"})
+		return
+	}
+	if !middleware.GetDataScope(c).AllowsCourse(enrollment.CourseID) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权为该课程办理退课"})
+		return
+	}
+	dropEnrollment(c, &enrollment)
+}
+
+// dropEnrollment 校验加退选截止时间后退课
+func dropEnrollment(c *gin.Context, enrollment *models.Enrollment) {
+	db := config.GetDB()
+	if !middleware.HasPermission(c, permission.EnrollmentOverride.Code) {
+		if err := service.CheckDropWindow(db, enrollment.CourseID); err != nil {
+			respondEnrollment(c, nil, err)
+			return
+		}
+	}
+
+	if err := service.Drop(db, enrollment.StudentID, enrollment.CourseID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "退课失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "退课成功"})
+}
+
+// AdminGetWaitlist 查看课程候补名单
+func AdminGetWaitlist(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || courseID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	if !middleware.GetDataScope(c).AllowsCourse(uint(courseID)) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权管理该课程"})
+		return
+	}
+
+	db := config.GetDB()
+	var course models.Course
+	if err := db.First(&course, courseID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "课程不存在"})
+		return
+	}
+
+	q := db.Preload("Student").Where("course_id = ?", courseID)
+	if status := c.DefaultQuery("status", service.WaitlistWaiting); status != "all" {
+		q = q.Where("status = ?", status)
+	}
+	var entries []models.CourseWaitlist
+	if err := q.Order("position ASC, id ASC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"course_id":      course.ID,
+			"course_name":    course.CourseName,
+			"capacity":       course.Capacity,
+			"enrolled_count": course.EnrolledCount,
+			"list":           entries,
+		},
+	})
+}
+
+// AdminReorderWaitlist 调整课程候补顺序
+func AdminReorderWaitlist(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || courseID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	if !middleware.GetDataScope(c).AllowsCourse(uint(courseID)) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权管理该课程"})
+		return
+	}
+	var req ReorderWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	if err := service.ReorderWaitlist(config.GetDB(), uint(courseID), req.EntryIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "调整失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "调整成功"})
+}
+
+// AdminPromoteWaitlist 手动触发候补递补（例如直接修改数据库后）
+func AdminPromoteWaitlist(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || courseID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	if !middleware.GetDataScope(c).AllowsCourse(uint(courseID)) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权管理该课程"})
+		return
+	}
+
+	promoted, err := service.PromoteWaitlist(config.GetDB(), uint(courseID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "递补失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "递补完成", "data": promoted})
+}
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LinkIdentityRequest 管理员关联外部身份
+type LinkIdentityRequest struct {
+	Provider string `json:"provider" binding:"required,max=50"` // 统一身份认证的 oidc.provider
+	Subject  string `json:"subject" binding:"required,max=255"` // 身份提供方的 sub
+}
+
+// linkableProviders 可以关联的外部身份提供方（已启用的认证方式）
+func linkableProviders() []string {
+	var providers []string
+	if app.OIDC != nil {
+		providers = append(providers, app.OIDC.Config().Provider)
+	}
+	if config.Current().LDAP.Enabled {
+		providers = append(providers, service.AuthLDAP)
+	}
+	return providers
+}
+
+// AdminListUserIdentities 账号已关联的外部身份
+func AdminListUserIdentities(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	identities, err := service.ListIdentities(config.GetDB(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    gin.H{"list": identities, "providers": linkableProviders()},
+	})
+}
+
+// AdminLinkUserIdentity 把外部身份关联到账号，之后该身份可以登录此账号
+// 统一身份认证与 LDAP 都不会按用户名自动关联已有账号，已有账号须经此关联
+func AdminLinkUserIdentity(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req LinkIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	allowed := false
+	for _, p := range linkableProviders() {
+		allowed = allowed || p == req.Provider
+	}
+	if !allowed {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "未知或未启用的身份提供方", "data": gin.H{"providers": linkableProviders()}})
+		return
+	}
+
+	link, err := service.LinkIdentity(config.GetDB(), uint(id), req.Provider, req.Subject)
+	switch {
+	case err == nil:
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	case errors.Is(err, service.ErrIdentityInUse):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "关联失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "关联成功", "data": link})
+}
+
+// AdminUnlinkUserIdentity 解除账号的外部身份关联
+func AdminUnlinkUserIdentity(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	identityID, err := strconv.Atoi(c.Param("identity_id"))
+	if err != nil || identityID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	if err := service.UnlinkIdentity(config.GetDB(), uint(id), uint(identityID)); err != nil {
+		if errors.Is(err, service.ErrIdentityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "解除关联失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "解除关联成功"})
+}
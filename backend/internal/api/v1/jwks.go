@@ -0,0 +1,21 @@
+package v1
+
+import (
+	"net/http"
+
+	"student-management-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS 公开本系统的 JWT 验证公钥（JWKS，RFC 7517），供其他内部服务按 kid 验证访问令牌
+// 返回标准 JWKS 格式 {"keys": [...]}，不使用统一的 code/message 包装
+func GetJWKS(c *gin.Context) {
+	ks := utils.CurrentKeySet()
+	if ks == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "message": "签名密钥尚未加载"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": ks.JWKS()})
+}
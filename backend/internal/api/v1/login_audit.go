@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockLoginRequest 解除登录限制请求（username、ip 至少提供一个）
+type UnlockLoginRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
+
+// AdminListLoginAttempts 登录审计记录（分页；可按 username、user_id、ip、result、success、start、end 筛选）
+func AdminListLoginAttempts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	query := config.GetDB().Model(&models.LoginAttempt{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if result := c.Query("result"); result != "" {
+		query = query.Where("result = ?", result)
+	}
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true" || success == "1")
+	}
+	if start := c.Query("start"); start != "" {
+		query = query.Where("created_at >= ?", start)
+	}
+	if end := c.Query("end"); end != "" {
+		query = query.Where("created_at <= ?", end)
+	}
+
+	var total int64
+	var attempts []models.LoginAttempt
+	if err := query.Count(&total).Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"list":      attempts,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// AdminListLoginLocks 当前处于锁定状态或有失败记录的用户名和 IP
+func AdminListLoginLocks(c *gin.Context) {
+	var throttles []models.LoginThrottle
+	query := config.GetDB().Order("updated_at DESC")
+	if c.Query("locked") == "true" {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+	if err := query.Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": throttles, "total": len(throttles)}})
+}
+
+// AdminUnlockLogin 解除用户名和/或 IP 的登录锁定
+func AdminUnlockLogin(c *gin.Context) {
+	var req UnlockLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if req.Username == "" && req.IP == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "请提供用户名或IP"})
+		return
+	}
+	respondUnlock(c, service.UnlockLogin(config.GetDB(), req.Username, req.IP))
+}
+
+// AdminUnlockUser 解除用户的登录锁定
+func AdminUnlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	db := config.GetDB()
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	respondUnlock(c, service.UnlockLogin(db, user.Username, ""))
+}
+
+func respondUnlock(c *gin.Context, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"code": 200, "message": "已解除锁定"})
+	case errors.Is(err, service.ErrNoLoginLock):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "解除锁定失败", "error": err.Error()})
+	}
+}
@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 学生自助接口（/api/v1/me）：学生ID 一律取自 SelfStudentMiddleware 放入上下文的本人ID，
+// 不接受请求中传入的学生ID。
+
+// MyEnrollmentRequest 学生本人选课请求
+type MyEnrollmentRequest struct {
+	CourseID uint  `json:"course_id" binding:"required"`
+	Waitlist *bool `json:"waitlist"` // 课程已满时是否加入候补名单，默认 true
+}
+
+// GetMyTimetable 本人课表，默认为当前学期（可用 semester_id 指定其他学期）
+func GetMyTimetable(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+
+	semesterID := semesterFilter(c)
+	var semester *models.Semester
+	if semesterID == 0 {
+		current, err := service.CurrentSemester(config.GetDB())
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未设置当前学期"})
+			return
+		}
+		semester, semesterID = current, current.ID
+	}
+
+	schedules, err := studentSchedule(student, semesterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    gin.H{"semester_id": semesterID, "semester": semester, "list": schedules, "total": len(schedules)},
+	})
+}
+
+// GetMyCourses 本人已选课程及候补中的课程（可按 semester_id 筛选）
+func GetMyCourses(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	query := db.Preload("Course").Preload("Course.Teacher").Where("student_id = ?", student.ID)
+	if semesterID := semesterFilter(c); semesterID > 0 {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	var enrollments []models.Enrollment
+	if err := query.Order("id ASC").Find(&enrollments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	var waitlist []models.CourseWaitlist
+	if err := db.Preload("Course").Where("student_id = ? AND status = ?", student.ID, service.WaitlistWaiting).
+		Order("id ASC").Find(&waitlist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    gin.H{"list": enrollments, "total": len(enrollments), "waitlist": waitlist},
+	})
+}
+
+// GetMyTranscript 本人成绩单（format=pdf 时返回 PDF）
+func GetMyTranscript(c *gin.Context) {
+	renderTranscript(c, config.GetDB(), c.GetUint(middleware.ScopedStudentKey))
+}
+
+// CreateMyEnrollment 本人选课（课程已满时加入候补名单）
+func CreateMyEnrollment(c *gin.Context) {
+	var req MyEnrollmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	enrollStudent(c, c.GetUint(middleware.ScopedStudentKey), req.CourseID, req.Waitlist)
+}
+
+// DeleteMyEnrollment 本人退课（路径参数为课程ID）
+func DeleteMyEnrollment(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("course_id"))
+	if err != nil || courseID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	var enrollment models.Enrollment
+	if err := config.GetDB().Where("student_id = ? AND course_id = ?", c.GetUint(middleware.ScopedStudentKey), courseID).
+		First(&enrollment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未选该课程"})
+		return
+	}
+	dropEnrollment(c, &enrollment)
+}
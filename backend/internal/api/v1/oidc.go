@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCCallbackRequest 统一身份认证回调参数（前端从回调地址的查询参数中取得后提交）
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// oidcStateCookie 保存进行中登录状态的 Cookie（签名，仅限统一身份认证接口，前端 JS 不可读）
+const oidcStateCookie = "oidc_state"
+
+// setOIDCStateCookie 设置或清除（maxAge < 0）登录状态 Cookie
+// SameSite=Lax：跨站发起的回调请求不会携带该 Cookie，攻击者无法把自己的授权码提交到受害者的浏览器完成登录
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, "/api/v1/auth/oidc", "", config.Current().IsProduction(), true)
+}
+
+// GetOIDCConfig 登录页是否显示统一身份认证按钮
+func GetOIDCConfig(c *gin.Context) {
+	if app.OIDC == nil {
+		c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"enabled": false}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    gin.H{"enabled": true, "display_name": app.OIDC.Config().DisplayName},
+	})
+}
+
+// OIDCAuthorize 返回跳转到身份提供方的登录地址
+func OIDCAuthorize(c *gin.Context) {
+	if app.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未启用统一身份认证"})
+		return
+	}
+	authURL, state, err := app.OIDC.AuthorizationURL(c.Request.Context())
+	if err != nil {
+		log.Printf("生成统一身份认证地址失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "message": "统一身份认证服务暂不可用"})
+		return
+	}
+	cookie, err := app.OIDC.EncodeState(state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成登录状态失败", "error": err.Error()})
+		return
+	}
+	setOIDCStateCookie(c, cookie, int(service.OIDCStateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"authorization_url": authURL}})
+}
+
+// OIDCCallback 用授权码完成统一身份认证登录：映射（或自动创建）本系统用户后，与密码登录一样签发令牌
+func OIDCCallback(c *gin.Context) {
+	if app.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未启用统一身份认证"})
+		return
+	}
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	// 登录状态只能使用一次：无论成功与否都清除 Cookie
+	var pending *service.OIDCState
+	if cookie, err := c.Cookie(oidcStateCookie); err == nil {
+		pending, _ = app.OIDC.DecodeState(cookie)
+	}
+	setOIDCStateCookie(c, "", -1)
+
+	ident, err := app.OIDC.Exchange(c.Request.Context(), req.Code, req.State, pending)
+	if err != nil {
+		log.Printf("统一身份认证失败: %v", err)
+		switch {
+		case errors.Is(err, service.ErrOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		case errors.Is(err, service.ErrOIDCToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": service.ErrOIDCToken.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"code": 502, "message": "统一身份认证服务暂不可用"})
+		}
+		return
+	}
+
+	db := config.GetDB()
+	user, created, err := service.ResolveOIDCUser(db, app.OIDC.Config(), ident)
+	if err != nil {
+		if errors.Is(err, service.ErrOIDCNoAccount) || errors.Is(err, service.ErrIdentityNotLinked) {
+			recordLoginAudit(c, db, ident.Username, 0, service.LoginUnknownUser)
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "登录失败", "error": err.Error()})
+		return
+	}
+	if created {
+		log.Printf("统一身份认证自动创建用户 %s（%s）", user.Username, ident.Subject)
+	}
+	continueLogin(c, db, user, user.Username)
+}
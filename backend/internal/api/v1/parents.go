@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GuardianRequest 建立监护关系请求
+type GuardianRequest struct {
+	StudentID uint   `json:"student_id" binding:"required"`
+	Relation  string `json:"relation"` // 为空时沿用家长表中的关系
+}
+
+// GetParentChildren 家长查看自己监护的全部子女
+func GetParentChildren(c *gin.Context) {
+	children, err := service.GuardianChildren(config.GetDB(), c.GetUint("parent_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": children, "total": len(children)}})
+}
+
+// AdminGetParentChildren 查看家长的监护关系
+func AdminGetParentChildren(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	db := config.GetDB()
+	var parent models.Parent
+	if err := db.First(&parent, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "家长不存在"})
+		return
+	}
+	var links []models.StudentGuardian
+	if err := db.Where("parent_id = ?", id).Order("created_at ASC").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	children, err := service.GuardianChildren(db, parent.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    gin.H{"parent": parent, "links": links, "children": children},
+	})
+}
+
+// AdminAddParentChild 为家长添加监护的学生
+func AdminAddParentChild(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req GuardianRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	if err := service.AddGuardian(config.GetDB(), uint(id), req.StudentID, req.Relation); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "家长或学生不存在"})
+		case errors.Is(err, service.ErrGuardianExists):
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "添加失败", "error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "添加成功"})
+}
+
+// AdminRemoveParentChild 解除家长与学生的监护关系
+func AdminRemoveParentChild(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	studentID, err := strconv.Atoi(c.Param("student_id"))
+	if err != nil || studentID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的学生ID"})
+		return
+	}
+
+	if err := service.RemoveGuardian(config.GetDB(), uint(id), uint(studentID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "监护关系不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "解除失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "解除成功"})
+}
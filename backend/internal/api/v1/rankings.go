@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// rankingOptions 从查询参数读取排名选项（score_type、tie、scale、semester_id、course_id）
+func rankingOptions(c *gin.Context) service.RankingOptions {
+	opts := service.RankingOptions{
+		ScoreType: c.Query("score_type"),
+		Tie:       c.Query("tie"),
+		Scale:     c.DefaultQuery("scale", service.DefaultGradeScale),
+	}
+	if id, err := strconv.Atoi(c.Query("semester_id")); err == nil && id > 0 {
+		opts.SemesterID = uint(id)
+	}
+	if id, err := strconv.Atoi(c.Query("course_id")); err == nil && id > 0 {
+		opts.CourseID = uint(id)
+	}
+	return opts
+}
+
+// currentStudentID 当前登录用户关联的学生ID，非学生账号返回 0
+func currentStudentID(c *gin.Context, db *gorm.DB) uint {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil || user.UserType != "student" {
+		return 0
+	}
+	return user.UserID
+}
+
+// respondRanking 输出排名结果
+// 具备 ranking:read 权限时返回数据范围内学生的名次；学生只能看到本人所在的一行
+func respondRanking(c *gin.Context, db *gorm.DB, entries []service.RankingEntry, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTiePolicy), errors.Is(err, service.ErrScaleNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询排名失败", "error": err.Error()})
+		}
+		return
+	}
+
+	if middleware.HasPermission(c, permission.RankingRead.Code) {
+		entries, err = scopeRanking(c, db, entries)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询排名失败", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": entries, "total": len(entries)}})
+		return
+	}
+
+	studentID := currentStudentID(c, db)
+	if studentID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权限访问"})
+		return
+	}
+	for _, entry := range entries {
+		if entry.StudentID == studentID {
+			c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": entry})
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "暂无排名数据"})
+}
+
+// GetClassRanking 班级排名
+func GetClassRanking(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	db := config.GetDB()
+	entries, err := service.ClassRanking(db, uint(id), rankingOptions(c))
+	respondRanking(c, db, entries, err)
+}
+
+// GetCourseRanking 课程排名
+func GetCourseRanking(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	db := config.GetDB()
+	entries, err := service.CourseRanking(db, uint(id), rankingOptions(c))
+	respondRanking(c, db, entries, err)
+}
+
+// GetSchoolRanking 全校 GPA 排名
+func GetSchoolRanking(c *gin.Context) {
+	db := config.GetDB()
+	entries, err := service.SchoolRanking(db, rankingOptions(c))
+	respondRanking(c, db, entries, err)
+}
+
+// scopeRanking 只保留调用者数据范围内的学生（名次与总人数仍为完整排名中的值）
+func scopeRanking(c *gin.Context, db *gorm.DB, entries []service.RankingEntry) ([]service.RankingEntry, error) {
+	scope := middleware.GetDataScope(c)
+	if scope.All {
+		return entries, nil
+	}
+	var ids []uint
+	query, ok := scope.Apply(db.Table("students"), "students", false)
+	if ok {
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+	}
+	allowed := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	filtered := make([]service.RankingEntry, 0, len(entries))
+	for _, entry := range entries {
+		if allowed[entry.StudentID] {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,217 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SemesterRequest 创建/更新学期请求（时间格式为 RFC3339）
+type SemesterRequest struct {
+	Name            string    `json:"name" binding:"required"`
+	StartDate       time.Time `json:"start_date" binding:"required"`
+	EndDate         time.Time `json:"end_date" binding:"required"`
+	EnrollStart     time.Time `json:"enroll_start" binding:"required"`
+	EnrollEnd       time.Time `json:"enroll_end" binding:"required"`
+	AddDropDeadline time.Time `json:"add_drop_deadline" binding:"required"`
+	IsCurrent       bool      `json:"is_current"`
+}
+
+// SemesterCoursesRequest 设置学期开课列表请求
+type SemesterCoursesRequest struct {
+	CourseIDs []uint `json:"course_ids" binding:"required"`
+}
+
+// validate 校验学期时间设置是否合理
+func (r SemesterRequest) validate() error {
+	if !r.StartDate.Before(r.EndDate) {
+		return errors.New("学期开始日期必须早于结束日期")
+	}
+	if !r.EnrollStart.Before(r.EnrollEnd) {
+		return errors.New("选课开始时间必须早于结束时间")
+	}
+	if r.AddDropDeadline.Before(r.EnrollEnd) {
+		return errors.New("加退选截止时间不能早于选课结束时间")
+	}
+	return nil
+}
+
+// apply 将请求内容写入学期模型
+func (r SemesterRequest) apply(semester *models.Semester) {
+	semester.Name = r.Name
+	semester.StartDate = r.StartDate
+	semester.EndDate = r.EndDate
+	semester.EnrollStart = r.EnrollStart
+	semester.EnrollEnd = r.EnrollEnd
+	semester.AddDropDeadline = r.AddDropDeadline
+	semester.IsCurrent = r.IsCurrent
+}
+
+// saveSemester 保存学期；设为当前学期时取消其他学期的当前标记
+func saveSemester(db *gorm.DB, semester *models.Semester) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if semester.IsCurrent {
+			if err := tx.Model(&models.Semester{}).Where("id <> ? AND is_current = ?", semester.ID, true).
+				Update("is_current", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(semester).Error
+	})
+}
+
+// AdminListSemesters 学期列表
+func AdminListSemesters(c *gin.Context) {
+	var semesters []models.Semester
+	if err := config.GetDB().Order("start_date DESC").Find(&semesters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": semesters, "total": len(semesters)}})
+}
+
+// AdminCreateSemester 新增学期
+func AdminCreateSemester(c *gin.Context) {
+	var req SemesterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	var semester models.Semester
+	req.apply(&semester)
+	if err := saveSemester(config.GetDB(), &semester); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "创建成功", "data": semester})
+}
+
+// AdminUpdateSemester 更新学期
+func AdminUpdateSemester(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req SemesterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var semester models.Semester
+	if err := db.First(&semester, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "学期不存在"})
+		return
+	}
+	req.apply(&semester)
+	if err := saveSemester(db, &semester); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "更新成功", "data": semester})
+}
+
+// AdminDeleteSemester 删除学期（仍有开课课程时不允许删除）
+func AdminDeleteSemester(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	db := config.GetDB()
+	var courseCount int64
+	db.Model(&models.Course{}).Where("semester_id = ?", id).Count(&courseCount)
+	if courseCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": "该学期仍有开课课程，无法删除"})
+		return
+	}
+
+	if err := db.Delete(&models.Semester{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "删除成功"})
+}
+
+// AdminSetSemesterCourses 将课程安排到指定学期开设
+func AdminSetSemesterCourses(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	var req SemesterCoursesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var semester models.Semester
+	if err := db.First(&semester, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "学期不存在"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(req.CourseIDs) > 0 {
+			if err := tx.Model(&models.Course{}).Where("id IN ?", req.CourseIDs).
+				Update("semester_id", semester.ID).Error; err != nil {
+				return err
+			}
+		}
+		// 同步这些课程已有选课记录的学期
+		return service.SyncEnrollmentSemesters(tx, req.CourseIDs...)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "设置失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "设置成功"})
+}
+
+// GetCurrentSemester 获取当前学期
+func GetCurrentSemester(c *gin.Context) {
+	semester, err := service.CurrentSemester(config.GetDB())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未设置当前学期"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": semester})
+}
+
+// GetSemesterCourses 获取学期开设的课程
+func GetSemesterCourses(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	var courses []models.Course
+	if err := config.GetDB().Preload("Teacher").Where("semester_id = ?", id).Order("id ASC").Find(&courses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": courses, "total": len(courses)}})
+}
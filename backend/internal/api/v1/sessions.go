@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionView 登录会话（标记是否为发起请求的当前会话）
+type SessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// AdminSessionView 管理员查看的登录会话（附用户名）
+type AdminSessionView struct {
+	models.Session
+	Username string `json:"username"`
+}
+
+// GetMySessions 当前用户的有效会话
+func GetMySessions(c *gin.Context) {
+	sessions, err := service.ListActiveSessions(config.GetDB(), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	current := c.GetUint("session_id")
+	list := make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, SessionView{Session: s, Current: s.ID == current})
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": list, "total": len(list)}})
+}
+
+// RevokeMySession 撤销本人的某个会话（可以是当前会话，即登出）
+func RevokeMySession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	if err := service.RevokeUserSession(config.GetDB(), c.GetUint("user_id"), uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "会话不存在或已失效"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "撤销失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "撤销成功"})
+}
+
+// RevokeMyOtherSessions 撤销本人除当前会话外的全部会话（在其他设备上登出）
+func RevokeMyOtherSessions(c *gin.Context) {
+	count, err := service.RevokeOtherSessions(config.GetDB(), c.GetUint("user_id"), c.GetUint("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "撤销失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "撤销成功", "data": gin.H{"revoked": count}})
+}
+
+// AdminListSessions 列出登录会话（分页；可按 user_id、username 筛选，active=false 时包含已撤销和已过期的会话）
+func AdminListSessions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	query := config.GetDB().Table("sessions").Joins("JOIN users ON users.id = sessions.user_id")
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("sessions.user_id = ?", userID)
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("users.username LIKE ?", "%"+username+"%")
+	}
+	if c.DefaultQuery("active", "true") != "false" {
+		query = query.Where("sessions.revoked_at IS NULL AND sessions.expires_at > ?", time.Now())
+	}
+
+	var total int64
+	var list []AdminSessionView
+	if err := query.Count(&total).Select("sessions.*, users.username").Order("sessions.last_seen_at DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Scan(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	if list == nil {
+		list = []AdminSessionView{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"list":      list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// AdminRevokeSession 强制结束某个会话
+func AdminRevokeSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	db := config.GetDB()
+	var session models.Session
+	if err := db.First(&session, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "会话不存在"})
+		return
+	}
+	if err := service.RevokeSession(db, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "撤销失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "撤销成功"})
+}
+
+// AdminRevokeUserSessions 强制结束用户的全部会话
+func AdminRevokeUserSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	db := config.GetDB()
+	if err := db.First(&models.User{}, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	count, err := service.RevokeOtherSessions(db, uint(id), 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "撤销失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "撤销成功", "data": gin.H{"revoked": count}})
+}
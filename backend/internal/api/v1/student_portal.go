@@ -0,0 +1,210 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 以下处理器只读取中间件放入上下文的学生（middleware.ScopedStudentKey）的数据，
+// 由挂载它们的路由组（家长门户、学生本人）负责行级校验。
+
+// StudentCourseGrades 学生某门课程的成绩
+type StudentCourseGrades struct {
+	EnrollmentID uint           `json:"enrollment_id"`
+	CourseID     uint           `json:"course_id"`
+	CourseName   string         `json:"course_name"`
+	Credits      float64        `json:"credits"`
+	SemesterID   uint           `json:"semester_id"`
+	Grades       []models.Grade `json:"grades"`
+	FinalScore   *float64       `json:"final_score"` // 总评，尚无成绩时为 null
+}
+
+// scopedStudent 读取当前请求允许访问的学生
+func scopedStudent(c *gin.Context) (*models.Student, bool) {
+	studentID := c.GetUint(middleware.ScopedStudentKey)
+	var student models.Student
+	if studentID == 0 || config.GetDB().Preload("Class").First(&student, studentID).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "学生不存在"})
+		return nil, false
+	}
+	return &student, true
+}
+
+// semesterFilter 读取 semester_id 查询参数，未指定时返回 0
+func semesterFilter(c *gin.Context) uint {
+	id, err := strconv.Atoi(c.Query("semester_id"))
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return uint(id)
+}
+
+// GetScopedStudentGrades 学生各课程成绩（可按 semester_id 筛选）
+func GetScopedStudentGrades(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+
+	query := config.GetDB().Preload("Course").Preload("Grades").Where("student_id = ?", student.ID)
+	if semesterID := semesterFilter(c); semesterID > 0 {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	var enrollments []models.Enrollment
+	if err := query.Order("id ASC").Find(&enrollments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	list := make([]StudentCourseGrades, 0, len(enrollments))
+	for _, e := range enrollments {
+		item := StudentCourseGrades{
+			EnrollmentID: e.ID,
+			CourseID:     e.CourseID,
+			CourseName:   e.Course.CourseName,
+			Credits:      e.Course.Credits,
+			SemesterID:   e.SemesterID,
+			Grades:       e.Grades,
+		}
+		if item.Grades == nil {
+			item.Grades = []models.Grade{}
+		}
+		if score, ok := service.FinalScore(e.Grades); ok {
+			item.FinalScore = &score
+		}
+		list = append(list, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": list, "total": len(list)}})
+}
+
+// GetScopedStudentAttendance 学生考勤记录（可按 start_date、end_date、status 筛选），附各状态统计
+func GetScopedStudentAttendance(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+
+	query := config.GetDB().Where("student_id = ?", student.ID)
+	if start := c.Query("start_date"); start != "" {
+		query = query.Where("date >= ?", start)
+	}
+	if end := c.Query("end_date"); end != "" {
+		query = query.Where("date <= ?", end)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var records []models.Attendance
+	if err := query.Order("date DESC, id DESC").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	summary := make(map[string]int)
+	for _, r := range records {
+		summary[r.Status]++
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    gin.H{"list": records, "total": len(records), "summary": summary},
+	})
+}
+
+// GetScopedStudentSchedule 学生课表：所在班级的排课以及已选课程的排课（可按 semester_id 筛选）
+func GetScopedStudentSchedule(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+	schedules, err := studentSchedule(student, semesterFilter(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": schedules, "total": len(schedules)}})
+}
+
+// studentSchedule 查询学生课表，semesterID 为 0 时不限学期
+func studentSchedule(student *models.Student, semesterID uint) ([]models.Schedule, error) {
+	db := config.GetDB()
+	enrolled := db.Model(&models.Enrollment{}).Select("course_id").Where("student_id = ?", student.ID)
+	query := db.Preload("Course").Preload("Teacher").
+		Where(db.Where("class_id = ?", student.ClassID).Or("course_id IN (?)", enrolled))
+	if semesterID > 0 {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	var schedules []models.Schedule
+	err := query.Order("day_of_week ASC, start_time ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// GetScopedStudentRewards 学生奖惩记录（可按 type 筛选：奖励/处分）
+func GetScopedStudentRewards(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+
+	query := config.GetDB().Where("student_id = ?", student.ID)
+	if t := c.Query("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+	var records []models.RewardPunishment
+	if err := query.Order("date DESC, id DESC").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": gin.H{"list": records, "total": len(records)}})
+}
+
+// GetScopedStudentNotifications 与学生相关的通知：全体、所在班级、学生本人，以及查看者本人（如家长）的通知
+func GetScopedStudentNotifications(c *gin.Context) {
+	student, ok := scopedStudent(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	targets := []string{"all", fmt.Sprintf("student:%d", student.ID)}
+	if student.ClassID > 0 {
+		targets = append(targets, fmt.Sprintf("class:%d", student.ClassID))
+	}
+	if extra, ok := c.Get(middleware.ViewerTargetsKey); ok {
+		targets = append(targets, extra.([]string)...)
+	}
+
+	var total int64
+	var notifications []models.Notification
+	if err := config.GetDB().Model(&models.Notification{}).Where("target IN ?", targets).Count(&total).
+		Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"list":      notifications,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
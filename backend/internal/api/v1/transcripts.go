@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
+	"student-management-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// canAccessStudent 判断当前用户能否查看指定学生的学业数据
+// 具备 transcript:read 权限且学生在其数据范围内的教职工、学生本人、以及该学生的监护人可以查看
+func canAccessStudent(c *gin.Context, db *gorm.DB, studentID uint) bool {
+	if middleware.HasPermission(c, permission.TranscriptRead.Code) {
+		return middleware.GetDataScope(c).AllowsStudent(db, studentID)
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil || user.UserID == 0 {
+		return false
+	}
+
+	switch user.UserType {
+	case "student":
+		return user.UserID == studentID
+	case "parent":
+		return service.IsGuardian(db, user.UserID, studentID)
+	}
+	return false
+}
+
+// GetStudentTranscript 获取学生成绩单（format=pdf 时返回可打印的 PDF）
+func GetStudentTranscript(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+
+	db := config.GetDB()
+	if !canAccessStudent(c, db, uint(id)) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权限访问"})
+		return
+	}
+	renderTranscript(c, db, uint(id))
+}
+
+// renderTranscript 生成成绩单并按 format 参数输出 JSON 或 PDF
+func renderTranscript(c *gin.Context, db *gorm.DB, studentID uint) {
+	transcript, err := service.BuildTranscript(db, studentID, c.DefaultQuery("scale", service.DefaultGradeScale))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "学生不存在"})
+		case errors.Is(err, service.ErrScaleNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成成绩单失败", "error": err.Error()})
+		}
+		return
+	}
+
+	if c.Query("format") == "pdf" {
+		filename := fmt.Sprintf("transcript_%s.pdf", transcript.Student.StudentID)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+		c.Data(http.StatusOK, "application/pdf", transcriptPDF(transcript))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": transcript})
+}
+
+// transcriptPDF 将成绩单排版为 PDF
+func transcriptPDF(t *service.Transcript) []byte {
+	w := utils.NewPDFWriter()
+	columns := []float64{0, 230, 290, 350, 410, 460}
+
+	w.Text(18, "学生成绩单")
+	w.Gap(6)
+	w.Row(11, []string{"姓名: " + t.Student.Name, "学号: " + t.Student.StudentID}, []float64{0, 230})
+	w.Row(11, []string{"班级: " + t.Student.Class.ClassName, "绩点方案: " + t.Scale}, []float64{0, 230})
+	w.Gap(8)
+
+	for _, term := range t.Terms {
+		w.Row(13, []string{term.SemesterName}, []float64{0})
+		w.Row(10, []string{"课程", "学分", "总评", "绩点", "等级", "结果"}, columns)
+		for _, course := range term.Courses {
+			score, gp, result := "-", "-", "未出成绩"
+			if course.FinalScore != nil {
+				score = fmt.Sprintf("%.1f", *course.FinalScore)
+				gp = fmt.Sprintf("%.1f", *course.GradePoint)
+				result = "不及格"
+				if course.Passed {
+					result = "及格"
+				}
+			}
+			w.Row(10, []string{course.CourseName, fmt.Sprintf("%.1f", course.Credits), score, gp, course.Letter, result}, columns)
+		}
+		w.Row(10, []string{fmt.Sprintf("学期学分: %.1f    获得学分: %.1f    学期 GPA: %.2f",
+			term.Credits, term.EarnedCredits, term.GPA)}, []float64{0})
+		w.Gap(8)
+	}
+
+	w.Row(12, []string{fmt.Sprintf("累计学分: %.1f    获得学分: %.1f    累计 GPA: %.2f",
+		t.TotalCredits, t.EarnedCredits, t.CumulativeGPA)}, []float64{0})
+	w.Row(9, []string{"生成时间: " + t.GeneratedAt.Format("2006-01-02 15:04:05")}, []float64{0})
+	return w.Bytes()
+}
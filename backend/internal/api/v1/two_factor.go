@@ -0,0 +1,354 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/app"
+	"student-management-system/internal/middleware"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+	"student-management-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TwoFactorCodeRequest 提交两步验证码（验证器 App 上的 6 位验证码，登录时也可用备用码）
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorSetupRequest 设置两步验证；用“须设置两步验证”临时令牌调用时须提交管理员签发的设置码
+type TwoFactorSetupRequest struct {
+	EnrollmentCode string `json:"enrollment_code"`
+}
+
+// EnableTwoFactorRequest 启用两步验证；用“须设置两步验证”临时令牌调用时须同时提交设置码
+type EnableTwoFactorRequest struct {
+	Code           string `json:"code" binding:"required"`
+	EnrollmentCode string `json:"enrollment_code"`
+}
+
+// DisableTwoFactorRequest 关闭两步验证请求（须同时验证密码和验证码）
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// VerifyTwoFactor 登录第二步：用登录返回的临时令牌提交验证码或备用码，通过后签发正式令牌
+func VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.Preload("Role").First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "用户不存在"})
+		return
+	}
+	// 验证码错误与密码错误共用失败次数限制，防止暴力猜测验证码
+	if !loginAllowed(c, db, user.Username) {
+		return
+	}
+
+	usedBackup, err := service.VerifySecondFactor(db, &user, req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSecondFactor) || errors.Is(err, service.ErrTOTPNotSetUp) {
+			recordCredentialFailure(c, db, &user, service.LoginBadSecondFactor)
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "验证失败"})
+		return
+	}
+
+	if err := service.RecordLoginSuccess(db, user.Username); err != nil {
+		log.Printf("清除登录失败次数失败: %v", err)
+	}
+	recordLoginAudit(c, db, user.Username, user.ID, service.LoginSuccess)
+
+	var extra map[string]interface{}
+	if usedBackup {
+		extra = map[string]interface{}{
+			"backup_codes_remaining": service.RemainingBackupCodes(db, user.ID),
+		}
+	}
+	completeLogin(c, db, &user, "登录成功", extra)
+}
+
+// GetTwoFactorStatus 当前用户的两步验证状态
+func GetTwoFactorStatus(c *gin.Context) {
+	db := config.GetDB()
+	var user models.User
+	if err := db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	required, err := service.TwoFactorRequired(db, user.RoleID, config.TwoFactorRequiredPermissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询失败", "error": err.Error()})
+		return
+	}
+	data := gin.H{"enabled": user.TOTPEnabled, "required": required}
+	if user.TOTPEnabled {
+		data["backup_codes_remaining"] = service.RemainingBackupCodes(db, user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "获取成功", "data": data})
+}
+
+// SetupTwoFactor 生成新的 TOTP 密钥，返回密钥和用于生成二维码的 otpauth:// 地址
+// 可用正式令牌调用，也可用登录时返回的“须设置两步验证”临时令牌加管理员签发的设置码调用
+func SetupTwoFactor(c *gin.Context) {
+	enrolling := c.GetString(middleware.AuthPurposeKey) == utils.PurposeTwoFactorEnroll
+	var req TwoFactorSetupRequest
+	if enrolling {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+			return
+		}
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	if enrolling && !checkEnrollmentCode(c, db, &user, req.EnrollmentCode) {
+		return
+	}
+	secret, err := service.BeginTOTPSetup(db, &user)
+	if err != nil {
+		if errors.Is(err, service.ErrTOTPAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成密钥失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "请用验证器 App 扫描二维码，并提交显示的验证码以启用",
+		"data": gin.H{
+			"secret":      secret,
+			"otpauth_uri": utils.TOTPURI(config.TwoFactorIssuer, user.Username, secret),
+		},
+	})
+}
+
+// EnableTwoFactor 提交验证码确认密钥并启用两步验证，返回备用码（只显示这一次）
+// 用“须设置两步验证”临时令牌调用时须同时提交设置码（启用后作废），启用后同时完成登录并签发正式令牌
+func EnableTwoFactor(c *gin.Context) {
+	var req EnableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.Preload("Role").First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	enrolling := c.GetString(middleware.AuthPurposeKey) == utils.PurposeTwoFactorEnroll
+	if enrolling && !checkEnrollmentCode(c, db, &user, req.EnrollmentCode) {
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if enrolling {
+			if err := service.ConsumeEnrollmentCode(tx, user.ID, req.EnrollmentCode); err != nil {
+				return err
+			}
+		}
+		var err error
+		codes, err = service.EnableTOTP(tx, &user, req.Code)
+		return err
+	})
+	if err != nil {
+		respondTwoFactorError(c, err, "启用失败")
+		return
+	}
+
+	if enrolling {
+		if err := service.RecordLoginSuccess(db, user.Username); err != nil {
+			log.Printf("清除登录失败次数失败: %v", err)
+		}
+		recordLoginAudit(c, db, user.Username, user.ID, service.LoginSuccess)
+		completeLogin(c, db, &user, "两步验证已启用，登录成功", map[string]interface{}{"backup_codes": codes})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "两步验证已启用，请妥善保存备用码", "data": gin.H{"backup_codes": codes}})
+}
+
+// DisableTwoFactor 关闭两步验证（所在角色要求两步验证时不允许关闭）
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	// 密码与验证码错误计入登录失败次数，持有被盗访问令牌也不能无限次猜测
+	if !loginAllowed(c, db, user.Username) {
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		recordCredentialFailure(c, db, &user, service.LoginBadPassword)
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "密码错误"})
+		return
+	}
+	required, err := service.TwoFactorRequired(db, user.RoleID, config.TwoFactorRequiredPermissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "关闭失败", "error": err.Error()})
+		return
+	}
+	if required {
+		respondTwoFactorError(c, service.ErrTwoFactorRequired, "关闭失败")
+		return
+	}
+	if _, err := service.VerifySecondFactor(db, &user, req.Code); err != nil {
+		if errors.Is(err, service.ErrInvalidSecondFactor) {
+			recordCredentialFailure(c, db, &user, service.LoginBadSecondFactor)
+		}
+		respondTwoFactorError(c, err, "关闭失败")
+		return
+	}
+	if err := service.DisableTOTP(db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "关闭失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "两步验证已关闭"})
+}
+
+// RegenerateBackupCodes 重新生成备用码（须提交当前验证码，旧备用码全部作废）
+func RegenerateBackupCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	if !loginAllowed(c, db, user.Username) {
+		return
+	}
+	if _, err := service.VerifySecondFactor(db, &user, req.Code); err != nil {
+		if errors.Is(err, service.ErrInvalidSecondFactor) {
+			recordCredentialFailure(c, db, &user, service.LoginBadSecondFactor)
+		}
+		respondTwoFactorError(c, err, "生成失败")
+		return
+	}
+	codes, err := service.RegenerateBackupCodes(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "已生成新的备用码，请妥善保存", "data": gin.H{"backup_codes": codes}})
+}
+
+// AdminResetTwoFactor 重置用户的两步验证（用户丢失验证器且备用码用完时），并结束其全部会话
+func AdminResetTwoFactor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	db := config.GetDB()
+	if err := db.First(&models.User{}, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+		return
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := service.DisableTOTP(tx, uint(id)); err != nil {
+			return err
+		}
+		return service.RevokeUserTokens(tx, uint(id))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "重置失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "已重置两步验证"})
+}
+
+// AdminIssueTwoFactorEnrollment 为尚未启用两步验证的用户签发一次性设置码（24 小时有效，经其他渠道交给本人）
+// 所在角色要求两步验证的用户登录后须凭设置码才能完成设置，只知道密码不能自行设置
+func AdminIssueTwoFactorEnrollment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+		return
+	}
+	code, expires, err := service.IssueEnrollmentCode(config.GetDB(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "用户不存在"})
+			return
+		}
+		respondTwoFactorError(c, err, "签发失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已签发设置码，请经其他渠道交给本人",
+		"data":    gin.H{"enrollment_code": code, "expires_at": expires},
+	})
+}
+
+// checkEnrollmentCode 校验设置码；错误时与密码错误共用失败次数限制，防止暴力猜测
+func checkEnrollmentCode(c *gin.Context, db *gorm.DB, user *models.User, code string) bool {
+	if !loginAllowed(c, db, user.Username) {
+		return false
+	}
+	if err := service.CheckEnrollmentCode(user, code); err != nil {
+		recordCredentialFailure(c, db, user, service.LoginBadSecondFactor)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": err.Error()})
+		return false
+	}
+	return true
+}
+
+// recordCredentialFailure 密码、验证码或设置码错误：计入登录失败次数（与登录共用限制）并记录审计
+func recordCredentialFailure(c *gin.Context, db *gorm.DB, user *models.User, result string) {
+	if err := service.RecordLoginFailure(db, app.LoginPolicy, user.Username, c.ClientIP()); err != nil {
+		log.Printf("记录登录失败次数失败: %v", err)
+	}
+	recordLoginAudit(c, db, user.Username, user.ID, result)
+}
+
+func respondTwoFactorError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSecondFactor):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidEnrollmentCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": err.Error()})
+	case errors.Is(err, service.ErrTOTPAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+	case errors.Is(err, service.ErrTOTPNotSetUp), errors.Is(err, service.ErrTwoFactorRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": msg, "error": err.Error()})
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"log"
+
+	"student-management-system/config"
+	"student-management-system/internal/service"
+)
+
+// 应用的运行时组件：按配置创建的服务实例（认证链、统一身份认证客户端、角色权限缓存、密码与登录策略）
+// 与启动时的数据初始化（见 database.go）。config 只负责读取配置和连接数据库，不依赖 service。
+
+// OIDC 统一身份认证客户端，未启用时为 nil
+var OIDC *service.OIDCClient
+
+// Authenticators 密码登录的认证链（本地密码及已启用的 LDAP）
+var Authenticators *service.AuthChain
+
+// Permissions 角色权限缓存（失效广播在 InitDB 中按配置设置）
+var Permissions = service.NewPermissionCache(0)
+
+// loaded 是否已按配置创建服务实例
+var loaded bool
+
+// Load 加载并校验配置（见 config.Load），成功后按配置创建服务实例
+func Load(args []string) (*config.AppConfig, error) {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := newAuthChain(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	OIDC = nil
+	if cfg.OIDC.Enabled {
+		OIDC = service.NewOIDCClient(cfg.OIDC)
+	}
+	Authenticators = chain
+	Permissions = service.NewPermissionCache(cfg.PermissionCache.TTL.Duration)
+	loadPasswordPolicy()
+	loadLoginPolicy()
+	loaded = true
+	return cfg, nil
+}
+
+// current 当前配置；尚未加载时按默认值、.env 和环境变量加载（不解析命令行参数），加载失败时退出
+func current() *config.AppConfig {
+	if !loaded {
+		if _, err := Load(nil); err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+	}
+	return config.App
+}
+
+// newAuthChain 按配置创建密码登录的认证链
+func newAuthChain(cfg *config.AppConfig) (*service.AuthChain, error) {
+	authenticators := []service.Authenticator{service.LocalAuthenticator{}}
+	if cfg.LDAP.Enabled {
+		ldapAuth, err := service.NewLDAPAuthenticator(cfg.LDAP)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, ldapAuth)
+	}
+	chain, err := service.NewAuthChain(cfg.Auth, authenticators...)
+	if err != nil {
+		return nil, &config.ConfigError{Problems: []string{"auth 认证顺序无效：" + err.Error()}}
+	}
+	return chain, nil
+}
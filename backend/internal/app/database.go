@@ -0,0 +1,168 @@
+package app
+
+import (
+	"log"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
+
+	"gorm.io/gorm/clause"
+)
+
+// InitDB 连接数据库并迁移表结构（见 config.InitDB），设置权限缓存的失效广播，初始化默认数据
+func InitDB() {
+	cfg := current()
+	config.InitDB()
+
+	// 多实例部署时通过数据库表广播角色权限缓存失效
+	if pc := cfg.PermissionCache; pc.Bus == "db" {
+		Permissions.SetBus(service.NewDBInvalidationBus(config.DB, pc.PollInterval.Duration))
+	}
+
+	// 加密存储之前以明文保存的两步验证密钥
+	if n, err := service.SealTOTPSecrets(config.DB); err != nil {
+		log.Printf("警告: 加密两步验证密钥失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已加密 %d 个以明文保存的两步验证密钥", n)
+	}
+
+	// 初始化默认数据
+	initDefaultData()
+
+	// 按默认权限清单补齐内置角色（teacher、student、parent）的权限；
+	// 学生自助与家长门户的路由依赖这些权限，放在这里保证任何入口程序初始化数据库后都已补齐
+	applyRoleDefaults()
+}
+
+// initDefaultData 初始化默认数据
+func initDefaultData() {
+	// 创建默认角色（幂等）
+	roles := []models.Role{
+		{RoleName: "admin"},
+		{RoleName: "teacher"},
+		{RoleName: "student"},
+		{RoleName: "parent"},
+	}
+
+	// 使用 ON CONFLICT DO NOTHING（MySQL 下会使用 INSERT IGNORE）避免唯一键冲突报错
+	config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&roles)
+
+	log.Println("默认角色初始化完成")
+
+	// 初始化默认绩点对照表
+	initGradePointScale()
+
+	// 初始化默认学业预警规则
+	initAlertRules()
+}
+
+// initAlertRules 创建默认学业预警规则（已存在的规则保留学校自行调整的阈值）
+func initAlertRules() {
+	rules := []models.AlertRule{
+		{Code: service.RuleFailingScore, Name: "成绩不及格", Threshold: 60, WindowDays: 30, Severity: "warning", Enabled: true},
+		{Code: service.RuleAbsenceStreak, Name: "连续缺勤", Threshold: 3, WindowDays: 30, Severity: "warning", Enabled: true},
+		{Code: service.RuleGPADrop, Name: "GPA 下滑", Threshold: 0.5, Severity: "critical", Enabled: true},
+		{Code: service.RuleDiscipline, Name: "近期处分", WindowDays: 30, Severity: "critical", Enabled: true},
+	}
+	config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rules)
+}
+
+// SyncPermissions 把权限目录（由路由表生成，见 api.BuildRouter）同步到权限表，新增的权限授予 admin 角色
+// 已不被任何路由引用的权限标记为废弃并记录日志；须在 InitDB 之后调用
+func SyncPermissions(catalog []models.Permission) {
+	result, err := service.SyncPermissions(config.DB, catalog)
+	if err != nil {
+		log.Fatalf("同步权限目录失败: %v", err)
+	}
+	for _, code := range result.Obsolete {
+		log.Printf("权限 %s 已不被任何路由引用，已标记为废弃（确认无用后可从权限表删除）", code)
+	}
+
+	count := grantAdmin(result.Created)
+	if count >= 0 && len(result.Created) == 0 {
+		log.Printf("权限目录共 %d 个权限，admin 角色已有 %d 个权限", len(catalog), count)
+	}
+
+	// 权限表有变化时所有实例的权限缓存失效
+	if result.Changed {
+		Permissions.InvalidateAll()
+	}
+}
+
+// grantAdmin 为 admin 角色分配权限：admin 尚无任何权限时（首次启动）分配全部未废弃的权限，否则追加新建的权限。
+// 返回分配前 admin 角色的权限数，获取 admin 角色失败时返回 -1
+func grantAdmin(created []models.Permission) int64 {
+	var adminRole models.Role
+	if err := config.DB.Where("role_name = ?", "admin").First(&adminRole).Error; err != nil {
+		log.Printf("获取 admin 角色失败: %v", err)
+		return -1
+	}
+
+	count := config.DB.Model(&adminRole).Association("Permissions").Count()
+	if count == 0 {
+		var permissions []models.Permission
+		if err := config.DB.Where("obsolete = ?", false).Find(&permissions).Error; err != nil {
+			log.Printf("获取权限列表失败: %v", err)
+			return count
+		}
+		if err := config.DB.Model(&adminRole).Association("Permissions").Replace(permissions); err != nil {
+			log.Printf("为 admin 角色分配权限失败: %v", err)
+		} else {
+			log.Printf("已为 admin 角色分配 %d 个权限", len(permissions))
+		}
+	} else if len(created) > 0 {
+		// 版本升级新增的权限默认授予 admin，已有的权限分配保持不变
+		if err := config.DB.Model(&adminRole).Association("Permissions").Append(created); err != nil {
+			log.Printf("为 admin 角色追加新权限失败: %v", err)
+		} else {
+			log.Printf("已为 admin 角色追加 %d 个新权限", len(created))
+		}
+	}
+	return count
+}
+
+// applyRoleDefaults 按默认权限清单补齐内置角色的权限（每一项只补一次）
+// 首次启动或升级时权限目录尚未同步（见 SyncPermissions），先创建清单引用的权限，新建的权限同样授予 admin
+func applyRoleDefaults() {
+	created, err := service.EnsurePermissions(config.DB, permission.Models(permission.DefaultPermissions()))
+	if err != nil {
+		log.Printf("创建默认权限清单引用的权限失败: %v", err)
+		return
+	}
+	if len(created) > 0 {
+		grantAdmin(created)
+		Permissions.InvalidateAll()
+	}
+	for _, roleID := range service.ApplyRoleDefaults(config.DB, permission.Defaults) {
+		Permissions.Invalidate(roleID)
+	}
+}
+
+// initGradePointScale 初始化默认绩点对照表（4.0 制，仅在该方案不存在时创建）
+func initGradePointScale() {
+	var count int64
+	config.DB.Model(&models.GradePointScale{}).Where("scale = ?", service.DefaultGradeScale).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	scale := []models.GradePointScale{
+		{Scale: service.DefaultGradeScale, MinScore: 90, GradePoint: 4.0, Letter: "A"},
+		{Scale: service.DefaultGradeScale, MinScore: 85, GradePoint: 3.7, Letter: "A-"},
+		{Scale: service.DefaultGradeScale, MinScore: 82, GradePoint: 3.3, Letter: "B+"},
+		{Scale: service.DefaultGradeScale, MinScore: 78, GradePoint: 3.0, Letter: "B"},
+		{Scale: service.DefaultGradeScale, MinScore: 75, GradePoint: 2.7, Letter: "B-"},
+		{Scale: service.DefaultGradeScale, MinScore: 72, GradePoint: 2.3, Letter: "C+"},
+		{Scale: service.DefaultGradeScale, MinScore: 68, GradePoint: 2.0, Letter: "C"},
+		{Scale: service.DefaultGradeScale, MinScore: 64, GradePoint: 1.5, Letter: "C-"},
+		{Scale: service.DefaultGradeScale, MinScore: 60, GradePoint: 1.0, Letter: "D"},
+		{Scale: service.DefaultGradeScale, MinScore: 0, GradePoint: 0, Letter: "F"},
+	}
+	if err := config.DB.Create(&scale).Error; err != nil {
+		log.Printf("初始化绩点对照表失败: %v", err)
+		return
+	}
+	log.Printf("已初始化默认绩点对照表 (%s)", service.DefaultGradeScale)
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"student-management-system/internal/service"
+)
+
+// PasswordPolicy 当前生效的密码策略，由环境变量覆盖默认值：
+//   - PASSWORD_MIN_LENGTH        最短长度（默认 8）
+//   - PASSWORD_REQUIRED_CLASSES  必须包含的字符类型，逗号分隔：lower,upper,digit,symbol（默认 lower,digit）
+//   - PASSWORD_BLACKLIST_FILE    额外的弱密码清单文件，每行一个
+//   - PASSWORD_CHECK_USERNAME    是否禁止包含用户名（默认 true）
+//   - PASSWORD_HISTORY           不得与最近 N 个密码相同（默认 5，0 表示不检查）
+var PasswordPolicy = service.DefaultPasswordPolicy()
+
+// loadPasswordPolicy 从环境变量加载密码策略
+func loadPasswordPolicy() {
+	policy := service.DefaultPasswordPolicy()
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			policy.MinLength = n
+		} else {
+			log.Printf("警告: PASSWORD_MIN_LENGTH=%q 无效，使用默认值 %d", v, policy.MinLength)
+		}
+	}
+	if v := os.Getenv("PASSWORD_REQUIRED_CLASSES"); v != "" {
+		var classes []string
+		for _, class := range strings.Split(v, ",") {
+			class = strings.TrimSpace(class)
+			if class == "" {
+				continue
+			}
+			if !service.IsCharClass(class) {
+				log.Printf("警告: PASSWORD_REQUIRED_CLASSES 中的 %q 无效，已忽略", class)
+				continue
+			}
+			classes = append(classes, class)
+		}
+		policy.RequiredClasses = classes
+	}
+	if v := os.Getenv("PASSWORD_CHECK_USERNAME"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			policy.CheckUsername = b
+		}
+	}
+	if v := os.Getenv("PASSWORD_HISTORY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			policy.HistorySize = n
+		} else {
+			log.Printf("警告: PASSWORD_HISTORY=%q 无效，使用默认值 %d", v, policy.HistorySize)
+		}
+	}
+	if path := os.Getenv("PASSWORD_BLACKLIST_FILE"); path != "" {
+		if err := loadPasswordBlacklist(path, policy.Blacklist); err != nil {
+			log.Printf("警告: 读取弱密码清单失败: %v", err)
+		}
+	}
+
+	PasswordPolicy = policy
+}
+
+// loadPasswordBlacklist 读取弱密码清单（每行一个，忽略空行和 # 开头的注释）
+func loadPasswordBlacklist(path string, blacklist map[string]bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blacklist[strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
+
+// LoginPolicy 当前生效的登录失败限制策略，由环境变量覆盖默认值：
+//   - LOGIN_MAX_FAILURES     同一用户名连续失败多少次后锁定（默认 5）
+//   - LOGIN_IP_MAX_FAILURES  同一 IP 连续失败多少次后锁定（默认 50）
+//   - LOGIN_LOCKOUT_MINUTES  首次锁定时长（分钟，默认 15，再次锁定时翻倍）
+var LoginPolicy = service.DefaultLoginPolicy()
+
+// loadLoginPolicy 从环境变量加载登录失败限制策略
+func loadLoginPolicy() {
+	policy := service.DefaultLoginPolicy()
+	for _, item := range []struct {
+		key    string
+		target *int
+	}{
+		{"LOGIN_MAX_FAILURES", &policy.MaxFailures},
+		{"LOGIN_IP_MAX_FAILURES", &policy.IPMaxFailures},
+	} {
+		if v := os.Getenv(item.key); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				*item.target = n
+			} else {
+				log.Printf("警告: %s=%q 无效，使用默认值 %d", item.key, v, *item.target)
+			}
+		}
+	}
+	if v := os.Getenv("LOGIN_LOCKOUT_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			policy.Lockout = time.Duration(n) * time.Minute
+		} else {
+			log.Printf("警告: LOGIN_LOCKOUT_MINUTES=%q 无效，使用默认值 %s", v, policy.Lockout)
+		}
+	}
+	LoginPolicy = policy
+}
@@ -5,39 +5,74 @@ import (
     "strings"
 
     "student-management-system/config"
+    "student-management-system/internal/app"
     "student-management-system/internal/models"
+    "student-management-system/internal/service"
     "student-management-system/internal/utils"
 
     "github.com/gin-gonic/gin"
 )
 
+// passwordChangeRoutes 须修改密码（must_change_password）时仍允许访问的路由
+var passwordChangeRoutes = map[string]bool{
+	"/api/v1/auth/password": true,
+	"/api/v1/auth/me":       true,
+}
+
 // AuthMiddleware JWT认证中间件
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		claims, ok := bearerClaims(c)
+		if !ok {
+			return
+		}
+		// 两步验证流程中的临时令牌不能访问其他接口
+		if claims.Purpose != "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
-				"message": "请求头中auth为空",
+				"message": "请先完成两步验证",
 			})
 			c.Abort()
 			return
 		}
+		if authenticate(c, claims) {
+			c.Next()
+		}
+	}
+}
 
-		// Authorization: Bearer token
-		parts := strings.SplitN(authHeader, " ", 2)
-		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "请求头中auth格式有误",
-			})
-			c.Abort()
+// PartialAuthMiddleware 两步验证流程的接口：接受用途为 purposes 之一的临时令牌；
+// allowFull 为 true 时也接受正常的访问令牌（如已登录用户自行设置两步验证）
+func PartialAuthMiddleware(allowFull bool, purposes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := bearerClaims(c)
+		if !ok {
+			return
+		}
+		if claims.Purpose == "" {
+			if !allowFull {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":    401,
+					"message": "无效的Token",
+				})
+				c.Abort()
+				return
+			}
+			if authenticate(c, claims) {
+				c.Next()
+			}
 			return
 		}
 
-		// 解析token
-		claims, err := utils.ParseToken(parts[1])
-		if err != nil {
+		allowed := false
+		for _, p := range purposes {
+			if p == claims.Purpose {
+				allowed = true
+			}
+		}
+		var user models.User
+		if !allowed || config.GetDB().Select("id", "is_active", "token_version").First(&user, claims.UserID).Error != nil ||
+			!user.IsActive || claims.TokenVersion != user.TokenVersion {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
 				"message": "无效的Token",
@@ -45,28 +80,103 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-
-		// 将用户信息存储到上下文中
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role_id", claims.RoleID)
+		c.Set(AuthPurposeKey, claims.Purpose)
+		c.Next()
+	}
+}
 
-		// 查询该角色拥有的所有权限
-		var role models.Role
-		if err := config.GetDB().Preload("Permissions").First(&role, claims.RoleID).Error; err == nil {
-			// 构建权限映射表（map[string]bool）用于快速查找
-			permissionMap := make(map[string]bool)
-			for _, perm := range role.Permissions {
-				permissionMap[perm.Permission] = true
-			}
-			c.Set("permissions", permissionMap)
-		} else {
-			// 如果查询失败，设置空权限映射
-			c.Set("permissions", make(map[string]bool))
-		}
+// AuthPurposeKey 上下文中临时令牌的用途（正常访问令牌不设置）
+const AuthPurposeKey = "auth_purpose"
 
-		c.Next()
+// bearerClaims 解析 Authorization 头中的令牌，失败时返回 401 并中止请求
+func bearerClaims(c *gin.Context) (*utils.Claims, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "请求头中auth为空",
+		})
+		c.Abort()
+		return nil, false
+	}
+
+	// Authorization: Bearer token
+	parts := strings.SplitN(authHeader, " ", 2)
+	if !(len(parts) == 2 && parts[0] == "Bearer") {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "请求头中auth格式有误",
+		})
+		c.Abort()
+		return nil, false
+	}
+
+	// 解析token
+	claims, err := utils.ParseToken(parts[1])
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "无效的Token",
+		})
+		c.Abort()
+		return nil, false
 	}
+
+	return claims, true
+}
+
+// authenticate 校验正常访问令牌对应的用户、会话状态，并把用户信息与权限放入上下文；失败时返回 401/403 并中止请求
+func authenticate(c *gin.Context, claims *utils.Claims) bool {
+	// 令牌版本不一致（已改密、禁用或变更角色）、账号已禁用或会话已撤销（已登出）时令牌立即失效
+	db := config.GetDB()
+	var user models.User
+	if err := db.Select("id", "is_active", "must_change_password", "token_version").First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "用户不存在",
+		})
+		c.Abort()
+		return false
+	}
+	if !user.IsActive || claims.TokenVersion != user.TokenVersion ||
+		claims.SessionID == 0 || !service.TouchSession(db, claims.SessionID) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "Token已失效，请重新登录",
+		})
+		c.Abort()
+		return false
+	}
+
+	// 须修改初始密码的账号只能访问修改密码和查看本人信息的接口
+	if user.MustChangePassword && !passwordChangeRoutes[c.FullPath()] {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": "请先修改初始密码",
+			"data":    gin.H{"must_change_password": true},
+		})
+		c.Abort()
+		return false
+	}
+
+	// 将用户信息存储到上下文中
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("role_id", claims.RoleID)
+	c.Set("session_id", claims.SessionID)
+
+	// 该角色的有效权限（*service.PermissionSet，含继承、通配符与拒绝，来自进程内缓存，只读）
+	if permissionSet, err := app.Permissions.Permissions(db, claims.RoleID); err == nil {
+		c.Set("permissions", permissionSet)
+	} else {
+		// 如果查询失败，设置空权限集合
+		c.Set("permissions", &service.PermissionSet{})
+	}
+
+	return true
 }
 
 // RoleMiddleware 角色权限中间件（保留用于向后兼容）
@@ -126,16 +236,16 @@ func PermissionMiddleware(requiredPermission string) gin.HandlerFunc {
 			return
 		}
 
-		// 类型断言 (假设存的是 map[string]bool)
-		permissionMap, ok := perms.(map[string]bool)
+		// 类型断言 (AuthMiddleware 存的是 *service.PermissionSet)
+		permissionSet, ok := perms.(*service.PermissionSet)
 		if !ok {
 			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "权限格式错误"})
 			c.Abort()
 			return
 		}
 
-		// 检查权限是否存在
-		if _, ok := permissionMap[requiredPermission]; !ok {
+		// 检查权限是否存在（精确或通配符授权，且未被显式拒绝）
+		if !permissionSet.Has(requiredPermission) {
 			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权限访问"})
 			c.Abort()
 			return
@@ -144,3 +254,13 @@ func PermissionMiddleware(requiredPermission string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// HasPermission 判断当前请求用户是否具有指定权限（用于处理器内的细粒度判断）
+func HasPermission(c *gin.Context, permission string) bool {
+	perms, exists := c.Get("permissions")
+	if !exists {
+		return false
+	}
+	permissionSet, ok := perms.(*service.PermissionSet)
+	return ok && permissionSet.Has(permission)
+}
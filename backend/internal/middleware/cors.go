@@ -3,14 +3,16 @@ package middleware
 import (
 	"time"
 
+	"student-management-system/config"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware CORS中间件
+// CORSMiddleware CORS中间件（允许的来源见配置 cors.allow_origins）
 func CORSMiddleware() gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:8081", "http://localhost:3000"},
+		AllowOrigins:     config.Current().CORS.AllowOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DataScopeKey 上下文中的行级数据范围 (*service.DataScope)
+const DataScopeKey = "data_scope"
+
+// DataScopeMiddleware 将请求绑定到调用者的数据范围（须挂在 AuthMiddleware 之后）
+// 管理员账号（user_type 为 admin）或拥有 data:scope:all 权限的账号不受限；教师账号限定为本人授课课程、
+// 班主任班级与排课班级；其他账号（学生、家长应使用 /me 与 /parent 接口，以及未知或为空的 user_type）没有数据范围。
+// 默认拒绝：其他职员账号须通过角色授予 data:scope:all 才能访问全部数据
+func DataScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if HasPermission(c, permission.DataScopeAll.Code) {
+			c.Set(DataScopeKey, service.FullScope())
+			c.Next()
+			return
+		}
+
+		userID, _ := c.Get("user_id")
+		var user models.User
+		if err := config.GetDB().First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "用户不存在"})
+			c.Abort()
+			return
+		}
+
+		scope := service.EmptyScope()
+		switch user.UserType {
+		case "admin":
+			scope = service.FullScope()
+		case "teacher":
+			if user.UserID == 0 { // 未关联教师档案
+				break
+			}
+			loaded, err := service.LoadTeacherScope(config.GetDB(), user.UserID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "加载数据范围失败", "error": err.Error()})
+				c.Abort()
+				return
+			}
+			scope = loaded
+		}
+		c.Set(DataScopeKey, scope)
+		c.Next()
+	}
+}
+
+// GetDataScope 读取当前请求的数据范围；未经过 DataScopeMiddleware 时返回空范围（拒绝访问）
+func GetDataScope(c *gin.Context) *service.DataScope {
+	if v, ok := c.Get(DataScopeKey); ok {
+		if scope, ok := v.(*service.DataScope); ok {
+			return scope
+		}
+	}
+	return service.EmptyScope()
+}
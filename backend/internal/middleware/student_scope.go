@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 学生数据范围相关的上下文键
+const (
+	// ScopedStudentKey 当前请求允许访问的学生ID (uint)，学生数据处理器只读取该学生的数据
+	ScopedStudentKey = "scoped_student_id"
+	// ViewerTargetsKey 查看者本人可接收的通知目标 ([]string)，例如家长的 "parent:3"
+	ViewerTargetsKey = "viewer_notification_targets"
+)
+
+// GuardianMiddleware 家长访问子女数据的行级校验（须挂在 ParentMiddleware 之后）
+// 路径参数 :id 对应的学生不在当前家长的监护关系中时一律拒绝
+func GuardianMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parentID := c.GetUint("parent_id")
+		if parentID == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "仅家长账号可访问"})
+			c.Abort()
+			return
+		}
+
+		studentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil || studentID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的ID"})
+			c.Abort()
+			return
+		}
+		if !service.IsGuardian(config.GetDB(), parentID, uint(studentID)) {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "无权访问该学生的信息"})
+			c.Abort()
+			return
+		}
+
+		c.Set(ScopedStudentKey, uint(studentID))
+		c.Set(ViewerTargetsKey, []string{fmt.Sprintf("parent:%d", parentID)})
+		c.Next()
+	}
+}
+
+// SelfStudentMiddleware 学生本人自助接口：仅允许学生账号访问，数据范围固定为 User.UserID 关联的学生
+func SelfStudentMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, ok := currentProfileID(c, "student")
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "仅学生账号可访问"})
+			c.Abort()
+			return
+		}
+		c.Set(ScopedStudentKey, studentID)
+		c.Next()
+	}
+}
+
+// ParentMiddleware 仅允许家长账号访问，并将家长ID存入上下文 "parent_id"
+func ParentMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parentID, ok := currentProfileID(c, "parent")
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "仅家长账号可访问"})
+			c.Abort()
+			return
+		}
+		c.Set("parent_id", parentID)
+		c.Next()
+	}
+}
+
+// currentProfileID 当前登录用户关联的学生/教师/家长ID，用户类型不符或未关联时返回 false
+func currentProfileID(c *gin.Context, userType string) (uint, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	var user models.User
+	if err := config.GetDB().First(&user, userID).Error; err != nil {
+		return 0, false
+	}
+	if user.UserType != userType || user.UserID == 0 {
+		return 0, false
+	}
+	return user.UserID, true
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
@@ -11,12 +15,28 @@ type User struct {
 	IsActive bool   `gorm:"default:true" json:"is_active"`
 	UserID   uint   `json:"user_id"`                           // 关联学生、教师或家长 (多态关联)
 	UserType string `gorm:"type:varchar(20)" json:"user_type"` // "student", "teacher", "admin", "parent"
+
+	MustChangePassword bool `gorm:"default:false" json:"must_change_password"` // 下次登录须修改密码（如批量开通的初始密码）
+	TokenVersion       uint `gorm:"default:0" json:"-"`                        // 令牌版本，改密、禁用、换角色时递增，使已签发的令牌全部失效
+
+	// 认证方式顺序（逗号分隔，如 "ldap,local"），为空时按 user_type 或默认顺序
+	AuthMethods string `gorm:"type:varchar(100)" json:"auth_methods"`
+
+	// 两步验证（TOTP）
+	TOTPSecret   string `gorm:"column:totp_secret;type:varchar(255)" json:"-"` // 加密存储的 Base32 密钥（见 utils.SealSecret）；已生成但未启用时为待验证的密钥
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"column:totp_last_step;default:0" json:"-"` // 最近一次使用的时间步，防止验证码重放
+
+	// 管理员签发的两步验证设置码（哈希）：所在角色要求两步验证但尚未启用时，凭设置码才能自行设置
+	TOTPEnrollCode      string     `gorm:"column:totp_enroll_code;type:varchar(64)" json:"-"`
+	TOTPEnrollExpiresAt *time.Time `gorm:"column:totp_enroll_expires_at" json:"-"`
 }
 
 // 2. 角色表 (RBAC)
 type Role struct {
 	gorm.Model
 	RoleName    string       `gorm:"type:varchar(50);uniqueIndex;not null" json:"role_name"`
+	ParentID    *uint        `gorm:"index" json:"parent_id"`                                   // 继承的上级角色（如班主任继承教师），拥有其全部权限
 	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions,omitempty"` // 角色拥有的权限
 }
 
@@ -26,6 +46,7 @@ type Permission struct {
 	Name       string `gorm:"type:varchar(100);not null" json:"name"`                   // 权限名称 (e.g., "创建学生")
 	Permission string `gorm:"type:varchar(100);uniqueIndex;not null" json:"permission"` // 权限标识 (e.g., "student:create")
 	Group      string `gorm:"type:varchar(50)" json:"group"`                            // 分组 (e.g., "student")
+	Obsolete   bool   `gorm:"default:false" json:"obsolete"`                            // 已不被任何路由引用（启动时由路由表同步），保留已有的角色分配
 }
 
 // 角色-权限 关联表 (RolePermission)
@@ -47,19 +68,20 @@ type Student struct {
 	Address   string   `gorm:"type:varchar(255)" json:"address"`
 	ClassID   uint     `json:"class_id"` // 关联班级
 	Class     Class    `gorm:"foreignKey:ClassID" json:"class"`
-	UserID    uint     `json:"user_id"`                             // 关联登录用户 User (如果学生可以登录)
-	Parents   []Parent `gorm:"foreignKey:StudentID" json:"parents"` // 一对多关联家长
+	UserID    uint     `json:"user_id"`                                     // 关联登录用户 User (如果学生可以登录)
+	Parents   []Parent `gorm:"many2many:student_guardians;" json:"parents"` // 多对多关联家长 (监护关系见 StudentGuardian)
 }
 
 // 4. 家长表 (对应要求 1, 5)
 type Parent struct {
 	gorm.Model
-	StudentID uint    `gorm:"index" json:"student_id"`                       // 关联学生
-	Student   Student `gorm:"foreignKey:StudentID" json:"student,omitempty"` // 关联学生信息
-	Name      string  `gorm:"type:varchar(100)" json:"name"`
-	Phone     string  `gorm:"type:varchar(20);not null" json:"phone"`
-	Relation  string  `gorm:"type:varchar(20)" json:"relation"` // e.g., "父亲", "母亲"
-	UserID    uint    `json:"user_id"`                          // 关联登录用户 User (如果家长可以登录)
+	StudentID uint      `gorm:"index" json:"student_id"`                       // 主要关联学生 (兼容旧数据，全部子女见 Children)
+	Student   Student   `gorm:"foreignKey:StudentID" json:"student,omitempty"` // 关联学生信息
+	Name      string    `gorm:"type:varchar(100)" json:"name"`
+	Phone     string    `gorm:"type:varchar(20);not null" json:"phone"`
+	Relation  string    `gorm:"type:varchar(20)" json:"relation"`                       // e.g., "父亲", "母亲"
+	UserID    uint      `json:"user_id"`                                                // 关联登录用户 User (如果家长可以登录)
+	Children  []Student `gorm:"many2many:student_guardians;" json:"children,omitempty"` // 监护的全部学生
 }
 
 // 5. 教师表
@@ -90,16 +112,18 @@ type Course struct {
 	Credits       float64 `json:"credits"`                         // 学分
 	Capacity      int     `gorm:"default:50" json:"capacity"`      // 课程容量
 	EnrolledCount int     `gorm:"default:0" json:"enrolled_count"` // 已选人数
+	SemesterID    uint    `gorm:"index" json:"semester_id"`        // 开课学期 (关联 Semester, 0 表示不限学期)
 }
 
 // 8. 选课表 (学生和课程的中间表) (对应要求 2)
 type Enrollment struct {
 	gorm.Model
-	StudentID uint    `gorm:"index:idx_student_course,unique" json:"student_id"`
-	Student   Student `gorm:"foreignKey:StudentID" json:"Student"` // 明确指定JSON字段名
-	CourseID  uint    `gorm:"index:idx_student_course,unique" json:"course_id"`
-	Course    Course  `gorm:"foreignKey:CourseID" json:"Course"`               // 明确指定JSON字段名
-	Grades    []Grade `gorm:"foreignKey:EnrollmentID" json:"Grades,omitempty"` // 明确指定JSON字段名
+	StudentID  uint    `gorm:"index:idx_student_course,unique" json:"student_id"`
+	Student    Student `gorm:"foreignKey:StudentID" json:"Student"` // 明确指定JSON字段名
+	CourseID   uint    `gorm:"index:idx_student_course,unique" json:"course_id"`
+	Course     Course  `gorm:"foreignKey:CourseID" json:"Course"`               // 明确指定JSON字段名
+	Grades     []Grade `gorm:"foreignKey:EnrollmentID" json:"Grades,omitempty"` // 明确指定JSON字段名
+	SemesterID uint    `gorm:"index" json:"semester_id"`                        // 所属学期，由触发器根据课程自动填充
 }
 
 // 课程先修关系表 (对应课程依赖关系)
@@ -153,17 +177,18 @@ type Notification struct {
 // 13. 课程表 (排课)
 type Schedule struct {
 	gorm.Model
-	CourseID  uint    `gorm:"index" json:"course_id"` // 关联课程
-	Course    Course  `gorm:"foreignKey:CourseID" json:"course"`
-	ClassID   uint    `gorm:"index" json:"class_id"` // 关联班级
-	Class     Class   `gorm:"foreignKey:ClassID" json:"class"`
-	TeacherID uint    `gorm:"index" json:"teacher_id"` // 关联教师 (可从Course获取, 但显式存储更灵活)
-	Teacher   Teacher `gorm:"foreignKey:TeacherID" json:"teacher"`
-	DayOfWeek int     `json:"day_of_week"` // 星期几 (例如 1=周一, 7=周日)
-	StartTime string  `json:"start_time"`  // 节次或时间 (e.g., "08:00" 或 "1-2节")
-	EndTime   string  `json:"end_time"`    // (e.g., "09:40")
-	Location  string  `json:"location"`    // 上课地点 (e.g., "教5-101")
-	Semester  string  `json:"semester"`    // (可选) 学期 (e.g., "2025-Fall")
+	CourseID   uint    `gorm:"index" json:"course_id"` // 关联课程
+	Course     Course  `gorm:"foreignKey:CourseID" json:"course"`
+	ClassID    uint    `gorm:"index" json:"class_id"` // 关联班级
+	Class      Class   `gorm:"foreignKey:ClassID" json:"class"`
+	TeacherID  uint    `gorm:"index" json:"teacher_id"` // 关联教师 (可从Course获取, 但显式存储更灵活)
+	Teacher    Teacher `gorm:"foreignKey:TeacherID" json:"teacher"`
+	DayOfWeek  int     `json:"day_of_week"`              // 星期几 (例如 1=周一, 7=周日)
+	StartTime  string  `json:"start_time"`               // 节次或时间 (e.g., "08:00" 或 "1-2节")
+	EndTime    string  `json:"end_time"`                 // (e.g., "09:40")
+	Location   string  `json:"location"`                 // 上课地点 (e.g., "教5-101")
+	Semester   string  `json:"semester"`                 // (可选) 学期 (e.g., "2025-Fall")
+	SemesterID uint    `gorm:"index" json:"semester_id"` // 关联学期 (Semester)
 }
 
 // 14. 成绩审计日志表 (对应修改要求 - 数据看门狗)
@@ -176,3 +201,168 @@ type GradeAuditLog struct {
 	NewScore float64 `gorm:"type:decimal(5,2)" json:"new_score"`        // 修改后分数
 	Grade    Grade   `gorm:"foreignKey:GradeID" json:"grade,omitempty"` // 关联成绩记录
 }
+
+// 15. 课程候补表 (课程已满时按先到先得排队)
+type CourseWaitlist struct {
+	gorm.Model
+	CourseID  uint    `gorm:"index:idx_waitlist_course_pos" json:"course_id"`
+	Course    Course  `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	StudentID uint    `gorm:"index" json:"student_id"`
+	Student   Student `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	Position  int     `gorm:"index:idx_waitlist_course_pos" json:"position"`        // 候补顺序，越小越靠前
+	Status    string  `gorm:"type:varchar(20);default:waiting;index" json:"status"` // "waiting", "enrolled", "skipped", "cancelled"
+	Remark    string  `gorm:"type:varchar(255)" json:"remark"`                      // 转正/跳过原因
+}
+
+// 16. 学期表 (选课时间窗口与加退选截止时间)
+type Semester struct {
+	gorm.Model
+	Name            string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"` // 学期名称 (e.g., "2025-Fall")
+	StartDate       time.Time `gorm:"type:date" json:"start_date"`                       // 学期开始日期
+	EndDate         time.Time `gorm:"type:date" json:"end_date"`                         // 学期结束日期
+	EnrollStart     time.Time `json:"enroll_start"`                                      // 选课开始时间
+	EnrollEnd       time.Time `json:"enroll_end"`                                        // 选课结束时间（之后至加退选截止只能退课，候补仍可递补）
+	AddDropDeadline time.Time `json:"add_drop_deadline"`                                 // 加退选截止时间
+	IsCurrent       bool      `gorm:"default:false" json:"is_current"`                   // 是否为当前学期
+}
+
+// 17. 绩点对照表 (成绩 → 绩点，支持按方案配置多套对照规则)
+type GradePointScale struct {
+	gorm.Model
+	Scale      string  `gorm:"type:varchar(50);index;not null" json:"scale"` // 方案名称 (e.g., "standard")
+	MinScore   float64 `json:"min_score"`                                    // 分数下限 (含)
+	GradePoint float64 `json:"grade_point"`                                  // 对应绩点
+	Letter     string  `gorm:"type:varchar(10)" json:"letter"`               // 等级 (e.g., "A", "B+")
+}
+
+// 18. 学业预警规则表 (规则与阈值可由学校自行配置)
+type AlertRule struct {
+	gorm.Model
+	Code       string  `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"` // 规则类型 (e.g., "failing_score", "absence_streak")
+	Name       string  `gorm:"type:varchar(100)" json:"name"`
+	Threshold  float64 `json:"threshold"`                                        // 阈值，含义随规则类型而定
+	WindowDays int     `json:"window_days"`                                      // 统计时间范围（天），0 表示不限
+	Severity   string  `gorm:"type:varchar(20);default:warning" json:"severity"` // "info", "warning", "critical"
+	Enabled    bool    `gorm:"default:true" json:"enabled"`
+}
+
+// 19. 学业预警记录表
+type AcademicAlert struct {
+	gorm.Model
+	StudentID uint    `gorm:"index" json:"student_id"`
+	Student   Student `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	RuleCode  string  `gorm:"type:varchar(50);index" json:"rule_code"`
+	Severity  string  `gorm:"type:varchar(20)" json:"severity"`
+	Message   string  `gorm:"type:varchar(500)" json:"message"`
+	TeacherID uint    `gorm:"index" json:"teacher_id"`                           // 负责跟进的班主任 (Class.TeacherID)
+	Status    string  `gorm:"type:varchar(20);default:open;index" json:"status"` // "open", "acknowledged", "resolved"
+	DedupKey  string  `gorm:"type:varchar(191);uniqueIndex;not null" json:"-"`   // 去重键，同一问题只预警一次
+}
+
+// 20. 学生-监护人关联表 (一个家长可监护多个学生，一个学生可有多个监护人)
+// 说明：parents.student_id 仍表示主要关联学生，由触发器同步到本表
+type StudentGuardian struct {
+	StudentID uint      `gorm:"primaryKey" json:"student_id"`
+	ParentID  uint      `gorm:"primaryKey;index" json:"parent_id"`
+	Relation  string    `gorm:"type:varchar(20)" json:"relation"` // e.g., "父亲", "母亲"，为空时沿用家长表中的关系
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 21. 密码历史表 (用于禁止重复使用最近用过的密码)
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Password  string    `gorm:"type:varchar(255);not null" json:"-"` // 曾经使用过的密码哈希
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 22. 登录会话表 (每次登录一条，保存轮换中的刷新令牌哈希)
+type Session struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	RefreshHash  string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"` // 当前有效的刷新令牌哈希
+	PreviousHash string     `gorm:"type:char(64);index" json:"-"`                // 上一个刷新令牌哈希，再次出现说明令牌被盗用
+	TokenVersion uint       `json:"-"`                                           // 创建会话时用户的令牌版本
+	UserAgent    string     `gorm:"type:varchar(255)" json:"user_agent"`         // 登录设备（浏览器 User-Agent）
+	IP           string     `gorm:"type:varchar(45)" json:"ip"`                  // 登录IP
+	LastSeenAt   time.Time  `json:"last_seen_at"`                                // 最近一次使用会话的时间
+	ExpiresAt    time.Time  `json:"expires_at"`                                  // 刷新令牌过期时间
+	RevokedAt    *time.Time `json:"revoked_at"`                                  // 撤销时间（登出、改密等），为空表示有效
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// 23. 登录审计表 (记录每一次登录尝试)
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"type:varchar(50);index" json:"username"` // 提交的用户名（用户不存在时同样记录）
+	UserID    uint      `gorm:"index" json:"user_id"`                   // 匹配到的用户，0 表示用户不存在
+	IP        string    `gorm:"type:varchar(45);index" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"user_agent"`
+	Success   bool      `gorm:"index" json:"success"`
+	Result    string    `gorm:"type:varchar(30)" json:"result"` // "success", "bad_password", "unknown_user", "inactive", "locked", "throttled"
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// 24. 登录限制表 (按用户名、IP 统计连续失败次数与锁定状态)
+type LoginThrottle struct {
+	ThrottleKey  string     `gorm:"type:varchar(100);primaryKey" json:"key"` // "user:<用户名>"（超长时为 "user:sha256:<摘要>"）或 "ip:<IP>"
+	Failures     int        `json:"failures"`                                // 当前窗口内的连续失败次数
+	LockCount    int        `json:"lock_count"`                              // 已被锁定的次数，锁定时长随之翻倍
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// 25. 两步验证备用码表 (每个备用码只能使用一次)
+type BackupCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);not null" json:"-"` // 备用码的 SHA-256 哈希
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// 26. 外部身份表 (统一身份认证账号与本系统用户的关联)
+type UserIdentity struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Provider    string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_provider_subject" json:"subject"` // 身份提供方的 sub
+	Email       string    `gorm:"type:varchar(100)" json:"email"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// 27. 权限缓存失效记录 (多实例部署时广播角色权限变化，各实例轮询)
+type PermissionInvalidation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RoleID    uint      `gorm:"index" json:"role_id"` // 0 表示全部角色
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// 28. 角色权限规则表 (通配符授权与显式拒绝；精确授权仍使用 role_permissions)
+type RolePermissionRule struct {
+	gorm.Model
+	RoleID  uint   `gorm:"uniqueIndex:idx_role_rule;not null" json:"role_id"`
+	Pattern string `gorm:"type:varchar(100);uniqueIndex:idx_role_rule;not null" json:"pattern"` // 权限标识或通配符，如 admin:*、db:grades:*
+	Effect  string `gorm:"type:varchar(10);not null" json:"effect"`                             // allow（仅通配符）或 deny（拒绝优先于任何授权）
+}
+
+// 29. 已应用的内置角色默认权限 (默认权限清单中的每一项只补一次，管理员之后撤销的不再补回)
+type AppliedRoleDefault struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RoleName  string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_applied_role_default" json:"role_name"`
+	Pattern   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_applied_role_default" json:"pattern"`
+	Effect    string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_applied_role_default" json:"effect"` // allow 或 deny
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 30. 初始密码清单表 (批量开通账号后暂存，加密保存，下载一次或过期后删除)
+type CredentialSheet struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TokenHash string    `gorm:"type:char(64);uniqueIndex;not null" json:"-"` // 下载令牌的 SHA-256 哈希
+	Data      string    `gorm:"type:mediumtext;not null" json:"-"`           // 加密后的清单（JSON，含初始密码）
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
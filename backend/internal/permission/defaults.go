@@ -0,0 +1,63 @@
+package permission
+
+// RoleDefault 一个内置角色的默认权限
+type RoleDefault struct {
+	Role  string   // 角色名
+	Allow []string // 授权的权限标识或通配符
+	Deny  []string // 显式拒绝的权限标识或通配符
+}
+
+// Defaults 内置角色的默认权限清单
+// 启动时（app.InitDB，所有入口程序都会调用）按清单补齐各角色的权限：每一项只补一次，管理员之后撤销的不会再补回，清单新增的项在升级后补上；
+// POST /api/v1/admin/roles/:id/reset-permissions 把角色恢复为清单中的权限，GET .../permission-diff 查看与清单的差异。
+// admin 角色不在清单中：启动同步权限目录时新增的权限都授予 admin（见 app.SyncPermissions）。
+var Defaults = []RoleDefault{
+	{
+		// 教师：数据范围限定为本人授课课程、班主任班级与排课班级
+		Role: "teacher",
+		Allow: []string{
+			TranscriptRead.Code,
+			RankingRead.Code,
+			EnrollmentCreate.Code,
+			EnrollmentDelete.Code,
+		},
+	},
+	{
+		Role: "student",
+		Allow: []string{
+			SelfRecordRead.Code,
+			SelfEnrollment.Code,
+		},
+	},
+	{
+		Role: "parent",
+		Allow: []string{
+			ChildRecordRead.Code,
+		},
+	},
+}
+
+// DefaultPermissions 默认权限清单引用的权限（精确标识，不含通配符）
+func DefaultPermissions() []Permission {
+	var list []Permission
+	seen := make(map[string]bool)
+	for _, d := range Defaults {
+		for _, code := range append(append([]string{}, d.Allow...), d.Deny...) {
+			if p, ok := Lookup(code); ok && !seen[code] {
+				seen[code] = true
+				list = append(list, p)
+			}
+		}
+	}
+	return list
+}
+
+// DefaultsFor 角色的默认权限，不在清单中时第二个返回值为 false
+func DefaultsFor(role string) (RoleDefault, bool) {
+	for _, d := range Defaults {
+		if d.Role == role {
+			return d, true
+		}
+	}
+	return RoleDefault{}, false
+}
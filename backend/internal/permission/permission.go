@@ -0,0 +1,105 @@
+package permission
+
+import "student-management-system/internal/models"
+
+// 系统中的权限定义。路由在注册时引用这里的权限（见 internal/api/router.go），
+// 权限目录由路由表生成：只有被路由或处理器引用的权限才会同步到 permissions 表。
+
+// Permission 一项权限：标识、名称与分组
+type Permission struct {
+	Code  string // 权限标识，如 admin:user:read
+	Name  string // 权限名称，如 查看用户
+	Group string // 分组，如 admin
+}
+
+// Model 转换为 permissions 表的记录
+func (p Permission) Model() models.Permission {
+	return models.Permission{Name: p.Name, Permission: p.Code, Group: p.Group}
+}
+
+// 管理员权限
+var (
+	UserRead         = Permission{"admin:user:read", "查看用户", "admin"}
+	UserCreate       = Permission{"admin:user:create", "创建用户", "admin"}
+	UserUpdate       = Permission{"admin:user:update", "修改用户", "admin"}
+	UserDelete       = Permission{"admin:user:delete", "删除用户", "admin"}
+	RoleRead         = Permission{"admin:role:read", "查看角色", "admin"}
+	RoleCreate       = Permission{"admin:role:create", "创建角色", "admin"}
+	RoleUpdate       = Permission{"admin:role:update", "修改角色", "admin"}
+	RoleDelete       = Permission{"admin:role:delete", "删除角色", "admin"}
+	WaitlistRead     = Permission{"admin:waitlist:read", "查看候补名单", "admin"}
+	WaitlistUpdate   = Permission{"admin:waitlist:update", "调整候补名单", "admin"}
+	SemesterRead     = Permission{"admin:semester:read", "查看学期", "admin"}
+	SemesterCreate   = Permission{"admin:semester:create", "创建学期", "admin"}
+	SemesterUpdate   = Permission{"admin:semester:update", "修改学期", "admin"}
+	SemesterDelete   = Permission{"admin:semester:delete", "删除学期", "admin"}
+	AlertRead        = Permission{"admin:alert:read", "查看学业预警", "admin"}
+	AlertUpdate      = Permission{"admin:alert:update", "处理学业预警", "admin"}
+	AlertRun         = Permission{"admin:alert:run", "执行学业预警", "admin"}
+	GuardianRead     = Permission{"admin:guardian:read", "查看监护关系", "admin"}
+	GuardianUpdate   = Permission{"admin:guardian:update", "管理监护关系", "admin"}
+	DataScopeAll     = Permission{"data:scope:all", "不受数据范围限制", "admin"}
+	SessionRead      = Permission{"admin:session:read", "查看登录会话", "admin"}
+	SessionDelete    = Permission{"admin:session:delete", "强制结束会话", "admin"}
+	LoginAuditRead   = Permission{"admin:login:read", "查看登录审计", "admin"}
+	LoginAuditUnlock = Permission{"admin:login:unlock", "解除登录锁定", "admin"}
+)
+
+// Administration 管理员必备权限：拥有这些权限即可为账号分配角色、为角色授予任何权限，从而恢复其他全部权限。
+// 删除或禁用账号、变更角色、修改角色权限时，须保留至少一个启用且拥有这些权限的账号（见 service.WithAdminGuard）
+var Administration = []Permission{UserRead, UserUpdate, RoleRead, RoleUpdate}
+
+// 选课权限
+var (
+	EnrollmentCreate   = Permission{"enrollment:create", "选课", "enrollment"}
+	EnrollmentDelete   = Permission{"enrollment:delete", "退课", "enrollment"}
+	EnrollmentOverride = Permission{"enrollment:override", "选课时间外加退选", "enrollment"}
+)
+
+// 学业权限
+var (
+	TranscriptRead = Permission{"transcript:read", "查看成绩单", "academic"}
+	RankingRead    = Permission{"ranking:read", "查看排名", "academic"}
+)
+
+// 学生自助与家长门户权限（另须是学生本人或监护人，由 SelfStudentMiddleware、GuardianMiddleware 校验）
+var (
+	SelfRecordRead  = Permission{"self:record:read", "查看本人学业记录", "self"}
+	SelfEnrollment  = Permission{"self:enrollment:update", "自助选课退课", "self"}
+	ChildRecordRead = Permission{"guardian:child:read", "查看子女学业记录", "guardian"}
+)
+
+// All 全部权限定义（含暂未被路由引用的），按标识查找见 Lookup；新增权限时须一并加入
+var All = []Permission{
+	UserRead, UserCreate, UserUpdate, UserDelete,
+	RoleRead, RoleCreate, RoleUpdate, RoleDelete,
+	WaitlistRead, WaitlistUpdate,
+	SemesterRead, SemesterCreate, SemesterUpdate, SemesterDelete,
+	AlertRead, AlertUpdate, AlertRun,
+	GuardianRead, GuardianUpdate,
+	DataScopeAll,
+	SessionRead, SessionDelete,
+	LoginAuditRead, LoginAuditUnlock,
+	EnrollmentCreate, EnrollmentDelete, EnrollmentOverride,
+	TranscriptRead, RankingRead,
+	SelfRecordRead, SelfEnrollment, ChildRecordRead,
+}
+
+// Lookup 按标识查找权限定义
+func Lookup(code string) (Permission, bool) {
+	for _, p := range All {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return Permission{}, false
+}
+
+// Models 转换为 permissions 表的记录
+func Models(perms []Permission) []models.Permission {
+	list := make([]models.Permission, len(perms))
+	for i, p := range perms {
+		list[i] = p.Model()
+	}
+	return list
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLastAdmin 操作后将没有可用的管理员账号
+var ErrLastAdmin = errors.New("操作后将没有可用的管理员账号（启用且拥有用户与角色管理权限），已拒绝")
+
+// IsAdministrator 权限集合是否拥有全部管理员必备权限（见 permission.Administration）
+func IsAdministrator(set *PermissionSet) bool {
+	for _, p := range permission.Administration {
+		if !set.Has(p.Code) {
+			return false
+		}
+	}
+	return true
+}
+
+// CountAdministrators 统计可用的管理员账号：未删除、已启用、角色（含继承与拒绝）拥有全部管理员必备权限
+// 直接从数据库计算，不经过权限缓存，在事务中调用时能看到事务内未提交的修改
+func CountAdministrators(db *gorm.DB) (int64, error) {
+	var roleIDs []uint
+	if err := db.Model(&models.User{}).Where("is_active = ?", true).Distinct().Pluck("role_id", &roleIDs).Error; err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, roleID := range roleIDs {
+		set, err := LoadPermissionSet(db, roleID)
+		if err != nil {
+			return 0, err
+		}
+		if !IsAdministrator(set) {
+			continue
+		}
+		var count int64
+		if err := db.Model(&models.User{}).Where("role_id = ? AND is_active = ?", roleID, true).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// WithAdminGuard 在事务中执行可能影响管理员账号的修改（删除或禁用账号、变更角色、修改角色权限或继承关系），
+// 修改前有可用的管理员账号而修改后没有时回滚并返回 ErrLastAdmin。
+// 修改前已没有可用的管理员账号时不拦截，由 cmd/restore_admin 恢复。
+func WithAdminGuard(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 锁定启用的账号，避免两个管理员并发互相禁用时都以为对方仍可用
+		var locked []uint
+		if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("is_active = ?", true).Pluck("id", &locked).Error; err != nil {
+			return err
+		}
+		before, err := CountAdministrators(tx)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if before == 0 {
+			return nil
+		}
+		after, err := CountAdministrators(tx)
+		if err != nil {
+			return err
+		}
+		if after == 0 {
+			return ErrLastAdmin
+		}
+		return nil
+	})
+}
+
+// RestoreAdminResult 恢复管理员权限的结果
+type RestoreAdminResult struct {
+	RoleID         uint     // admin 角色
+	Granted        int      // 为 admin 角色补上的权限数
+	RemovedDeny    []string // 删除的 admin 角色拒绝规则
+	DetachedRole   bool     // 解除了 admin 角色的上级角色（上级角色的拒绝同样生效）
+	UserID         uint     // 恢复的账号
+	CreatedUser    bool     // 账号不存在，新建
+	RestoredUser   bool     // 账号已删除，恢复
+	PasswordReset  bool     // 重置了密码（之后须修改）
+	Administrators int64    // 恢复后可用的管理员账号数
+}
+
+// RestoreAdministrator 绕过管理接口直接在数据库中恢复管理员权限（应急使用，见 cmd/restore_admin）：
+// admin 角色拥有全部未废弃的权限、没有拒绝规则和上级角色；账号 username 启用（已删除的恢复、不存在的新建）并使用 admin 角色。
+// password 非空时重置密码（须符合策略，之后须修改）；新建账号时必须提供。账号的登录锁定一并解除，已签发的令牌失效
+func RestoreAdministrator(db *gorm.DB, username, password string, policy PasswordPolicy) (*RestoreAdminResult, error) {
+	result := &RestoreAdminResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Where("role_name = ?", "admin").First(&role).Error; err != nil {
+			return fmt.Errorf("获取 admin 角色失败: %w", err)
+		}
+		result.RoleID = role.ID
+
+		// 管理员必备权限须在权限表中且未废弃
+		for _, p := range permission.Administration {
+			perm := p.Model()
+			if err := tx.Unscoped().Where("permission = ?", p.Code).Attrs(perm).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+			if perm.Obsolete || perm.DeletedAt.Valid {
+				if err := tx.Unscoped().Model(&perm).Updates(map[string]interface{}{"obsolete": false, "deleted_at": nil}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		var missing []models.Permission
+		if err := tx.Where("obsolete = ?", false).
+			Where("id NOT IN (?)", tx.Table("role_permissions").Select("permission_id").Where("role_id = ?", role.ID)).
+			Find(&missing).Error; err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			if err := tx.Model(&role).Association("Permissions").Append(missing); err != nil {
+				return err
+			}
+		}
+		result.Granted = len(missing)
+		if err := tx.Model(&models.RolePermissionRule{}).Where("role_id = ? AND effect = ?", role.ID, EffectDeny).
+			Pluck("pattern", &result.RemovedDeny).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("role_id = ? AND effect = ?", role.ID, EffectDeny).
+			Delete(&models.RolePermissionRule{}).Error; err != nil {
+			return err
+		}
+		if role.ParentID != nil {
+			if err := tx.Model(&role).Update("parent_id", nil).Error; err != nil {
+				return err
+			}
+			result.DetachedRole = true
+		}
+
+		var user models.User
+		err := tx.Unscoped().Where("username = ?", username).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if password == "" {
+				return fmt.Errorf("账号 %s 不存在，新建账号须指定密码", username)
+			}
+			user = models.User{Username: username, RoleID: role.ID, IsActive: true, UserType: "admin"}
+			if err := SetPassword(tx, &user, password, policy, true); err != nil {
+				return err
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			result.CreatedUser = true
+		case err != nil:
+			return err
+		default:
+			result.RestoredUser = user.DeletedAt.Valid
+			if err := tx.Unscoped().Model(&user).Updates(map[string]interface{}{"is_active": true, "deleted_at": nil}).Error; err != nil {
+				return err
+			}
+			// 用户类型改为 admin 后原档案不再适用，按原类型解除关联
+			if user.UserType != "admin" {
+				if err := UnlinkProfile(tx, user.ID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&user).Updates(map[string]interface{}{"role_id": role.ID, "user_type": "admin"}).Error; err != nil {
+				return err
+			}
+			if password != "" {
+				if err := SetPassword(tx, &user, password, policy, true); err != nil {
+					return err
+				}
+				result.PasswordReset = true
+			}
+			if err := RevokeUserTokens(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		result.UserID = user.ID
+		if err := UnlockLogin(tx, username, ""); err != nil && !errors.Is(err, ErrNoLoginLock) {
+			return err
+		}
+
+		count, err := CountAdministrators(tx)
+		if err != nil {
+			return err
+		}
+		result.Administrators = count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 预警规则类型
+const (
+	RuleFailingScore  = "failing_score"  // 单项成绩不及格，阈值为及格线
+	RuleAbsenceStreak = "absence_streak" // 连续缺勤，阈值为连续次数
+	RuleGPADrop       = "gpa_drop"       // 学期 GPA 较上学期下降，阈值为降幅
+	RuleDiscipline    = "discipline"     // 近期受到处分，阈值不使用
+)
+
+// 预警处理状态
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+)
+
+// 考勤与奖惩记录中的取值
+const (
+	attendanceAbsent  = "缺席"
+	rewardTypePenalty = "处分"
+)
+
+// alertCandidate 规则命中的一条待预警记录
+type alertCandidate struct {
+	StudentID uint
+	Message   string
+	DedupKey  string
+}
+
+// ruleEvaluator 评估一条规则，返回命中的学生
+type ruleEvaluator func(db *gorm.DB, rule models.AlertRule, since string, scale string) ([]alertCandidate, error)
+
+var ruleEvaluators = map[string]ruleEvaluator{
+	RuleFailingScore:  evalFailingScore,
+	RuleAbsenceStreak: evalAbsenceStreak,
+	RuleGPADrop:       evalGPADrop,
+	RuleDiscipline:    evalDiscipline,
+}
+
+// IsKnownAlertRule 判断规则类型是否受支持
+func IsKnownAlertRule(code string) bool {
+	_, ok := ruleEvaluators[code]
+	return ok
+}
+
+// AlertRunResult 一次预警运行的结果
+type AlertRunResult struct {
+	Rules   int                    `json:"rules"`   // 执行的规则数
+	Matched int                    `json:"matched"` // 命中记录数（含此前已预警的）
+	Created []models.AcademicAlert `json:"created"` // 本次新增的预警
+}
+
+// RunAlerts 执行全部启用的预警规则
+// 新命中的记录写入 academic_alerts 并指派给班主任，同时通知学生家长；已预警过的问题不会重复生成
+func RunAlerts(db *gorm.DB, scale string) (*AlertRunResult, error) {
+	var rules []models.AlertRule
+	if err := db.Where("enabled = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	result := &AlertRunResult{Created: []models.AcademicAlert{}}
+	teachers := make(map[uint]uint)
+	for _, rule := range rules {
+		eval, ok := ruleEvaluators[rule.Code]
+		if !ok {
+			log.Printf("跳过未知的预警规则: %s", rule.Code)
+			continue
+		}
+		since := ""
+		if rule.WindowDays > 0 {
+			since = time.Now().AddDate(0, 0, -rule.WindowDays).Format("2006-01-02")
+		}
+
+		candidates, err := eval(db, rule, since, scale)
+		if err != nil {
+			return nil, fmt.Errorf("执行预警规则 %s 失败: %w", rule.Code, err)
+		}
+		result.Rules++
+		result.Matched += len(candidates)
+
+		for _, cand := range candidates {
+			teacherID, ok := teachers[cand.StudentID]
+			if !ok {
+				teacherID = headTeacherOf(db, cand.StudentID)
+				teachers[cand.StudentID] = teacherID
+			}
+			alert := models.AcademicAlert{
+				StudentID: cand.StudentID,
+				RuleCode:  rule.Code,
+				Severity:  rule.Severity,
+				Message:   cand.Message,
+				TeacherID: teacherID,
+				Status:    AlertOpen,
+				DedupKey:  cand.DedupKey,
+			}
+			res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			notifyParents(db, cand.StudentID, "学业预警："+rule.Name, cand.Message)
+			result.Created = append(result.Created, alert)
+		}
+	}
+	return result, nil
+}
+
+// StartAlertScheduler 启动后台任务，每天在 hour 点（本地时间）运行一次预警规则
+// ctx 取消后任务退出（正在运行的查询随之中止），返回的通道在任务退出后关闭
+func StartAlertScheduler(ctx context.Context, db *gorm.DB, hour int, scale string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			timer := time.NewTimer(time.Until(nextAlertRun(time.Now(), hour)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			result, err := RunAlerts(db.WithContext(ctx), scale)
+			if err != nil {
+				log.Printf("学业预警定时任务失败: %v", err)
+				continue
+			}
+			log.Printf("学业预警定时任务完成: 规则 %d 条, 命中 %d 条, 新增预警 %d 条", result.Rules, result.Matched, len(result.Created))
+		}
+	}()
+	return done
+}
+
+// nextAlertRun now 之后（不含 now）的下一个 hour 点
+func nextAlertRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// headTeacherOf 学生所在班级的班主任，未分班时为 0
+func headTeacherOf(db *gorm.DB, studentID uint) uint {
+	var teacherID uint
+	db.Raw(`SELECT cl.teacher_id FROM students s JOIN classes cl ON cl.id = s.class_id AND cl.deleted_at IS NULL
+        WHERE s.id = ?`, studentID).Scan(&teacherID)
+	return teacherID
+}
+
+// notifyParents 向学生的全部监护人发送通知
+func notifyParents(db *gorm.DB, studentID uint, title, content string) {
+	parentIDs, err := GuardianParentIDs(db, studentID)
+	if err != nil {
+		log.Printf("查询家长失败: student=%d, error: %v", studentID, err)
+		return
+	}
+	for _, parentID := range parentIDs {
+		notification := models.Notification{
+			Title:   title,
+			Content: content,
+			Target:  fmt.Sprintf("parent:%d", parentID),
+		}
+		if err := db.Create(&notification).Error; err != nil {
+			log.Printf("发送通知失败: parent=%d, error: %v", parentID, err)
+		}
+	}
+}
+
+// evalFailingScore 任一成绩项低于阈值
+func evalFailingScore(db *gorm.DB, rule models.AlertRule, since string, _ string) ([]alertCandidate, error) {
+	var rows []struct {
+		GradeID    uint
+		StudentID  uint
+		CourseName string
+		ScoreType  string
+		Score      float64
+	}
+	q := db.Table("grades g").
+		Select("g.id AS grade_id, e.student_id, c.course_name, g.score_type, g.score").
+		Joins("JOIN enrollments e ON e.id = g.enrollment_id AND e.deleted_at IS NULL").
+		Joins("JOIN courses c ON c.id = e.course_id").
+		Where("g.deleted_at IS NULL AND g.score < ?", rule.Threshold)
+	if since != "" {
+		q = q.Where("g.updated_at >= ?", since)
+	}
+	if err := q.Order("g.id ASC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]alertCandidate, 0, len(rows))
+	for _, r := range rows {
+		candidates = append(candidates, alertCandidate{
+			StudentID: r.StudentID,
+			Message:   fmt.Sprintf("课程《%s》%s为 %.1f 分，低于 %.0f 分", r.CourseName, r.ScoreType, r.Score, rule.Threshold),
+			DedupKey:  fmt.Sprintf("%s:grade:%d", RuleFailingScore, r.GradeID),
+		})
+	}
+	return candidates, nil
+}
+
+// evalAbsenceStreak 按日期顺序连续缺勤达到阈值次数（请假、迟到等其他状态会中断连续）
+func evalAbsenceStreak(db *gorm.DB, rule models.AlertRule, since string, _ string) ([]alertCandidate, error) {
+	var records []models.Attendance
+	q := db.Select("id, student_id, date, status")
+	if since != "" {
+		q = q.Where("date >= ?", since)
+	}
+	if err := q.Order("student_id ASC, date ASC, id ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	var candidates []alertCandidate
+	var current uint
+	var start string
+	streak := 0
+	flush := func() {
+		if streak > 0 && float64(streak) >= rule.Threshold {
+			candidates = append(candidates, alertCandidate{
+				StudentID: current,
+				Message:   fmt.Sprintf("自 %s 起连续缺勤 %d 次", start, streak),
+				DedupKey:  fmt.Sprintf("%s:%d:%s", RuleAbsenceStreak, current, start),
+			})
+		}
+		streak = 0
+	}
+	for _, r := range records {
+		if r.StudentID != current {
+			flush()
+			current = r.StudentID
+		}
+		if r.Status != attendanceAbsent {
+			flush()
+			continue
+		}
+		if streak == 0 {
+			start = r.Date
+		}
+		streak++
+	}
+	flush()
+	return candidates, nil
+}
+
+// evalGPADrop 学期加权 GPA 比上一学期下降达到阈值
+func evalGPADrop(db *gorm.DB, rule models.AlertRule, since string, scale string) ([]alertCandidate, error) {
+	scoreSQL, args := enrollmentScoreSQL(RankingOptions{ScoreType: TotalScoreType})
+	args = append(args, scale)
+
+	query := `WITH fs AS (` + scoreSQL + `),
+        term AS (
+            SELECT gp.student_id, gp.semester_id, sm.name AS semester_name, sm.start_date,
+                SUM(gp.point * c.credits) / SUM(c.credits) AS gpa
+            FROM (
+                SELECT fs.student_id, fs.course_id, fs.semester_id, ` + gradePointSQL + ` AS point
+                FROM fs WHERE fs.score IS NOT NULL
+            ) gp
+            JOIN courses c ON c.id = gp.course_id
+            JOIN semesters sm ON sm.id = gp.semester_id AND sm.deleted_at IS NULL
+            GROUP BY gp.student_id, gp.semester_id, sm.name, sm.start_date
+            HAVING SUM(c.credits) > 0
+        ),
+        seq AS (
+            SELECT term.*, LAG(term.gpa) OVER (PARTITION BY term.student_id ORDER BY term.start_date) AS prev_gpa
+            FROM term
+        )
+        SELECT student_id, semester_id, semester_name, ROUND(gpa, 2) AS gpa, ROUND(prev_gpa, 2) AS prev_gpa
+        FROM seq WHERE prev_gpa IS NOT NULL AND prev_gpa - gpa >= ?`
+	args = append(args, rule.Threshold)
+	if since != "" {
+		query += " AND start_date >= ?"
+		args = append(args, since)
+	}
+
+	var rows []struct {
+		StudentID    uint
+		SemesterID   uint
+		SemesterName string
+		GPA          float64 `gorm:"column:gpa"`
+		PrevGPA      float64 `gorm:"column:prev_gpa"`
+	}
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]alertCandidate, 0, len(rows))
+	for _, r := range rows {
+		candidates = append(candidates, alertCandidate{
+			StudentID: r.StudentID,
+			Message:   fmt.Sprintf("%s 学期 GPA 为 %.2f，较上学期 %.2f 下降 %.2f", r.SemesterName, r.GPA, r.PrevGPA, r.PrevGPA-r.GPA),
+			DedupKey:  fmt.Sprintf("%s:%d:%d", RuleGPADrop, r.StudentID, r.SemesterID),
+		})
+	}
+	return candidates, nil
+}
+
+// evalDiscipline 统计范围内受到处分
+func evalDiscipline(db *gorm.DB, _ models.AlertRule, since string, _ string) ([]alertCandidate, error) {
+	var records []models.RewardPunishment
+	q := db.Where("type = ?", rewardTypePenalty)
+	if since != "" {
+		q = q.Where("date >= ?", since)
+	}
+	if err := q.Order("id ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]alertCandidate, 0, len(records))
+	for _, r := range records {
+		candidates = append(candidates, alertCandidate{
+			StudentID: r.StudentID,
+			Message:   fmt.Sprintf("%s 受到处分：%s", r.Date, r.Description),
+			DedupKey:  fmt.Sprintf("%s:%d", RuleDiscipline, r.ID),
+		})
+	}
+	return candidates, nil
+}
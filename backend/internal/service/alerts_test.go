@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNextAlertRun(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	cases := []struct {
+		name string
+		now  time.Time
+		hour int
+		want time.Time
+	}{
+		{"当天未到", time.Date(2024, 3, 1, 1, 30, 0, 0, loc), 2, time.Date(2024, 3, 1, 2, 0, 0, 0, loc)},
+		{"正好整点", time.Date(2024, 3, 1, 2, 0, 0, 0, loc), 2, time.Date(2024, 3, 2, 2, 0, 0, 0, loc)},
+		{"当天已过", time.Date(2024, 3, 1, 23, 59, 0, 0, loc), 2, time.Date(2024, 3, 2, 2, 0, 0, 0, loc)},
+		{"跨月", time.Date(2024, 2, 29, 5, 0, 0, 0, loc), 0, time.Date(2024, 3, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		if got := nextAlertRun(tc.now, tc.hour); !got.Equal(tc.want) {
+			t.Errorf("%s: nextAlertRun = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// 取消 ctx 后定时任务退出，不等到下一次运行
+func TestAlertSchedulerStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	hour := (time.Now().Hour() + 12) % 24
+	done := StartAlertScheduler(ctx, nil, hour, DefaultGradeScale)
+
+	select {
+	case <-done:
+		t.Fatal("定时任务在取消前退出")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("取消后定时任务未退出")
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// 可插拔的密码认证：本地密码（bcrypt）、LDAP 目录绑定等
+
+// 认证方式名称（用于 auth.order 配置与 users.auth_methods）
+const (
+	AuthLocal = "local"
+	AuthLDAP  = "ldap"
+)
+
+var (
+	// ErrBadCredentials 该认证方式下用户不存在或密码错误，继续尝试下一种方式
+	ErrBadCredentials = errors.New("用户名或密码错误")
+	// ErrAuthUnavailable 认证服务（如 LDAP 服务器）无法访问
+	ErrAuthUnavailable = errors.New("认证服务暂不可用，请稍后重试")
+)
+
+// Authenticator 一种用户名 + 密码的认证方式
+type Authenticator interface {
+	// Name 认证方式名称，如 local、ldap
+	Name() string
+	// Authenticate 校验密码；user 为同名的本地用户（不存在时为 nil）
+	// 成功时返回登录的本地用户（已预加载 Role，可能是本次自动创建的）；
+	// 用户不存在或密码错误时返回 ErrBadCredentials，服务不可用时返回包装了 ErrAuthUnavailable 的错误
+	Authenticate(db *gorm.DB, username, password string, user *models.User) (*models.User, error)
+}
+
+// LocalAuthenticator 校验本地保存的 bcrypt 密码
+type LocalAuthenticator struct{}
+
+// Name 认证方式名称
+func (LocalAuthenticator) Name() string { return AuthLocal }
+
+// Authenticate 校验本地密码
+func (LocalAuthenticator) Authenticate(db *gorm.DB, username, password string, user *models.User) (*models.User, error) {
+	if user == nil || !utils.CheckPasswordHash(password, user.Password) {
+		return nil, ErrBadCredentials
+	}
+	return user, nil
+}
+
+// AuthChain 按配置的顺序依次尝试各认证方式，第一个成功的方式决定登录结果
+type AuthChain struct {
+	order          config.AuthOrder
+	authenticators map[string]Authenticator
+}
+
+// NewAuthChain 创建认证链；order 中引用的认证方式须在 authenticators 中
+func NewAuthChain(order config.AuthOrder, authenticators ...Authenticator) (*AuthChain, error) {
+	chain := &AuthChain{order: order, authenticators: make(map[string]Authenticator)}
+	for _, a := range authenticators {
+		chain.authenticators[a.Name()] = a
+	}
+	if len(order.Default) == 0 {
+		return nil, errors.New("默认认证顺序不能为空")
+	}
+	if err := chain.validate(order.Default); err != nil {
+		return nil, err
+	}
+	for userType, methods := range order.ByUserType {
+		if err := chain.validate(methods); err != nil {
+			return nil, fmt.Errorf("user_type %s：%w", userType, err)
+		}
+	}
+	return chain, nil
+}
+
+// ParseAuthMethods 解析逗号分隔的认证方式列表（如 "ldap,local"）
+func ParseAuthMethods(s string) []string {
+	var methods []string
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// ValidateMethods 校验用户的 auth_methods 设置（空字符串表示使用 user_type 或默认顺序）
+func (c *AuthChain) ValidateMethods(s string) error {
+	return c.validate(ParseAuthMethods(s))
+}
+
+func (c *AuthChain) validate(methods []string) error {
+	seen := make(map[string]bool)
+	for _, m := range methods {
+		if _, ok := c.authenticators[m]; !ok {
+			return fmt.Errorf("未知或未启用的认证方式 %q", m)
+		}
+		if seen[m] {
+			return fmt.Errorf("认证方式 %q 重复", m)
+		}
+		seen[m] = true
+	}
+	return nil
+}
+
+// Methods 用户适用的认证顺序；user 为 nil 表示尚无本地账号
+func (c *AuthChain) Methods(user *models.User) []string {
+	if user != nil {
+		if methods := ParseAuthMethods(user.AuthMethods); len(methods) > 0 {
+			return methods
+		}
+		if methods := c.order.ByUserType[user.UserType]; len(methods) > 0 {
+			return methods
+		}
+	}
+	return c.order.Default
+}
+
+// Authenticate 按顺序尝试各认证方式
+// 失败时第一个返回值为同名的本地用户（不存在时为 nil），供失败计数和审计使用；
+// 没有方式成功且有方式不可用时返回 ErrAuthUnavailable，否则返回 ErrBadCredentials
+func (c *AuthChain) Authenticate(db *gorm.DB, username, password string) (*models.User, error) {
+	var local *models.User
+	var user models.User
+	err := db.Where("username = ?", username).Preload("Role").First(&user).Error
+	switch {
+	case err == nil:
+		local = &user
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	unavailable := false
+	for _, name := range c.Methods(local) {
+		a, ok := c.authenticators[name]
+		if !ok {
+			// 用户的 auth_methods 引用了已停用的认证方式
+			continue
+		}
+		u, err := a.Authenticate(db, username, password, local)
+		switch {
+		case err == nil:
+			return u, nil
+		case errors.Is(err, ErrBadCredentials):
+			// 继续尝试下一种方式
+		case errors.Is(err, ErrAuthUnavailable):
+			log.Printf("认证方式 %s 不可用: %v", name, err)
+			unavailable = true
+		default:
+			return local, err
+		}
+	}
+	if unavailable {
+		return local, ErrAuthUnavailable
+	}
+	return local, ErrBadCredentials
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// 用户名模板默认值与可用占位符
+const (
+	DefaultStudentUsernamePattern = "{student_code}"
+	DefaultParentUsernamePattern  = "{student_code}_p{seq}"
+	DefaultInitialPasswordLength  = 10
+
+	// CredentialSheetTTL 初始密码清单的有效期，过期或下载一次后即销毁
+	CredentialSheetTTL = 30 * time.Minute
+)
+
+// ErrRoleMissing 缺少开通账号所需的角色
+var ErrRoleMissing = errors.New("缺少 student 或 parent 角色，请先初始化角色数据")
+
+// BulkAccountOptions 批量开通账号的选项
+//
+// 用户名模板占位符：
+//   - {student_code} 学号（家长账号取其子女的学号）
+//   - {id}           档案ID
+//   - {class_id}     班级ID
+//   - {phone}        家长手机号（仅家长）
+//   - {seq}          家长在该子女监护人中的序号，从 1 开始（仅家长）
+type BulkAccountOptions struct {
+	ClassID        uint
+	Students       bool   // 为班级学生开通账号
+	Parents        bool   // 为学生的监护人开通账号
+	StudentPattern string // 学生用户名模板，默认 DefaultStudentUsernamePattern
+	ParentPattern  string // 家长用户名模板，默认 DefaultParentUsernamePattern
+	PasswordLength int    // 初始密码长度，默认 DefaultInitialPasswordLength
+}
+
+// IssuedCredential 新开通账号的初始凭据（明文密码只出现在一次性清单中）
+type IssuedCredential struct {
+	UserID      uint   `json:"user_id"`
+	UserType    string `json:"user_type"`
+	ProfileID   uint   `json:"profile_id"`
+	Name        string `json:"name"`
+	StudentCode string `json:"student_code"` // 学生学号；家长为其子女的学号
+	Username    string `json:"username"`
+	Password    string `json:"-"`
+}
+
+// SkippedProfile 未开通账号的档案及原因
+type SkippedProfile struct {
+	UserType  string `json:"user_type"`
+	ProfileID uint   `json:"profile_id"`
+	Name      string `json:"name"`
+	Username  string `json:"username,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// BulkAccountResult 批量开通结果
+type BulkAccountResult struct {
+	Created []IssuedCredential `json:"created"`
+	Skipped []SkippedProfile   `json:"skipped"`
+}
+
+// renderUsername 按模板生成用户名
+func renderUsername(pattern string, values map[string]string) string {
+	pairs := make([]string, 0, len(values)*2)
+	for k, v := range values {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.TrimSpace(strings.NewReplacer(pairs...).Replace(pattern))
+}
+
+// hasAccount 档案是否已关联有效账号
+func hasAccount(db *gorm.DB, userID uint) bool {
+	if userID == 0 {
+		return false
+	}
+	var count int64
+	db.Model(&models.User{}).Where("id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// ProvisionClassAccounts 为班级学生及其家长批量开通账号
+// 已有账号的档案、用户名为空/过长/已被占用的档案会被跳过；其余账号在同一事务中创建，
+// 每个账号使用随机初始密码并标记为下次登录须修改密码
+func ProvisionClassAccounts(db *gorm.DB, opts BulkAccountOptions) (*BulkAccountResult, error) {
+	if opts.StudentPattern == "" {
+		opts.StudentPattern = DefaultStudentUsernamePattern
+	}
+	if opts.ParentPattern == "" {
+		opts.ParentPattern = DefaultParentUsernamePattern
+	}
+	if opts.PasswordLength <= 0 {
+		opts.PasswordLength = DefaultInitialPasswordLength
+	}
+
+	roles := make(map[string]uint)
+	var roleRows []models.Role
+	if err := db.Where("role_name IN ?", []string{"student", "parent"}).Find(&roleRows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range roleRows {
+		roles[r.RoleName] = r.ID
+	}
+	if (opts.Students && roles["student"] == 0) || (opts.Parents && roles["parent"] == 0) {
+		return nil, ErrRoleMissing
+	}
+
+	var students []models.Student
+	if err := db.Where("class_id = ?", opts.ClassID).Order("id ASC").Find(&students).Error; err != nil {
+		return nil, err
+	}
+
+	result := &BulkAccountResult{Created: []IssuedCredential{}, Skipped: []SkippedProfile{}}
+	taken := make(map[string]bool)
+	// plan 检查用户名并登记待开通账号
+	plan := func(cred IssuedCredential, hasUser bool) {
+		skip := SkippedProfile{UserType: cred.UserType, ProfileID: cred.ProfileID, Name: cred.Name, Username: cred.Username}
+		switch {
+		case hasUser:
+			skip.Reason = "已有账号"
+		case cred.Username == "":
+			skip.Reason = "用户名为空"
+		case len(cred.Username) > 50:
+			skip.Reason = "用户名超过 50 个字符"
+		case taken[cred.Username]:
+			skip.Reason = "用户名与本批次其他账号重复"
+		default:
+			var count int64
+			db.Unscoped().Model(&models.User{}).Where("username = ?", cred.Username).Count(&count)
+			if count > 0 {
+				skip.Reason = "用户名已存在"
+			}
+		}
+		if skip.Reason != "" {
+			result.Skipped = append(result.Skipped, skip)
+			return
+		}
+		taken[cred.Username] = true
+		result.Created = append(result.Created, cred)
+	}
+
+	seenParents := make(map[uint]bool)
+	for _, s := range students {
+		values := map[string]string{
+			"student_code": s.StudentID,
+			"id":           fmt.Sprint(s.ID),
+			"class_id":     fmt.Sprint(s.ClassID),
+		}
+		if opts.Students {
+			plan(IssuedCredential{
+				UserType:    "student",
+				ProfileID:   s.ID,
+				Name:        s.Name,
+				StudentCode: s.StudentID,
+				Username:    renderUsername(opts.StudentPattern, values),
+			}, hasAccount(db, s.UserID))
+		}
+		if !opts.Parents {
+			continue
+		}
+
+		parentIDs, err := GuardianParentIDs(db, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i, parentID := range parentIDs {
+			if seenParents[parentID] {
+				continue
+			}
+			seenParents[parentID] = true
+			var parent models.Parent
+			if err := db.First(&parent, parentID).Error; err != nil {
+				continue
+			}
+			values["id"] = fmt.Sprint(parent.ID)
+			values["phone"] = parent.Phone
+			values["seq"] = fmt.Sprint(i + 1)
+			plan(IssuedCredential{
+				UserType:    "parent",
+				ProfileID:   parent.ID,
+				Name:        parent.Name,
+				StudentCode: s.StudentID,
+				Username:    renderUsername(opts.ParentPattern, values),
+			}, hasAccount(db, parent.UserID))
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range result.Created {
+			cred := &result.Created[i]
+			password, err := utils.RandomPassword(opts.PasswordLength)
+			if err != nil {
+				return err
+			}
+			hashed, err := utils.HashPassword(password)
+			if err != nil {
+				return err
+			}
+			user := models.User{
+				Username:           cred.Username,
+				Password:           hashed,
+				RoleID:             roles[cred.UserType],
+				IsActive:           true,
+				UserType:           cred.UserType,
+				MustChangePassword: true,
+			}
+			if err := CreateAccount(tx, &user, cred.ProfileID, nil); err != nil {
+				return fmt.Errorf("开通账号 %s 失败: %w", cred.Username, err)
+			}
+			cred.UserID = user.ID
+			cred.Password = password
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ErrCredentialSheetNotFound 密码清单不存在、已下载或已过期
+var ErrCredentialSheetNotFound = errors.New("密码清单不存在、已下载或已过期")
+
+// credentialSheetRow 清单中的一行（IssuedCredential 序列化时不含密码，清单中需要保存）
+type credentialSheetRow struct {
+	IssuedCredential
+	Password string `json:"password"`
+}
+
+// StoreCredentialSheet 暂存初始密码清单，返回一次性下载令牌
+// 清单加密后存入数据库（多实例部署或重启后仍可下载），令牌只保存哈希；下载一次或超过 CredentialSheetTTL 后删除
+func StoreCredentialSheet(db *gorm.DB, rows []IssuedCredential) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+
+	stored := make([]credentialSheetRow, len(rows))
+	for i, r := range rows {
+		stored[i] = credentialSheetRow{IssuedCredential: r, Password: r.Password}
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	sealed, err := utils.SealSecret(string(data))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	// 顺带清理过期未下载的清单
+	if err := db.Where("expires_at < ?", now).Delete(&models.CredentialSheet{}).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	sheet := models.CredentialSheet{TokenHash: utils.HashToken(token), Data: sealed, ExpiresAt: now.Add(CredentialSheetTTL)}
+	if err := db.Create(&sheet).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return token, sheet.ExpiresAt, nil
+}
+
+// TakeCredentialSheet 取出并删除初始密码清单；令牌无效、已下载或已过期时返回 ErrCredentialSheetNotFound
+func TakeCredentialSheet(db *gorm.DB, token string) ([]IssuedCredential, error) {
+	var sheet models.CredentialSheet
+	err := db.Where("token_hash = ?", utils.HashToken(token)).First(&sheet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCredentialSheetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 删除成功的请求才能取得清单，同时下载时只有一个请求成功
+	res := db.Delete(&models.CredentialSheet{}, sheet.ID)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || time.Now().After(sheet.ExpiresAt) {
+		return nil, ErrCredentialSheetNotFound
+	}
+
+	data, err := utils.OpenSecret(sheet.Data)
+	if err != nil {
+		return nil, err
+	}
+	var stored []credentialSheetRow
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+	rows := make([]IssuedCredential, len(stored))
+	for i, r := range stored {
+		rows[i] = r.IssuedCredential
+		rows[i].Password = r.Password
+	}
+	return rows, nil
+}
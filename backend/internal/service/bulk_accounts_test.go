@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+)
+
+// 密码清单加密存入数据库，只能下载一次，过期后不能下载
+func TestCredentialSheetOneTime(t *testing.T) {
+	db := openTestDB(t, &models.CredentialSheet{})
+	if err := utils.SetSecretKey("test-encryption-key"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []IssuedCredential{{UserID: 1, UserType: "student", Name: "张三", StudentCode: "S001", Username: "S001", Password: "Init-pass-1"}}
+	token, _, err := StoreCredentialSheet(db, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet models.CredentialSheet
+	if err := db.Where("token_hash = ?", utils.HashToken(token)).First(&sheet).Error; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sheet.Data, "Init-pass-1") || !utils.IsSealed(sheet.Data) {
+		t.Fatalf("清单未加密保存: %q", sheet.Data)
+	}
+
+	got, err := TakeCredentialSheet(db, token)
+	if err != nil || len(got) != 1 || got[0].Password != "Init-pass-1" || got[0].Username != "S001" {
+		t.Fatalf("TakeCredentialSheet = %+v, %v", got, err)
+	}
+	if _, err := TakeCredentialSheet(db, token); !errors.Is(err, ErrCredentialSheetNotFound) {
+		t.Fatalf("第二次下载 = %v, want %v", err, ErrCredentialSheetNotFound)
+	}
+
+	expired, _, err := StoreCredentialSheet(db, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&models.CredentialSheet{}).Where("token_hash = ?", utils.HashToken(expired)).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TakeCredentialSheet(db, expired); !errors.Is(err, ErrCredentialSheetNotFound) {
+		t.Fatalf("过期清单 = %v, want %v", err, ErrCredentialSheetNotFound)
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 候补状态
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistEnrolled  = "enrolled"
+	WaitlistSkipped   = "skipped"
+	WaitlistCancelled = "cancelled"
+)
+
+// 选课相关错误，与 sp_enroll_student 的 p_status 对应
+var (
+	ErrAlreadyEnrolled   = errors.New("已经选过该课程")
+	ErrPrereqNotMet      = errors.New("未完成先修课程要求")
+	ErrCourseFull        = errors.New("课程已满")
+	ErrEnrollFailed      = errors.New("选课失败")
+	ErrNotEnrolled       = errors.New("未选该课程")
+	ErrAlreadyWaitlisted = errors.New("已在候补名单中")
+)
+
+// Enroll 调用 sp_enroll_student 为学生选课
+// 存储过程内部自行开启事务，因此不能在外部事务中调用
+func Enroll(db *gorm.DB, studentID, courseID uint) error {
+	var out struct {
+		Status  int
+		Message string
+	}
+	err := db.Connection(func(conn *gorm.DB) error {
+		// OUT 参数是会话变量，必须在同一连接上读取
+		if err := conn.Exec("CALL sp_enroll_student(?, ?, @status, @message)", studentID, courseID).Error; err != nil {
+			return err
+		}
+		return conn.Raw("SELECT @status AS status, @message AS message").Scan(&out).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	switch out.Status {
+	case 0:
+		return nil
+	case 2:
+		return ErrPrereqNotMet
+	case 3:
+		return ErrCourseFull
+	default:
+		if out.Message == ErrAlreadyEnrolled.Error() {
+			return ErrAlreadyEnrolled
+		}
+		return ErrEnrollFailed
+	}
+}
+
+// EnrollOrWaitlist 选课，课程已满时自动加入候补名单
+// 成功选课时返回的候补记录为 nil
+func EnrollOrWaitlist(db *gorm.DB, studentID, courseID uint) (*models.CourseWaitlist, error) {
+	err := Enroll(db, studentID, courseID)
+	if !errors.Is(err, ErrCourseFull) {
+		return nil, err
+	}
+	return JoinWaitlist(db, studentID, courseID)
+}
+
+// JoinWaitlist 将学生加入课程候补名单队尾
+func JoinWaitlist(db *gorm.DB, studentID, courseID uint) (*models.CourseWaitlist, error) {
+	var entry models.CourseWaitlist
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 锁定课程行，保证同一课程的候补顺序号不重复
+		var course models.Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&course, courseID).Error; err != nil {
+			return err
+		}
+
+		var exists int64
+		tx.Model(&models.CourseWaitlist{}).
+			Where("course_id = ? AND student_id = ? AND status = ?", courseID, studentID, WaitlistWaiting).
+			Count(&exists)
+		if exists > 0 {
+			return ErrAlreadyWaitlisted
+		}
+
+		var maxPos int
+		tx.Model(&models.CourseWaitlist{}).Where("course_id = ?", courseID).
+			Select("COALESCE(MAX(position), 0)").Scan(&maxPos)
+
+		entry = models.CourseWaitlist{
+			CourseID:  courseID,
+			StudentID: studentID,
+			Position:  maxPos + 1,
+			Status:    WaitlistWaiting,
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Drop 退课（软删除选课记录），并尝试让候补学生递补
+func Drop(db *gorm.DB, studentID, courseID uint) error {
+	result := db.Where("student_id = ? AND course_id = ?", studentID, courseID).Delete(&models.Enrollment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotEnrolled
+	}
+
+	if _, err := PromoteWaitlist(db, courseID); err != nil {
+		log.Printf("课程 %d 候补递补失败: %v", courseID, err)
+	}
+	return nil
+}
+
+// PromoteWaitlist 在课程有空余名额时按候补顺序自动选课
+// 不再满足先修要求或因其他原因无法选课的学生会被跳过；加退选截止后不再递补；返回本次成功递补的候补记录
+func PromoteWaitlist(db *gorm.DB, courseID uint) ([]models.CourseWaitlist, error) {
+	if err := CheckDropWindow(db, courseID); err != nil {
+		if errors.Is(err, ErrAddDropClosed) || errors.Is(err, ErrEnrollNotOpen) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var promoted []models.CourseWaitlist
+	for {
+		var course models.Course
+		if err := db.First(&course, courseID).Error; err != nil {
+			return promoted, err
+		}
+		if course.EnrolledCount >= course.Capacity {
+			return promoted, nil
+		}
+
+		var entry models.CourseWaitlist
+		err := db.Where("course_id = ? AND status = ?", courseID, WaitlistWaiting).
+			Order("position ASC, id ASC").First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return promoted, nil
+		}
+		if err != nil {
+			return promoted, err
+		}
+
+		// 更新候补状态失败时返回错误，否则同一条记录会被再次选中而无限循环
+		switch err := Enroll(db, entry.StudentID, courseID); {
+		case err == nil:
+			marked, err := markWaitlist(db, entry.ID, WaitlistEnrolled, "候补递补成功")
+			if err != nil {
+				return promoted, err
+			}
+			if marked {
+				entry.Status = WaitlistEnrolled
+				promoted = append(promoted, entry)
+				notifyStudent(db, entry.StudentID, "候补选课成功",
+					fmt.Sprintf("课程《%s》有空余名额，你已从候补名单自动选上该课程。", course.CourseName))
+			}
+		case errors.Is(err, ErrAlreadyEnrolled):
+			if _, err := markWaitlist(db, entry.ID, WaitlistEnrolled, "已在课程中"); err != nil {
+				return promoted, err
+			}
+		case errors.Is(err, ErrPrereqNotMet):
+			marked, err := markWaitlist(db, entry.ID, WaitlistSkipped, ErrPrereqNotMet.Error())
+			if err != nil {
+				return promoted, err
+			}
+			if marked {
+				notifyStudent(db, entry.StudentID, "候补选课未成功",
+					fmt.Sprintf("课程《%s》有空余名额，但你未完成先修课程要求，已移出候补名单。", course.CourseName))
+			}
+		case errors.Is(err, ErrCourseFull):
+			// 名额已被其他请求占用
+			return promoted, nil
+		case errors.Is(err, ErrEnrollFailed):
+			// 其他原因无法选课（如学生已被删除）：移出候补名单，否则队首一直失败，后面的候补无法递补
+			marked, err := markWaitlist(db, entry.ID, WaitlistSkipped, err.Error())
+			if err != nil {
+				return promoted, err
+			}
+			if marked {
+				notifyStudent(db, entry.StudentID, "候补选课未成功",
+					fmt.Sprintf("课程《%s》有空余名额，但系统无法为你选课，已移出候补名单，请联系教务处理。", course.CourseName))
+			}
+		default:
+			// 数据库或连接错误：保留候补记录，下次递补时重试
+			return promoted, err
+		}
+	}
+}
+
+// ReorderWaitlist 按给定的候补记录ID顺序重排课程的候补名单
+// entryIDs 必须恰好包含该课程当前所有等待中的候补记录
+func ReorderWaitlist(db *gorm.DB, courseID uint, entryIDs []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var course models.Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&course, courseID).Error; err != nil {
+			return err
+		}
+
+		var waiting []uint
+		if err := tx.Model(&models.CourseWaitlist{}).
+			Where("course_id = ? AND status = ?", courseID, WaitlistWaiting).
+			Pluck("id", &waiting).Error; err != nil {
+			return err
+		}
+		if !sameIDSet(waiting, entryIDs) {
+			return errors.New("候补记录列表与当前候补名单不一致")
+		}
+
+		var minPos int
+		tx.Model(&models.CourseWaitlist{}).
+			Where("course_id = ? AND status = ?", courseID, WaitlistWaiting).
+			Select("COALESCE(MIN(position), 1)").Scan(&minPos)
+
+		for i, id := range entryIDs {
+			if err := tx.Model(&models.CourseWaitlist{}).Where("id = ?", id).
+				Update("position", minPos+i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// markWaitlist 仅当候补记录仍处于等待状态时更新，返回是否由本次调用完成更新
+func markWaitlist(db *gorm.DB, entryID uint, status, remark string) (bool, error) {
+	result := db.Model(&models.CourseWaitlist{}).
+		Where("id = ? AND status = ?", entryID, WaitlistWaiting).
+		Updates(map[string]interface{}{"status": status, "remark": remark})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// notifyStudent 通过通知表向单个学生发送通知
+func notifyStudent(db *gorm.DB, studentID uint, title, content string) {
+	notification := models.Notification{
+		Title:   title,
+		Content: content,
+		Target:  fmt.Sprintf("student:%d", studentID),
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		log.Printf("发送通知失败: student=%d, error: %v", studentID, err)
+	}
+}
+
+func sameIDSet(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uint]int, len(a))
+	for _, id := range a {
+		seen[id]++
+	}
+	for _, id := range b {
+		if seen[id] == 0 {
+			return false
+		}
+		seen[id]--
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"student-management-system/internal/models"
+)
+
+// 并发选课、退课（软删除与硬删除）后，enrolled_count 与实际选课记录一致且不超过容量（同 cmd/enroll_stress，规模较小）。
+// sp_enroll_student 与 trg_enrollment_count_* 触发器由 connectTestDB 创建
+func TestEnrollConcurrentCountConsistent(t *testing.T) {
+	db := connectTestDB(t, &models.Teacher{}, &models.Class{}, &models.Student{}, &models.Course{},
+		&models.CoursePrerequisite{}, &models.Enrollment{}, &models.Grade{})
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	teacher := models.Teacher{Name: "test_enroll", TeacherID: "test_enroll_" + suffix}
+	if err := db.Create(&teacher).Error; err != nil {
+		t.Fatal(err)
+	}
+	class := models.Class{ClassName: "test_enroll_" + suffix, TeacherID: teacher.ID}
+	if err := db.Create(&class).Error; err != nil {
+		t.Fatal(err)
+	}
+	students := make([]models.Student, 40)
+	for i := range students {
+		students[i] = models.Student{Name: "test_enroll", StudentID: fmt.Sprintf("test_enroll_%s_%d", suffix, i), ClassID: class.ID}
+	}
+	if err := db.Create(&students).Error; err != nil {
+		t.Fatal(err)
+	}
+	course := models.Course{CourseName: "test_enroll_" + suffix, TeacherID: teacher.ID, Capacity: 10}
+	if err := db.Create(&course).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM enrollments WHERE course_id = ?", course.ID)
+		db.Unscoped().Delete(&models.Course{}, course.ID)
+		db.Unscoped().Where("class_id = ?", class.ID).Delete(&models.Student{})
+		db.Unscoped().Delete(&models.Class{}, class.ID)
+		db.Unscoped().Delete(&models.Teacher{}, teacher.ID)
+	})
+
+	const workers, opsPerWorker = 16, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*opsPerWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < opsPerWorker; i++ {
+				studentID := students[r.Intn(len(students))].ID
+				switch n := r.Intn(100); {
+				case n < 60:
+					err := Enroll(db, studentID, course.ID)
+					if err != nil && !errors.Is(err, ErrCourseFull) && !errors.Is(err, ErrAlreadyEnrolled) {
+						errs <- err
+					}
+				case n < 85:
+					if err := db.Where("student_id = ? AND course_id = ?", studentID, course.ID).Delete(&models.Enrollment{}).Error; err != nil {
+						errs <- err
+					}
+				default:
+					if err := db.Exec("DELETE FROM enrollments WHERE student_id = ? AND course_id = ?", studentID, course.ID).Error; err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("选课/退课失败: %v", err)
+	}
+
+	var reloaded models.Course
+	if err := db.First(&reloaded, course.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var actual int64
+	if err := db.Model(&models.Enrollment{}).Where("course_id = ?", course.ID).Count(&actual).Error; err != nil {
+		t.Fatal(err)
+	}
+	if int64(reloaded.EnrolledCount) != actual || actual > int64(reloaded.Capacity) {
+		t.Fatalf("enrolled_count = %d，实际选课 %d，容量 %d", reloaded.EnrolledCount, actual, reloaded.Capacity)
+	}
+}
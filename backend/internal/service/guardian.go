@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrGuardianExists 监护关系已存在
+var ErrGuardianExists = errors.New("监护关系已存在")
+
+// IsGuardian 判断家长是否为该学生的监护人
+func IsGuardian(db *gorm.DB, parentID, studentID uint) bool {
+	var count int64
+	db.Model(&models.StudentGuardian{}).Where("parent_id = ? AND student_id = ?", parentID, studentID).Count(&count)
+	return count > 0
+}
+
+// GuardianChildren 家长监护的全部学生
+func GuardianChildren(db *gorm.DB, parentID uint) ([]models.Student, error) {
+	var students []models.Student
+	err := db.Preload("Class").
+		Joins("JOIN student_guardians sg ON sg.student_id = students.id").
+		Where("sg.parent_id = ?", parentID).Order("students.id ASC").Find(&students).Error
+	return students, err
+}
+
+// GuardianParentIDs 学生的全部监护人（家长ID）
+func GuardianParentIDs(db *gorm.DB, studentID uint) ([]uint, error) {
+	var parentIDs []uint
+	err := db.Model(&models.StudentGuardian{}).Where("student_id = ?", studentID).
+		Order("parent_id ASC").Pluck("parent_id", &parentIDs).Error
+	return parentIDs, err
+}
+
+// AddGuardian 建立监护关系；家长尚无主要关联学生时同时设为主要关联学生
+func AddGuardian(db *gorm.DB, parentID, studentID uint, relation string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var parent models.Parent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, parentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id").First(&models.Student{}, studentID).Error; err != nil {
+			return err
+		}
+		if relation == "" {
+			relation = parent.Relation
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.StudentGuardian{
+			StudentID: studentID,
+			ParentID:  parentID,
+			Relation:  relation,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrGuardianExists
+		}
+		if parent.StudentID == 0 {
+			return tx.Model(&parent).Update("student_id", studentID).Error
+		}
+		return nil
+	})
+}
+
+// RemoveGuardian 解除监护关系；若解除的是主要关联学生，则改为剩余子女中的一个（没有则置 0）
+func RemoveGuardian(db *gorm.DB, parentID, studentID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var parent models.Parent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, parentID).Error; err != nil {
+			return err
+		}
+		res := tx.Where("parent_id = ? AND student_id = ?", parentID, studentID).Delete(&models.StudentGuardian{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if parent.StudentID != studentID {
+			return nil
+		}
+
+		var next uint
+		tx.Model(&models.StudentGuardian{}).Select("student_id").Where("parent_id = ?", parentID).
+			Order("created_at ASC").Limit(1).Scan(&next)
+		return tx.Model(&parent).Update("student_id", next).Error
+	})
+}
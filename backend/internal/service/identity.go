@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 外部身份关联（user_identities）：统一身份认证只接受已关联的身份登录已有账号，关联由管理员建立或在自动创建用户时建立
+
+var (
+	// ErrIdentityNotLinked 本地已有同名账号，但外部身份尚未关联到该账号
+	ErrIdentityNotLinked = errors.New("本系统已有同名账号，但尚未关联该外部身份，请联系管理员关联后再登录")
+	// ErrIdentityInUse 外部身份已关联到其他账号
+	ErrIdentityInUse = errors.New("该外部身份已关联到其他账号")
+	// ErrIdentityNotFound 关联不存在
+	ErrIdentityNotFound = errors.New("外部身份关联不存在")
+)
+
+// ListIdentities 账号已关联的外部身份
+func ListIdentities(db *gorm.DB, userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := db.Where("user_id = ?", userID).Order("provider, id").Find(&identities).Error
+	return identities, err
+}
+
+// LinkIdentity 把外部身份 (provider, subject) 关联到账号；每个账号在同一提供方只关联一个身份，已有时替换
+func LinkIdentity(db *gorm.DB, userID uint, provider, subject string) (*models.UserIdentity, error) {
+	provider, subject = strings.TrimSpace(provider), strings.TrimSpace(subject)
+	var link models.UserIdentity
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		var other models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, subject).First(&other).Error
+		switch {
+		case err == nil && other.UserID != userID:
+			return ErrIdentityInUse
+		case err == nil:
+			link = other
+			return nil
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+		if err := tx.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		link = models.UserIdentity{UserID: userID, Provider: provider, Subject: subject}
+		return tx.Create(&link).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// UnlinkIdentity 解除账号的一个外部身份关联
+func UnlinkIdentity(db *gorm.DB, userID, identityID uint) error {
+	result := db.Where("id = ? AND user_id = ?", identityID, userID).Delete(&models.UserIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// LDAP 目录认证：用服务账号（或匿名）按用户名查找条目，再以条目 DN 和用户输入的密码绑定
+
+// ErrLDAPNoAccount 目录密码正确，但本地没有同名用户且不能自动创建
+var ErrLDAPNoAccount = errors.New("该目录账号未开通本系统用户，请联系管理员")
+
+// LDAPEntry 通过目录认证的用户
+type LDAPEntry struct {
+	DN     string
+	Email  string
+	Groups []string // 所属组的 DN
+}
+
+// LDAPAuthenticator 目录认证
+type LDAPAuthenticator struct {
+	cfg     config.LDAPConfig
+	tls     *tls.Config
+	timeout time.Duration
+}
+
+// NewLDAPAuthenticator 创建目录认证（读取 CA 证书失败时返回错误）
+func NewLDAPAuthenticator(cfg config.LDAPConfig) (*LDAPAuthenticator, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 LDAP 地址 %q", cfg.URL)
+	}
+	tlsCfg := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: cfg.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 LDAP CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("LDAP CA 证书 %s 中没有有效的证书", cfg.CACertFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &LDAPAuthenticator{cfg: cfg, tls: tlsCfg, timeout: timeout}, nil
+}
+
+// Name 认证方式名称
+func (a *LDAPAuthenticator) Name() string { return AuthLDAP }
+
+// Authenticate 目录认证通过后返回与目录条目关联的本地用户（user_identities 中 provider 为 ldap、subject 为条目 DN）。
+// 同名本地用户未关联该条目时不能通过目录登录（目录中的用户名可能与本地账号巧合或被人注册，包括管理员），
+// 须由管理员关联；没有同名用户时按配置自动创建并关联。开启 SyncRole 时按组更新角色
+func (a *LDAPAuthenticator) Authenticate(db *gorm.DB, username, password string, user *models.User) (*models.User, error) {
+	entry, err := a.Verify(username, password)
+	if err != nil {
+		return nil, err
+	}
+	role, mapped := a.MapRole(entry.Groups)
+
+	var link models.UserIdentity
+	err = db.Where("provider = ? AND subject = ?", AuthLDAP, entry.DN).First(&link).Error
+	switch {
+	case err == nil:
+		if user == nil || link.UserID != user.ID {
+			log.Printf("LDAP 条目 %s 已关联到其他账号，拒绝以用户名 %s 登录", entry.DN, username)
+			return nil, ErrBadCredentials
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	case user != nil:
+		log.Printf("本地账号 %s 未关联 LDAP 条目 %s，不能通过目录登录", username, entry.DN)
+		return nil, ErrBadCredentials
+	default:
+		if !a.cfg.AutoProvision {
+			return nil, ErrLDAPNoAccount
+		}
+		if !mapped {
+			role = a.cfg.DefaultRole
+		}
+		if role == "" || len(username) > 50 {
+			return nil, ErrLDAPNoAccount
+		}
+		return a.provision(db, username, role, entry)
+	}
+	if err := db.Model(&link).Updates(map[string]interface{}{"last_login_at": time.Now(), "email": entry.Email}).Error; err != nil {
+		return nil, err
+	}
+
+	if a.cfg.SyncRole && mapped && user.Role.RoleName != role {
+		if err := a.syncRole(db, user, role); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// Verify 在目录中查找用户并用其密码绑定，返回条目与所属组
+func (a *LDAPAuthenticator) Verify(username, password string) (*LDAPEntry, error) {
+	// 多数目录对空密码的简单绑定视为匿名绑定并返回成功，必须在本地拒绝
+	if username == "" || password == "" {
+		return nil, ErrBadCredentials
+	}
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := a.bindService(conn); err != nil {
+		return nil, err
+	}
+	attrs := []string{"dn"}
+	for _, attr := range []string{a.cfg.UsernameAttr, a.cfg.EmailAttr, a.cfg.GroupAttr} {
+		if attr != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.timeout.Seconds()), false,
+		fmt.Sprintf(a.cfg.UserFilter, ldap.EscapeFilter(username)), attrs, nil,
+	))
+	if err != nil {
+		switch {
+		case ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject):
+			return nil, ErrBadCredentials
+		case ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded):
+			log.Printf("LDAP 中有多个条目匹配用户名 %s，拒绝登录", username)
+			return nil, ErrBadCredentials
+		}
+		return nil, fmt.Errorf("%w: 查找用户失败: %v", ErrAuthUnavailable, err)
+	}
+	var found *ldap.Entry
+	for _, e := range res.Entries {
+		// 目录的匹配规则可能比登录名宽松，只接受用户名属性完全一致的条目
+		if a.cfg.UsernameAttr != "" && !strings.EqualFold(e.GetAttributeValue(a.cfg.UsernameAttr), username) {
+			continue
+		}
+		if found != nil {
+			log.Printf("LDAP 中有多个条目匹配用户名 %s，拒绝登录", username)
+			return nil, ErrBadCredentials
+		}
+		found = e
+	}
+	if found == nil {
+		return nil, ErrBadCredentials
+	}
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrBadCredentials
+		}
+		return nil, fmt.Errorf("%w: 绑定用户失败: %v", ErrAuthUnavailable, err)
+	}
+
+	entry := &LDAPEntry{DN: found.DN}
+	if a.cfg.EmailAttr != "" {
+		entry.Email = found.GetAttributeValue(a.cfg.EmailAttr)
+	}
+	if a.cfg.GroupAttr != "" {
+		entry.Groups = append(entry.Groups, found.GetAttributeValues(a.cfg.GroupAttr)...)
+	}
+	if a.cfg.GroupBaseDN != "" && a.cfg.GroupFilter != "" {
+		groups, err := a.searchGroups(conn, found.DN)
+		if err != nil {
+			return nil, err
+		}
+		entry.Groups = append(entry.Groups, groups...)
+	}
+	return entry, nil
+}
+
+// MapRole 按 GroupRoles 的顺序返回第一个匹配的角色
+func (a *LDAPAuthenticator) MapRole(groups []string) (string, bool) {
+	for _, rule := range a.cfg.GroupRoles {
+		for _, g := range groups {
+			if strings.EqualFold(g, rule.Group) || strings.EqualFold(groupCN(g), rule.Group) {
+				return rule.Role, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.timeout}),
+		ldap.DialWithTLSConfig(a.tls))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 连接 LDAP 失败: %v", ErrAuthUnavailable, err)
+	}
+	conn.SetTimeout(a.timeout)
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(a.tls); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: StartTLS 失败: %v", ErrAuthUnavailable, err)
+		}
+	}
+	return conn, nil
+}
+
+// bindService 以服务账号绑定；未配置服务账号时保持匿名
+func (a *LDAPAuthenticator) bindService(conn *ldap.Conn) error {
+	if a.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+		return fmt.Errorf("%w: 服务账号绑定失败: %v", ErrAuthUnavailable, err)
+	}
+	return nil
+}
+
+// searchGroups 按 GroupFilter 查找用户所属的组（以服务账号身份查找）
+func (a *LDAPAuthenticator) searchGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	if err := a.bindService(conn); err != nil {
+		return nil, err
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(a.timeout.Seconds()), false,
+		fmt.Sprintf(a.cfg.GroupFilter, ldap.EscapeFilter(userDN)), []string{"dn"}, nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: 查找用户组失败: %v", ErrAuthUnavailable, err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		groups = append(groups, e.DN)
+	}
+	return groups, nil
+}
+
+// groupCN 组 DN 第一个 RDN 的值（cn=teachers,ou=groups,... → teachers），无法解析时原样返回
+func groupCN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
+
+// userType 角色对应的用户类型：内置角色与其同名，其他角色使用 DefaultUserType
+func (a *LDAPAuthenticator) userType(roleName string) string {
+	switch roleName {
+	case "admin", "teacher", "student", "parent":
+		return roleName
+	}
+	return a.cfg.DefaultUserType
+}
+
+// provision 以映射得到的角色创建本地用户并关联目录条目（本地密码为随机值，只能通过目录登录）
+func (a *LDAPAuthenticator) provision(db *gorm.DB, username, roleName string, entry *LDAPEntry) (*models.User, error) {
+	var role models.Role
+	if err := db.Where("role_name = ?", roleName).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("自动创建用户失败：角色 %q 不存在", roleName)
+	}
+	password, err := utils.RandomPassword(32)
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Username:    username,
+		Password:    hashed,
+		RoleID:      role.ID,
+		IsActive:    true,
+		UserType:    a.userType(role.RoleName),
+		AuthMethods: AuthLDAP,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    AuthLDAP,
+			Subject:     entry.DN,
+			Email:       entry.Email,
+			LastLoginAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+// syncRole 把已有用户的角色与用户类型更新为组映射的角色，并使已签发的令牌失效。
+// 用户类型决定数据范围（见 middleware.DataScopeMiddleware），必须随角色一起更新；类型变化时原档案不再适用，解除关联
+func (a *LDAPAuthenticator) syncRole(db *gorm.DB, user *models.User, roleName string) error {
+	var role models.Role
+	if err := db.Where("role_name = ?", roleName).First(&role).Error; err != nil {
+		log.Printf("LDAP 组映射的角色 %q 不存在，保留用户 %s 的原角色", roleName, user.Username)
+		return nil
+	}
+	userType := a.userType(role.RoleName)
+	// 组映射不能让系统失去最后一个可用的管理员账号（如误配置映射把管理员降为普通角色）
+	err := WithAdminGuard(db, func(tx *gorm.DB) error {
+		if userType != user.UserType {
+			if err := UnlinkProfile(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{"role_id": role.ID, "user_type": userType}).Error; err != nil {
+			return err
+		}
+		return RevokeUserTokens(tx, user.ID)
+	})
+	if errors.Is(err, ErrLastAdmin) {
+		log.Printf("用户 %s 是最后一个可用的管理员账号，不按 LDAP 组映射改为角色 %s，保留原角色", user.Username, role.RoleName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("按 LDAP 组映射将用户 %s 的角色由 %s 改为 %s（用户类型 %s）", user.Username, user.Role.RoleName, role.RoleName, userType)
+	user.RoleID = role.ID
+	user.Role = role
+	if user.UserType != userType {
+		user.UserType = userType
+		user.UserID = 0
+	}
+	user.TokenVersion++
+	return nil
+}
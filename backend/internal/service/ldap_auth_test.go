@@ -0,0 +1,299 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+)
+
+// 测试目录的结构与账号
+const (
+	testUserDN          = "ou=people,dc=example,dc=edu"
+	testGroupDN         = "ou=groups,dc=example,dc=edu"
+	testServiceDN       = "cn=sms-reader,dc=example,dc=edu"
+	testServicePassword = "reader-secret"
+	testUserPassword    = "ldap123"
+)
+
+// testDirectoryUsers 用户名 → 所属组（cn）
+var testDirectoryUsers = map[string][]string{
+	"zhanglaoshi": {"teachers"},
+	"lizhuren":    {"teachers", "head-teachers"},
+	"wangadmin":   {"teachers", "it-admins"},
+	"zhaoke":      nil,
+}
+
+// startTestDirectory 在随机端口启动内嵌的 gldap 测试目录（不启用 TLS），测试结束时停止
+func startTestDirectory(t *testing.T) *testdirectory.Directory {
+	t.Helper()
+	logger := hclog.New(&hclog.LoggerOptions{Name: "ldap-test", Level: hclog.Error})
+
+	var users []*gldap.Entry
+	members := make(map[string][]string)
+	for name, groups := range testDirectoryUsers {
+		dn := fmt.Sprintf("uid=%s,%s", name, testUserDN)
+		attrs := map[string][]string{
+			"uid":      {name},
+			"cn":       {name},
+			"mail":     {name + "@example.edu.cn"},
+			"password": {testUserPassword},
+		}
+		for _, g := range groups {
+			attrs["memberOf"] = append(attrs["memberOf"], fmt.Sprintf("cn=%s,%s", g, testGroupDN))
+			members[g] = append(members[g], dn)
+		}
+		users = append(users, gldap.NewEntry(dn, attrs))
+	}
+	users = append(users, gldap.NewEntry(testServiceDN, map[string][]string{"password": {testServicePassword}}))
+
+	var groups []*gldap.Entry
+	for g, dns := range members {
+		groups = append(groups, gldap.NewEntry(fmt.Sprintf("cn=%s,%s", g, testGroupDN), map[string][]string{"cn": {g}, "member": dns}))
+	}
+
+	d := testdirectory.Start(t,
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithLogger(t, logger),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{
+			UserAttr:  "uid",
+			GroupAttr: "cn",
+			UserDN:    testUserDN,
+			GroupDN:   testGroupDN,
+			Users:     users,
+			Groups:    groups,
+		}),
+	)
+	t.Cleanup(d.Stop)
+	return d
+}
+
+// testLDAPConfig 指向测试目录的 LDAP 配置
+func testLDAPConfig(port int) config.LDAPConfig {
+	return config.LDAPConfig{
+		Enabled:        true,
+		URL:            fmt.Sprintf("ldap://localhost:%d", port),
+		TimeoutSeconds: 2,
+		BindDN:         testServiceDN,
+		BindPassword:   testServicePassword,
+		UserBaseDN:     testUserDN,
+		UserFilter:     "(uid=%s)",
+		UsernameAttr:   "uid",
+		EmailAttr:      "mail",
+		GroupAttr:      "memberOf",
+		GroupRoles: []config.LDAPGroupRole{
+			{Group: "it-admins", Role: "admin"},
+			{Group: "cn=teachers," + testGroupDN, Role: "teacher"},
+		},
+	}
+}
+
+func newTestLDAPAuthenticator(t *testing.T, cfg config.LDAPConfig) *LDAPAuthenticator {
+	t.Helper()
+	a, err := NewLDAPAuthenticator(cfg)
+	if err != nil {
+		t.Fatalf("NewLDAPAuthenticator: %v", err)
+	}
+	return a
+}
+
+func TestLDAPVerifyBindAndGroupMapping(t *testing.T) {
+	d := startTestDirectory(t)
+	a := newTestLDAPAuthenticator(t, testLDAPConfig(d.Port()))
+
+	entry, err := a.Verify("zhanglaoshi", testUserPassword)
+	if err != nil {
+		t.Fatalf("正确密码绑定失败: %v", err)
+	}
+	if entry.DN != "uid=zhanglaoshi,"+testUserDN {
+		t.Errorf("DN = %q", entry.DN)
+	}
+	if entry.Email != "zhanglaoshi@example.edu.cn" {
+		t.Errorf("Email = %q", entry.Email)
+	}
+	if role, mapped := a.MapRole(entry.Groups); !mapped || role != "teacher" {
+		t.Errorf("按完整组 DN 映射角色 = (%q, %v), want teacher", role, mapped)
+	}
+
+	// 属于多个组时按映射顺序取第一个（按 cn 匹配）
+	entry, err = a.Verify("wangadmin", testUserPassword)
+	if err != nil {
+		t.Fatalf("Verify(wangadmin): %v", err)
+	}
+	if role, _ := a.MapRole(entry.Groups); role != "admin" {
+		t.Errorf("多个组映射为 %q, want admin", role)
+	}
+
+	entry, err = a.Verify("zhaoke", testUserPassword)
+	if err != nil {
+		t.Fatalf("Verify(zhaoke): %v", err)
+	}
+	if _, mapped := a.MapRole(entry.Groups); mapped {
+		t.Errorf("不属于任何组的用户被映射了角色，组为 %v", entry.Groups)
+	}
+}
+
+// 不读取 memberOf，改为按 (member=<用户DN>) 查找组
+func TestLDAPVerifyGroupFilter(t *testing.T) {
+	d := startTestDirectory(t)
+	cfg := testLDAPConfig(d.Port())
+	cfg.GroupAttr = ""
+	cfg.GroupBaseDN = testGroupDN
+	cfg.GroupFilter = "(member=%s)"
+
+	entry, err := newTestLDAPAuthenticator(t, cfg).Verify("lizhuren", testUserPassword)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(entry.Groups) != 2 {
+		t.Errorf("按 group_filter 查找到的组为 %v, want 2 个", entry.Groups)
+	}
+}
+
+func TestLDAPVerifyRejects(t *testing.T) {
+	d := startTestDirectory(t)
+	a := newTestLDAPAuthenticator(t, testLDAPConfig(d.Port()))
+
+	badService := testLDAPConfig(d.Port())
+	badService.BindPassword = "wrong"
+	unreachable := testLDAPConfig(freeTestPort(t))
+
+	cases := []struct {
+		name     string
+		a        *LDAPAuthenticator
+		username string
+		password string
+		want     error
+	}{
+		{"错误密码", a, "zhanglaoshi", "wrong-password", ErrBadCredentials},
+		{"空密码不作匿名绑定", a, "zhanglaoshi", "", ErrBadCredentials},
+		{"空用户名", a, "", testUserPassword, ErrBadCredentials},
+		{"不存在的用户", a, "nobody", testUserPassword, ErrBadCredentials},
+		{"用户名前缀不能匹配其他用户", a, "zhang", testUserPassword, ErrBadCredentials},
+		{"通配符被转义", a, "*", testUserPassword, ErrBadCredentials},
+		{"前缀通配符被转义", a, "zhang*", testUserPassword, ErrBadCredentials},
+		{"注入过滤器条件被转义", a, "zhanglaoshi)(uid=*", testUserPassword, ErrBadCredentials},
+		{"服务账号密码错误视为服务不可用", newTestLDAPAuthenticator(t, badService), "zhanglaoshi", testUserPassword, ErrAuthUnavailable},
+		{"目录无法连接视为服务不可用", newTestLDAPAuthenticator(t, unreachable), "zhanglaoshi", testUserPassword, ErrAuthUnavailable},
+	}
+	for _, tc := range cases {
+		if _, err := tc.a.Verify(tc.username, tc.password); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Verify err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+// 认证顺序：用户设置 > user_type > 默认
+func TestAuthChainMethods(t *testing.T) {
+	a := newTestLDAPAuthenticator(t, testLDAPConfig(389))
+	chain, err := NewAuthChain(config.AuthOrder{
+		Default:    []string{AuthLocal, AuthLDAP},
+		ByUserType: map[string][]string{"teacher": {AuthLDAP, AuthLocal}},
+	}, LocalAuthenticator{}, a)
+	if err != nil {
+		t.Fatalf("NewAuthChain: %v", err)
+	}
+	teacher := &models.User{UserType: "teacher"}
+	if got := chain.Methods(nil); fmt.Sprint(got) != "[local ldap]" {
+		t.Errorf("新用户的顺序 = %v", got)
+	}
+	if got := chain.Methods(teacher); fmt.Sprint(got) != "[ldap local]" {
+		t.Errorf("按 user_type 的顺序 = %v", got)
+	}
+	teacher.AuthMethods = "ldap"
+	if got := chain.Methods(teacher); fmt.Sprint(got) != "[ldap]" {
+		t.Errorf("用户自己设置的顺序 = %v", got)
+	}
+	if chain.ValidateMethods("local,oidc") == nil {
+		t.Error("未拒绝未启用的认证方式")
+	}
+}
+
+// 目录密码正确也不能登录未关联该条目的同名本地账号（包括管理员）；关联或自动创建的账号可以登录，并按组同步角色与用户类型
+func TestLDAPAuthenticateRequiresLink(t *testing.T) {
+	db := openTestDB(t, &models.Teacher{})
+	d := startTestDirectory(t)
+	cfg := testLDAPConfig(d.Port())
+	cfg.AutoProvision = true
+	cfg.SyncRole = true
+	cfg.DefaultUserType = "student"
+	adminRole := createTestRole(t, db, "test_ldap_admin", true)
+	teacherRole := createTestRole(t, db, "test_ldap_teacher", false)
+	studentRole := createTestRole(t, db, "test_ldap_student", false)
+	cfg.GroupRoles = []config.LDAPGroupRole{
+		{Group: "it-admins", Role: adminRole.RoleName},
+		{Group: "teachers", Role: teacherRole.RoleName},
+	}
+	a := newTestLDAPAuthenticator(t, cfg)
+
+	load := func(username string) *models.User {
+		var u models.User
+		if err := db.Preload("Role").Where("username = ?", username).First(&u).Error; err != nil {
+			return nil
+		}
+		return &u
+	}
+
+	// 同名的本地管理员未关联目录条目
+	createTestUser(t, db, "wangadmin", adminRole, "admin")
+	if _, err := a.Authenticate(db, "wangadmin", testUserPassword, load("wangadmin")); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("未关联的管理员账号: Authenticate err = %v, want ErrBadCredentials", err)
+	}
+
+	// 本地没有同名账号时自动创建并关联，之后可以再次登录
+	user, err := a.Authenticate(db, "zhanglaoshi", testUserPassword, nil)
+	if err != nil {
+		t.Fatalf("自动创建: %v", err)
+	}
+	if user.RoleID != teacherRole.ID || user.UserType != "student" {
+		t.Errorf("自动创建的账号角色 %d、类型 %q", user.RoleID, user.UserType)
+	}
+	if _, err := a.Authenticate(db, "zhanglaoshi", testUserPassword, load("zhanglaoshi")); err != nil {
+		t.Fatalf("已关联账号再次登录: %v", err)
+	}
+
+	// 管理员关联后可以登录；组映射变化时角色与用户类型一起更新，原档案解除关联
+	lizhuren := createTestUser(t, db, "lizhuren", teacherRole, "teacher")
+	teacher := models.Teacher{Name: "李主任", TeacherID: "test-ldap-t1", UserID: lizhuren.ID}
+	db.Create(&teacher)
+	db.Model(&lizhuren).Update("user_id", teacher.ID)
+	if _, err := LinkIdentity(db, lizhuren.ID, AuthLDAP, "uid=lizhuren,"+testUserDN); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+	// 映射到非内置角色时用户类型为 DefaultUserType
+	cfg.GroupRoles = []config.LDAPGroupRole{{Group: "head-teachers", Role: studentRole.RoleName}}
+	user, err = newTestLDAPAuthenticator(t, cfg).Authenticate(db, "lizhuren", testUserPassword, load("lizhuren"))
+	if err != nil {
+		t.Fatalf("关联后登录: %v", err)
+	}
+	got := load("lizhuren")
+	if got.RoleID != studentRole.ID || got.UserType != "student" || got.UserID != 0 {
+		t.Errorf("同步后角色 %d、类型 %q、档案 %d", got.RoleID, got.UserType, got.UserID)
+	}
+	var reloaded models.Teacher
+	db.First(&reloaded, teacher.ID)
+	if reloaded.UserID != 0 {
+		t.Errorf("教师档案仍关联账号 %d", reloaded.UserID)
+	}
+	if user.UserType != "student" {
+		t.Errorf("返回的用户类型 %q", user.UserType)
+	}
+}
+
+// freeTestPort 返回一个当前空闲的本地端口
+func freeTestPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("获取空闲端口失败: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 登录结果（login_attempts.result）
+const (
+	LoginSuccess     = "success"
+	LoginBadPassword = "bad_password"
+	LoginUnknownUser = "unknown_user"
+	LoginInactive    = "inactive"
+	LoginLocked      = "locked"
+	LoginThrottled   = "throttled"
+
+	LoginBadSecondFactor = "bad_second_factor" // 两步验证码或备用码错误
+)
+
+// LoginPolicy 登录失败限制策略
+// 同一用户名连续失败时，每次失败后须等待的时间按 BackoffBase 翻倍（不超过 MaxBackoff），
+// 达到 MaxFailures 次后锁定 Lockout，再次被锁定时锁定时长翻倍（不超过 MaxLockout）。
+// 同一 IP 可能是整个学校的出口地址，因此只在失败次数达到 IPMaxFailures 时锁定，不做逐次等待。
+type LoginPolicy struct {
+	MaxFailures   int           // 用户名连续失败多少次后锁定
+	IPMaxFailures int           // 同一 IP 连续失败多少次后锁定
+	BackoffBase   time.Duration // 第一次失败后的等待时间
+	MaxBackoff    time.Duration
+	Lockout       time.Duration // 第一次锁定的时长
+	MaxLockout    time.Duration
+	FailureWindow time.Duration // 超过该时间没有失败，失败次数清零
+}
+
+// DefaultLoginPolicy 默认登录失败限制策略
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxFailures:   5,
+		IPMaxFailures: 50,
+		BackoffBase:   time.Second,
+		MaxBackoff:    30 * time.Second,
+		Lockout:       15 * time.Minute,
+		MaxLockout:    24 * time.Hour,
+		FailureWindow: time.Hour,
+	}
+}
+
+// LoginBlockedError 登录被暂时阻止
+type LoginBlockedError struct {
+	Result     string        // LoginLocked 或 LoginThrottled
+	RetryAfter time.Duration // 多久后可以再次尝试
+}
+
+func (e *LoginBlockedError) Error() string {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if e.Result == LoginLocked {
+		return fmt.Sprintf("登录失败次数过多，账号已临时锁定，请 %d 秒后重试", seconds)
+	}
+	return fmt.Sprintf("登录过于频繁，请 %d 秒后重试", seconds)
+}
+
+// maxThrottleKeyLen 限制键的最大长度（login_throttles.throttle_key 为 varchar(100)）
+const maxThrottleKeyLen = 100
+
+// UserThrottleKey 用户名对应的限制键（用户名不区分大小写）
+// 登录请求中的用户名没有长度限制，超长时改用其 SHA-256，避免写入限制记录失败导致整个失败记录回滚
+func UserThrottleKey(username string) string {
+	key := "user:" + strings.ToLower(strings.TrimSpace(username))
+	if len(key) > maxThrottleKeyLen {
+		key = "user:sha256:" + utils.HashToken(key)
+	}
+	return key
+}
+
+// IPThrottleKey IP 对应的限制键
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff 第 failures 次失败后须等待的时间
+func (p LoginPolicy) backoff(failures int) time.Duration {
+	if failures <= 0 || p.BackoffBase <= 0 {
+		return 0
+	}
+	d := p.BackoffBase
+	for i := 1; i < failures && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// lockout 第 lockCount 次锁定的时长
+func (p LoginPolicy) lockout(lockCount int) time.Duration {
+	d := p.Lockout
+	for i := 1; i < lockCount && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if p.MaxLockout > 0 && d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+// CheckLoginAllowed 检查用户名和 IP 当前是否允许尝试登录，不允许时返回 *LoginBlockedError
+func CheckLoginAllowed(db *gorm.DB, policy LoginPolicy, username, ip string) error {
+	now := time.Now()
+	var throttles []models.LoginThrottle
+	if err := db.Where("throttle_key IN ?", []string{UserThrottleKey(username), IPThrottleKey(ip)}).
+		Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	var blocked *LoginBlockedError
+	block := func(result string, until time.Time) {
+		if wait := until.Sub(now); wait > 0 && (blocked == nil || wait > blocked.RetryAfter) {
+			blocked = &LoginBlockedError{Result: result, RetryAfter: wait}
+		}
+	}
+	for _, t := range throttles {
+		if t.LockedUntil != nil {
+			block(LoginLocked, *t.LockedUntil)
+		}
+		if strings.HasPrefix(t.ThrottleKey, "user:") && now.Sub(t.LastFailedAt) < policy.FailureWindow {
+			block(LoginThrottled, t.LastFailedAt.Add(policy.backoff(t.Failures)))
+		}
+	}
+	if blocked != nil {
+		return blocked
+	}
+	return nil
+}
+
+// RecordLoginFailure 记录一次失败（用户名与 IP 各计一次），达到阈值时锁定
+func RecordLoginFailure(db *gorm.DB, policy LoginPolicy, username, ip string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := recordFailure(tx, policy, UserThrottleKey(username), policy.MaxFailures); err != nil {
+			return err
+		}
+		return recordFailure(tx, policy, IPThrottleKey(ip), policy.IPMaxFailures)
+	})
+}
+
+func recordFailure(tx *gorm.DB, policy LoginPolicy, key string, maxFailures int) error {
+	now := time.Now()
+	// 确保行存在后加锁读取，避免并发失败互相覆盖计数
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{ThrottleKey: key, LastFailedAt: now}).Error; err != nil {
+		return err
+	}
+	var t models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("throttle_key = ?", key).First(&t).Error; err != nil {
+		return err
+	}
+
+	if t.Failures > 0 && now.Sub(t.LastFailedAt) > policy.FailureWindow {
+		t.Failures = 0
+	}
+	if t.LockedUntil != nil && now.After(*t.LockedUntil) {
+		t.LockedUntil = nil
+	}
+	t.Failures++
+	t.LastFailedAt = now
+	if maxFailures > 0 && t.Failures >= maxFailures {
+		t.LockCount++
+		until := now.Add(policy.lockout(t.LockCount))
+		t.LockedUntil = &until
+		t.Failures = 0
+	}
+	return tx.Save(&t).Error
+}
+
+// RecordLoginSuccess 登录成功后清除该用户名的失败记录（IP 的失败次数随时间窗口自然清零）
+func RecordLoginSuccess(db *gorm.DB, username string) error {
+	return db.Where("throttle_key = ?", UserThrottleKey(username)).Delete(&models.LoginThrottle{}).Error
+}
+
+// RecordLoginAttempt 写入登录审计记录
+func RecordLoginAttempt(db *gorm.DB, attempt models.LoginAttempt) error {
+	if len(attempt.Username) > 50 {
+		attempt.Username = attempt.Username[:50]
+	}
+	if len(attempt.UserAgent) > 255 {
+		attempt.UserAgent = attempt.UserAgent[:255]
+	}
+	attempt.Success = attempt.Result == LoginSuccess
+	return db.Create(&attempt).Error
+}
+
+// ErrNoLoginLock 没有需要解除的锁定
+var ErrNoLoginLock = errors.New("没有需要解除的登录限制")
+
+// UnlockLogin 解除用户名和/或 IP 的登录限制（清除失败次数与锁定）
+func UnlockLogin(db *gorm.DB, username, ip string) error {
+	var keys []string
+	if username != "" {
+		keys = append(keys, UserThrottleKey(username))
+	}
+	if ip != "" {
+		keys = append(keys, IPThrottleKey(ip))
+	}
+	if len(keys) == 0 {
+		return ErrNoLoginLock
+	}
+	res := db.Where("throttle_key IN ?", keys).Delete(&models.LoginThrottle{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNoLoginLock
+	}
+	return nil
+}
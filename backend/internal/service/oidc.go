@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 统一身份认证（OpenID Connect 授权码模式 + PKCE）
+
+// OIDCIdentity 身份提供方返回的已验证身份
+type OIDCIdentity struct {
+	Subject  string
+	Username string // UsernameClaim 的值
+	Email    string
+	Name     string
+}
+
+// OIDCStateTTL 从跳转到身份提供方到回调的最长时间
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	// ErrOIDCState state 无效或已过期（可能是重复提交或跨站请求）
+	ErrOIDCState = errors.New("登录状态无效或已过期，请重新发起登录")
+	// ErrOIDCToken 身份提供方返回的令牌无效
+	ErrOIDCToken = errors.New("统一身份认证令牌无效")
+	// ErrOIDCNoAccount 未关联本系统用户且未开启自动创建
+	ErrOIDCNoAccount = errors.New("该统一身份账号未关联本系统用户，请联系管理员")
+)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCState 一次进行中的登录：state、nonce 与 PKCE code_verifier，签名后保存在浏览器的 Cookie 中（见 EncodeState），
+// 回调时只接受与 Cookie 中 state 一致的请求，防止把他人的授权码提交到受害者的浏览器（登录 CSRF），多实例部署时也无须共享状态
+type OIDCState struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Expires  int64  `json:"e"` // Unix 秒
+}
+
+// OIDCClient 身份提供方客户端（缓存发现文档与签名公钥）
+type OIDCClient struct {
+	cfg      config.OIDCConfig
+	http     *http.Client
+	stateKey []byte // 签名登录状态 Cookie 的密钥
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+}
+
+// NewOIDCClient 创建身份提供方客户端；登录状态用 StateSecret 签名，未设置时由 ClientSecret 派生
+func NewOIDCClient(cfg config.OIDCConfig) *OIDCClient {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	secret := cfg.StateSecret
+	if secret == "" {
+		secret = cfg.ClientSecret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("oidc-state"))
+	return &OIDCClient{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: 10 * time.Second},
+		stateKey: mac.Sum(nil),
+	}
+}
+
+// Config 客户端配置
+func (c *OIDCClient) Config() config.OIDCConfig {
+	return c.cfg
+}
+
+// AuthorizationURL 生成跳转到身份提供方的登录地址（附 state、nonce 与 PKCE code_challenge），
+// 返回的登录状态须由调用方签名后保存在浏览器中（见 EncodeState），回调时交给 Exchange
+func (c *OIDCClient) AuthorizationURL(ctx context.Context) (string, *OIDCState, error) {
+	d, err := c.discover(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	st := &OIDCState{Expires: time.Now().Add(OIDCStateTTL).Unix()}
+	for _, f := range []struct {
+		dst *string
+		n   int
+	}{{&st.State, 24}, {&st.Nonce, 24}, {&st.Verifier, 48}} {
+		if *f.dst, err = randomURLString(f.n); err != nil {
+			return "", nil, err
+		}
+	}
+
+	challenge := sha256.Sum256([]byte(st.Verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", c.cfg.ClientID)
+	q.Set("redirect_uri", c.cfg.RedirectURL)
+	q.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	q.Set("state", st.State)
+	q.Set("nonce", st.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), st, nil
+}
+
+// EncodeState 把登录状态编码为带签名的 Cookie 值（base64url(JSON).base64url(HMAC-SHA256)）
+func (c *OIDCClient) EncodeState(st *OIDCState) (string, error) {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(c.signState(body)), nil
+}
+
+// DecodeState 校验 Cookie 的签名与有效期，返回登录状态；无效或过期时返回 ErrOIDCState
+func (c *OIDCClient) DecodeState(value string) (*OIDCState, error) {
+	body, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrOIDCState
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.signState(body)) {
+		return nil, ErrOIDCState
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrOIDCState
+	}
+	st := &OIDCState{}
+	if err := json.Unmarshal(payload, st); err != nil || st.State == "" || time.Now().Unix() > st.Expires {
+		return nil, ErrOIDCState
+	}
+	return st, nil
+}
+
+func (c *OIDCClient) signState(body string) []byte {
+	mac := hmac.New(sha256.New, c.stateKey)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+// Exchange 用回调中的授权码换取并验证 ID Token；回调中的 state 须与浏览器保存的登录状态一致
+func (c *OIDCClient) Exchange(ctx context.Context, code, state string, pending *OIDCState) (*OIDCIdentity, error) {
+	if pending == nil || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(pending.State)) != 1 ||
+		time.Now().Unix() > pending.Expires {
+		return nil, ErrOIDCState
+	}
+
+	d, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("client_secret", c.cfg.ClientSecret)
+	form.Set("code_verifier", pending.Verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求身份提供方失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("解析身份提供方响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("%w：%s %s", ErrOIDCToken, body.Error, body.ErrorDescription)
+	}
+	return c.verifyIDToken(ctx, d, body.IDToken, pending.Nonce)
+}
+
+// verifyIDToken 校验 ID Token 的签名（按 kid 取身份提供方公钥）、iss、aud、exp 与 nonce
+func (c *OIDCClient) verifyIDToken(ctx context.Context, d *oidcDiscovery, raw, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := c.key(ctx, d, kid)
+		if err != nil {
+			return nil, err
+		}
+		if _, isRSA := key.(*rsa.PublicKey); isRSA != (token.Method.Alg() == utils.AlgRS256) {
+			return nil, fmt.Errorf("签名算法 %s 与密钥 %s 不符", token.Method.Alg(), kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{utils.AlgRS256, utils.AlgEdDSA}),
+		jwt.WithIssuer(c.cfg.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%v", ErrOIDCToken, err)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w：nonce 不匹配", ErrOIDCToken)
+	}
+
+	ident := &OIDCIdentity{}
+	ident.Subject, _ = claims["sub"].(string)
+	ident.Email, _ = claims["email"].(string)
+	ident.Name, _ = claims["name"].(string)
+	ident.Username, _ = claims[c.cfg.UsernameClaim].(string)
+	ident.Username = strings.TrimSpace(ident.Username)
+	if ident.Subject == "" || ident.Username == "" {
+		return nil, fmt.Errorf("%w：缺少 sub 或 %s 声明", ErrOIDCToken, c.cfg.UsernameClaim)
+	}
+	return ident, nil
+}
+
+// discover 获取并缓存发现文档
+func (c *OIDCClient) discover(ctx context.Context) (*oidcDiscovery, error) {
+	c.mu.Lock()
+	d := c.discovery
+	c.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	d = &oidcDiscovery{}
+	if err := c.getJSON(ctx, strings.TrimSuffix(c.cfg.Issuer, "/")+"/.well-known/openid-configuration", d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("发现文档中的 issuer %q 与配置 %q 不一致", d.Issuer, c.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少 authorization_endpoint、token_endpoint 或 jwks_uri")
+	}
+	c.mu.Lock()
+	c.discovery = d
+	c.mu.Unlock()
+	return d, nil
+}
+
+// key 按 kid 取身份提供方公钥；未知 kid 时重新获取 JWKS（提供方可能已轮换密钥，最多每分钟一次）
+func (c *OIDCClient) key(ctx context.Context, d *oidcDiscovery, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	stale := time.Since(c.keysAt) > time.Minute
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("未知的密钥 kid %q", kid)
+	}
+
+	var set struct {
+		Keys []utils.JWK `json:"keys"`
+	}
+	if err := c.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if pub, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = pub
+		}
+	}
+	c.mu.Lock()
+	c.keys = keys
+	c.keysAt = time.Now()
+	c.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("未知的密钥 kid %q", kid)
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求身份提供方失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 失败: HTTP %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func randomURLString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// ResolveOIDCUser 将外部身份映射到本系统用户：只按 (provider, sub) 查找已关联的用户。
+// 不按用户名匹配已有账号：身份提供方的用户名不由本系统控制，据此关联会让任何能在提供方使用该用户名的人登录同名账号（包括管理员）；
+// 已有账号须由管理员关联（见 LinkIdentity）。未关联且本地没有同名账号时，若开启自动创建则以默认角色创建用户
+// （随机密码，只能通过统一身份认证登录）并关联。返回是否新建
+func ResolveOIDCUser(db *gorm.DB, cfg config.OIDCConfig, ident *OIDCIdentity) (*models.User, bool, error) {
+	var user models.User
+	created := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", cfg.Provider, ident.Subject).First(&link).Error
+		switch {
+		case err == nil:
+			if err := tx.Preload("Role").First(&user, link.UserID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&link).Updates(map[string]interface{}{"last_login_at": time.Now(), "email": ident.Email}).Error
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", ident.Username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrIdentityNotLinked
+		}
+		if !cfg.AutoProvision {
+			return ErrOIDCNoAccount
+		}
+		if err := provisionOIDCUser(tx, cfg, ident, &user); err != nil {
+			return err
+		}
+		created = true
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    cfg.Provider,
+			Subject:     ident.Subject,
+			Email:       ident.Email,
+			LastLoginAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &user, created, nil
+}
+
+// provisionOIDCUser 以默认角色创建用户
+func provisionOIDCUser(tx *gorm.DB, cfg config.OIDCConfig, ident *OIDCIdentity, user *models.User) error {
+	if len(ident.Username) > 50 {
+		return fmt.Errorf("%w：用户名过长", ErrOIDCToken)
+	}
+	var role models.Role
+	if err := tx.Where("role_name = ?", cfg.DefaultRole).First(&role).Error; err != nil {
+		return fmt.Errorf("自动创建用户失败：默认角色 %q 不存在", cfg.DefaultRole)
+	}
+	// 自动创建的账号不能是管理员（任何能在身份提供方登录的人都会得到该账号）
+	set, err := LoadPermissionSet(tx, role.ID)
+	if err != nil {
+		return err
+	}
+	if IsAdministrator(set) {
+		return fmt.Errorf("自动创建用户失败：默认角色 %q 拥有管理员权限", cfg.DefaultRole)
+	}
+	password, err := utils.RandomPassword(32)
+	if err != nil {
+		return err
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	*user = models.User{
+		Username: ident.Username,
+		Password: hashed,
+		RoleID:   role.ID,
+		IsActive: true,
+		UserType: cfg.DefaultUserType,
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
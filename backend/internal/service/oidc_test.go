@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+)
+
+func testOIDCClient(secret string) *OIDCClient {
+	return NewOIDCClient(config.OIDCConfig{
+		Provider:     "campus",
+		Issuer:       "http://127.0.0.1:0",
+		ClientID:     "sms",
+		ClientSecret: "client-secret",
+		StateSecret:  secret,
+	})
+}
+
+func TestOIDCStateRoundTrip(t *testing.T) {
+	c := testOIDCClient("state-secret")
+	st := &OIDCState{State: "s1", Nonce: "n1", Verifier: "v1", Expires: time.Now().Add(time.Minute).Unix()}
+	cookie, err := c.EncodeState(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.DecodeState(cookie)
+	if err != nil {
+		t.Fatalf("DecodeState: %v", err)
+	}
+	if *got != *st {
+		t.Fatalf("DecodeState = %+v, want %+v", got, st)
+	}
+}
+
+func TestOIDCStateRejectsInvalidCookie(t *testing.T) {
+	c := testOIDCClient("state-secret")
+	valid, _ := c.EncodeState(&OIDCState{State: "s1", Nonce: "n1", Verifier: "v1", Expires: time.Now().Add(time.Minute).Unix()})
+	expired, _ := c.EncodeState(&OIDCState{State: "s1", Nonce: "n1", Verifier: "v1", Expires: time.Now().Add(-time.Second).Unix()})
+	otherKey, _ := testOIDCClient("other-secret").EncodeState(&OIDCState{State: "s1", Expires: time.Now().Add(time.Minute).Unix()})
+	body, sig, _ := strings.Cut(valid, ".")
+	forged, _ := c.EncodeState(&OIDCState{State: "attacker", Expires: time.Now().Add(time.Minute).Unix()})
+	forgedBody, _, _ := strings.Cut(forged, ".")
+
+	cases := map[string]string{
+		"空值":      "",
+		"缺少签名":    body,
+		"签名被篡改":   body + "." + strings.Repeat("A", len(sig)),
+		"内容被替换":   forgedBody + "." + sig,
+		"已过期":     expired,
+		"其他密钥签名的": otherKey,
+	}
+	for name, cookie := range cases {
+		if _, err := c.DecodeState(cookie); !errors.Is(err, ErrOIDCState) {
+			t.Errorf("%s: DecodeState err = %v, want ErrOIDCState", name, err)
+		}
+	}
+}
+
+// 回调中的 state 与浏览器 Cookie 中的不一致（如攻击者诱导受害者提交自己的授权码）时，不向身份提供方兑换授权码
+func TestOIDCExchangeRequiresMatchingState(t *testing.T) {
+	c := testOIDCClient("state-secret")
+	pending := &OIDCState{State: "browser-state", Nonce: "n", Verifier: "v", Expires: time.Now().Add(time.Minute).Unix()}
+	stale := &OIDCState{State: "browser-state", Nonce: "n", Verifier: "v", Expires: time.Now().Add(-time.Second).Unix()}
+
+	cases := []struct {
+		name    string
+		state   string
+		pending *OIDCState
+	}{
+		{"没有 Cookie", "browser-state", nil},
+		{"state 不一致", "attacker-state", pending},
+		{"空 state", "", pending},
+		{"已过期", "browser-state", stale},
+	}
+	for _, tc := range cases {
+		if _, err := c.Exchange(context.Background(), "code", tc.state, tc.pending); !errors.Is(err, ErrOIDCState) {
+			t.Errorf("%s: Exchange err = %v, want ErrOIDCState", tc.name, err)
+		}
+	}
+}
+
+// 未关联的外部身份不能按用户名登录已有账号（包括管理员），关联后才能登录；自动创建只用于本地没有同名账号的情况
+func TestResolveOIDCUserDoesNotLinkByUsername(t *testing.T) {
+	db := openTestDB(t)
+	adminRole := createTestRole(t, db, "test_oidc_admin", true)
+	studentRole := createTestRole(t, db, "test_oidc_student", false)
+	admin := createTestUser(t, db, "test_oidc_root", adminRole, "admin")
+	alice := createTestUser(t, db, "test_oidc_alice", studentRole, "student")
+
+	cfg := config.OIDCConfig{Provider: "test-idp", AutoProvision: true, DefaultRole: studentRole.RoleName, DefaultUserType: "student"}
+
+	for _, username := range []string{admin.Username, "test_oidc_alice"} {
+		ident := &OIDCIdentity{Subject: "sub-" + username, Username: username}
+		if _, _, err := ResolveOIDCUser(db, cfg, ident); !errors.Is(err, ErrIdentityNotLinked) {
+			t.Fatalf("%s: ResolveOIDCUser err = %v, want ErrIdentityNotLinked", username, err)
+		}
+		var count int64
+		db.Model(&models.UserIdentity{}).Where("provider = ? AND subject = ?", cfg.Provider, ident.Subject).Count(&count)
+		if count != 0 {
+			t.Fatalf("%s: 未关联的身份被写入了 user_identities", username)
+		}
+	}
+
+	// 管理员显式关联后可以登录
+	if _, err := LinkIdentity(db, admin.ID, cfg.Provider, "sub-admin"); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+	user, created, err := ResolveOIDCUser(db, cfg, &OIDCIdentity{Subject: "sub-admin", Username: "someone-else"})
+	if err != nil || created || user.ID != admin.ID {
+		t.Fatalf("关联后 ResolveOIDCUser = (%v, %v, %v), want 用户 %d", user, created, err, admin.ID)
+	}
+	// 同一身份不能再关联到其他账号
+	if _, err := LinkIdentity(db, alice.ID, cfg.Provider, "sub-admin"); !errors.Is(err, ErrIdentityInUse) {
+		t.Fatalf("LinkIdentity err = %v, want ErrIdentityInUse", err)
+	}
+
+	// 本地没有同名账号时自动创建并关联
+	user, created, err = ResolveOIDCUser(db, cfg, &OIDCIdentity{Subject: "sub-new", Username: "test_oidc_bob"})
+	if err != nil || !created || user.RoleID != studentRole.ID {
+		t.Fatalf("自动创建 ResolveOIDCUser = (%v, %v, %v)", user, created, err)
+	}
+
+	// 默认角色拥有管理员权限时不自动创建
+	cfg.DefaultRole = adminRole.RoleName
+	if _, _, err := ResolveOIDCUser(db, cfg, &OIDCIdentity{Subject: "sub-evil", Username: "test_oidc_evil"}); err == nil {
+		t.Fatal("默认角色为管理员时不应自动创建账号")
+	}
+}
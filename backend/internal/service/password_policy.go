@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// 密码字符类型
+const (
+	CharLower  = "lower"
+	CharUpper  = "upper"
+	CharDigit  = "digit"
+	CharSymbol = "symbol"
+)
+
+var charClassNames = map[string]string{
+	CharLower:  "小写字母",
+	CharUpper:  "大写字母",
+	CharDigit:  "数字",
+	CharSymbol: "特殊字符",
+}
+
+// ErrPasswordReused 新密码与最近使用过的密码相同
+var ErrPasswordReused = errors.New("不能使用最近用过的密码")
+
+// PasswordPolicyError 密码不符合策略，Violations 为全部不满足的规则
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "密码不符合安全策略：" + strings.Join(e.Violations, "；")
+}
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength       int             // 最短长度
+	MaxLength       int             // 最大长度（bcrypt 只使用前 72 字节）
+	RequiredClasses []string        // 必须包含的字符类型（CharLower 等）
+	Blacklist       map[string]bool // 常见弱密码（小写）
+	CheckUsername   bool            // 不得包含用户名（忽略大小写）
+	HistorySize     int             // 不得与最近 N 个密码相同（含当前密码），0 表示不检查
+}
+
+// commonPasswords 内置的常见弱密码
+var commonPasswords = []string{
+	"123456", "1234567", "12345678", "123456789", "1234567890", "111111", "000000", "888888",
+	"123123", "654321", "666666", "112233", "abc123", "abc12345", "a123456", "a12345678",
+	"password", "password1", "password123", "passw0rd", "qwerty", "qwerty123", "qwertyuiop",
+	"iloveyou", "admin", "admin123", "admin888", "root", "welcome", "letmein", "changeme",
+	"student", "student123", "teacher", "teacher123", "parent123", "woaini", "woaini1314",
+}
+
+// DefaultPasswordPolicy 默认密码策略
+func DefaultPasswordPolicy() PasswordPolicy {
+	blacklist := make(map[string]bool, len(commonPasswords))
+	for _, p := range commonPasswords {
+		blacklist[p] = true
+	}
+	return PasswordPolicy{
+		MinLength:       8,
+		MaxLength:       72,
+		RequiredClasses: []string{CharLower, CharDigit},
+		Blacklist:       blacklist,
+		CheckUsername:   true,
+		HistorySize:     5,
+	}
+}
+
+// IsCharClass 判断字符类型名称是否有效
+func IsCharClass(name string) bool {
+	_, ok := charClassNames[name]
+	return ok
+}
+
+// Validate 检查密码是否符合策略（不含历史密码检查）
+func (p PasswordPolicy) Validate(username, password string) error {
+	var violations []string
+	if p.MinLength > 0 && len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("长度不能少于 %d 个字符", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("长度不能超过 %d 个字节", p.MaxLength))
+	}
+
+	present := make(map[string]bool)
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			present[CharLower] = true
+		case unicode.IsUpper(r):
+			present[CharUpper] = true
+		case unicode.IsDigit(r):
+			present[CharDigit] = true
+		default:
+			present[CharSymbol] = true
+		}
+	}
+	for _, class := range p.RequiredClasses {
+		if !present[class] {
+			violations = append(violations, "必须包含"+charClassNames[class])
+		}
+	}
+
+	lower := strings.ToLower(password)
+	if p.Blacklist[lower] {
+		violations = append(violations, "密码过于常见")
+	}
+	if p.CheckUsername && username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		violations = append(violations, "不能包含用户名")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// SetPassword 按策略为用户设置新密码
+// 检查通过后保存新哈希，旧哈希写入密码历史（只保留最近 HistorySize 条），并使已签发的令牌全部失效；
+// mustChange 指定之后是否仍须修改密码（用户自己修改时为 false，管理员重置时通常为 true）
+func SetPassword(db *gorm.DB, user *models.User, password string, policy PasswordPolicy, mustChange bool) error {
+	if err := policy.Validate(user.Username, password); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if policy.HistorySize > 0 && user.ID > 0 {
+			if utils.CheckPasswordHash(password, user.Password) {
+				return ErrPasswordReused
+			}
+			var history []models.PasswordHistory
+			if err := tx.Where("user_id = ?", user.ID).Order("id DESC").Limit(policy.HistorySize - 1).
+				Find(&history).Error; err != nil {
+				return err
+			}
+			for _, h := range history {
+				if utils.CheckPasswordHash(password, h.Password) {
+					return ErrPasswordReused
+				}
+			}
+		}
+
+		hashed, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		if user.ID > 0 && user.Password != "" {
+			if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}).Error; err != nil {
+				return err
+			}
+			if err := trimPasswordHistory(tx, user.ID, policy.HistorySize); err != nil {
+				return err
+			}
+		}
+
+		user.Password = hashed
+		user.MustChangePassword = mustChange
+		if user.ID == 0 {
+			return nil
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":             hashed,
+			"must_change_password": mustChange,
+		}).Error; err != nil {
+			return err
+		}
+		// 密码变更后，之前签发的令牌和会话全部失效
+		if err := RevokeUserTokens(tx, user.ID); err != nil {
+			return err
+		}
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// trimPasswordHistory 只保留最近 keep 条历史（keep 为 0 时全部删除）
+func trimPasswordHistory(tx *gorm.DB, userID uint, keep int) error {
+	var ids []uint
+	if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).Order("id DESC").
+		Offset(keep).Limit(1000).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ?", ids).Delete(&models.PasswordHistory{}).Error
+}
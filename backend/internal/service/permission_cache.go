@@ -0,0 +1,174 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 角色 → 有效权限的进程内缓存，避免每个请求都查询角色继承链与 role_permissions
+
+// InvalidationBus 多实例部署时在实例之间广播缓存失效
+type InvalidationBus interface {
+	// Publish 通知其他实例角色 roleID 的权限已变化（0 表示全部角色）
+	Publish(roleID uint) error
+	// Subscribe 开始接收其他实例发布的失效通知
+	Subscribe(handler func(roleID uint)) error
+}
+
+// PermissionCache 带有效期的角色权限缓存
+// 本实例修改角色权限后调用 Invalidate 立即失效；其他实例通过 InvalidationBus 得知，未配置时最迟在有效期后生效
+type PermissionCache struct {
+	ttl  time.Duration
+	bus  InvalidationBus
+	load func(db *gorm.DB, roleID uint) (*PermissionSet, error) // 未命中时查询，默认 LoadPermissionSet（测试中替换）
+
+	mu         sync.RWMutex
+	entries    map[uint]permissionEntry
+	generation uint64 // 每次失效递增，防止失效前开始的查询把旧结果写回缓存
+}
+
+type permissionEntry struct {
+	set     *PermissionSet
+	expires time.Time
+}
+
+// NewPermissionCache 创建缓存；ttl 为 0 时不缓存，每次都查询数据库
+func NewPermissionCache(ttl time.Duration) *PermissionCache {
+	return &PermissionCache{ttl: ttl, load: LoadPermissionSet, entries: make(map[uint]permissionEntry)}
+}
+
+// SetBus 设置失效广播（为 nil 时只在本实例失效）
+func (c *PermissionCache) SetBus(bus InvalidationBus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bus = bus
+}
+
+// Listen 订阅其他实例的失效通知；未设置广播时不做任何事
+func (c *PermissionCache) Listen() error {
+	c.mu.RLock()
+	bus := c.bus
+	c.mu.RUnlock()
+	if bus == nil {
+		return nil
+	}
+	return bus.Subscribe(c.invalidateLocal)
+}
+
+// Permissions 角色的有效权限（含继承、通配符与拒绝）
+// 返回的集合由缓存共享，调用方只能读取不能修改
+func (c *PermissionCache) Permissions(db *gorm.DB, roleID uint) (*PermissionSet, error) {
+	if c.ttl <= 0 {
+		return c.load(db, roleID)
+	}
+
+	now := time.Now()
+	c.mu.RLock()
+	entry, ok := c.entries[roleID]
+	gen := c.generation
+	c.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.set, nil
+	}
+
+	set, err := c.load(db, roleID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.generation == gen {
+		c.entries[roleID] = permissionEntry{set: set, expires: now.Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return set, nil
+}
+
+// Invalidate 角色权限或上级角色变化后立即失效本实例缓存（含继承该角色的下级角色），并通知其他实例（roleID 为 0 表示全部角色）
+func (c *PermissionCache) Invalidate(roleID uint) {
+	c.invalidateLocal(roleID)
+	c.mu.RLock()
+	bus := c.bus
+	c.mu.RUnlock()
+	if bus != nil {
+		if err := bus.Publish(roleID); err != nil {
+			log.Printf("广播权限缓存失效失败（其他实例将在缓存过期后生效）: %v", err)
+		}
+	}
+}
+
+// InvalidateAll 失效全部角色（如权限表本身被修改）
+func (c *PermissionCache) InvalidateAll() {
+	c.Invalidate(0)
+}
+
+func (c *PermissionCache) invalidateLocal(roleID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if roleID == 0 {
+		c.entries = make(map[uint]permissionEntry)
+		return
+	}
+	for id, entry := range c.entries {
+		if entry.set.dependsOn(roleID) {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// DBInvalidationBus 通过数据库表 permission_invalidations 广播失效：发布时插入一行，各实例定期轮询新行
+// 不需要额外的中间件，适合实例数不多的部署；轮询间隔即其他实例的最大延迟
+type DBInvalidationBus struct {
+	db       *gorm.DB
+	interval time.Duration
+}
+
+// permissionInvalidationRetention 失效记录的保留时间，超过后由轮询顺带清理
+const permissionInvalidationRetention = time.Hour
+
+// NewDBInvalidationBus 创建基于数据库的失效广播
+func NewDBInvalidationBus(db *gorm.DB, interval time.Duration) *DBInvalidationBus {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return &DBInvalidationBus{db: db, interval: interval}
+}
+
+// Publish 插入一条失效记录
+func (b *DBInvalidationBus) Publish(roleID uint) error {
+	return b.db.Create(&models.PermissionInvalidation{RoleID: roleID}).Error
+}
+
+// Subscribe 从当前最新的记录之后开始轮询
+func (b *DBInvalidationBus) Subscribe(handler func(roleID uint)) error {
+	var last uint
+	if err := b.db.Model(&models.PermissionInvalidation{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		lastCleanup := time.Now()
+		for range ticker.C {
+			var rows []models.PermissionInvalidation
+			if err := b.db.Where("id > ?", last).Order("id ASC").Find(&rows).Error; err != nil {
+				log.Printf("轮询权限缓存失效记录失败: %v", err)
+				continue
+			}
+			for _, row := range rows {
+				handler(row.RoleID)
+				last = row.ID
+			}
+			if time.Since(lastCleanup) > permissionInvalidationRetention {
+				lastCleanup = time.Now()
+				b.db.Where("created_at < ?", time.Now().Add(-permissionInvalidationRetention)).Delete(&models.PermissionInvalidation{})
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeLoadLatency 模拟一次查询继承链与 role_permissions 的数据库往返
+const fakeLoadLatency = 100 * time.Microsecond
+
+// fakePermissionLoader 不连接数据库的权限查询：角色 N 继承角色 0（基础角色），各有 50 条精确授权、一条通配符授权和一条拒绝
+type fakePermissionLoader struct {
+	latency time.Duration
+	loads   atomic.Int64
+}
+
+func (f *fakePermissionLoader) load(_ *gorm.DB, roleID uint) (*PermissionSet, error) {
+	f.loads.Add(1)
+	if f.latency > 0 {
+		time.Sleep(f.latency)
+	}
+	set := &PermissionSet{
+		roles: []RoleRef{{ID: roleID, RoleName: fmt.Sprint("role_", roleID)}, {ID: 0, RoleName: "base"}},
+		exact: make(map[string]PermissionGrant),
+	}
+	for depth, role := range set.roles {
+		for i := 0; i < 50; i++ {
+			code := fmt.Sprintf("module%d:resource%d:read", depth, i)
+			set.exact[code] = PermissionGrant{Pattern: code, Effect: EffectAllow, RoleID: role.ID, RoleName: role.RoleName, depth: depth}
+		}
+	}
+	set.wildcards = []PermissionGrant{{Pattern: "report:*", Effect: EffectAllow, RoleID: roleID}}
+	set.denies = []PermissionGrant{{Pattern: "module0:resource0:read", Effect: EffectDeny, RoleID: roleID}}
+	return set, nil
+}
+
+func newFakePermissionCache(ttl, latency time.Duration) (*PermissionCache, *fakePermissionLoader) {
+	loader := &fakePermissionLoader{latency: latency}
+	cache := NewPermissionCache(ttl)
+	cache.load = loader.load
+	return cache, loader
+}
+
+// 命中时不再查询；失效角色本身或其上级角色后重新查询，失效无关角色不影响
+func TestPermissionCacheInvalidate(t *testing.T) {
+	cache, loader := newFakePermissionCache(time.Minute, 0)
+	get := func(roleID uint) {
+		t.Helper()
+		set, err := cache.Permissions(nil, roleID)
+		if err != nil || !set.Has("module1:resource1:read") || set.Has("module0:resource0:read") {
+			t.Fatalf("Permissions(%d) = %v, %v", roleID, set, err)
+		}
+	}
+
+	get(1)
+	get(1)
+	if n := loader.loads.Load(); n != 1 {
+		t.Fatalf("命中缓存后查询了 %d 次，want 1", n)
+	}
+	cache.Invalidate(2)
+	get(1)
+	if n := loader.loads.Load(); n != 1 {
+		t.Fatalf("失效无关角色后查询了 %d 次，want 1", n)
+	}
+	cache.Invalidate(0)
+	get(1)
+	if n := loader.loads.Load(); n != 2 {
+		t.Fatalf("失效全部角色后查询了 %d 次，want 2", n)
+	}
+}
+
+// 每次查询都访问（模拟的）数据库，即 ttl 为 0 时的行为
+func BenchmarkPermissionCacheDisabled(b *testing.B) {
+	cache, _ := newFakePermissionCache(0, fakeLoadLatency)
+	benchmarkPermissions(b, cache, 0)
+}
+
+// 命中缓存：AuthMiddleware 的常见路径
+func BenchmarkPermissionCacheHit(b *testing.B) {
+	cache, _ := newFakePermissionCache(time.Minute, fakeLoadLatency)
+	benchmarkPermissions(b, cache, 0)
+}
+
+// 命中为主，穿插角色权限修改导致的失效
+func BenchmarkPermissionCacheInvalidate(b *testing.B) {
+	cache, _ := newFakePermissionCache(time.Minute, fakeLoadLatency)
+	benchmarkPermissions(b, cache, 1000)
+}
+
+// benchmarkPermissions 并发查询 8 个角色的权限并检查一项权限；invalidateEvery 大于 0 时每隔这么多次查询失效一个角色
+func benchmarkPermissions(b *testing.B, cache *PermissionCache, invalidateEvery int64) {
+	const roles = 8
+	var ops atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := ops.Add(1)
+			roleID := uint(n%roles) + 1
+			if invalidateEvery > 0 && n%invalidateEvery == 0 {
+				cache.Invalidate(roleID)
+			}
+			set, err := cache.Permissions(nil, roleID)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !set.Has("module1:resource7:read") {
+				b.Fatal("缺少权限")
+			}
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 层级权限：通配符授权（admin:*）、角色继承（班主任继承教师）与显式拒绝
+
+// 权限规则的效果
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// maxRoleDepth 角色继承链的最大层数（含角色本身）
+const maxRoleDepth = 8
+
+// ErrRoleInheritanceCycle 设置上级角色会形成循环继承
+var ErrRoleInheritanceCycle = errors.New("不能继承自身或下级角色")
+
+// ValidatePermissionPattern 校验权限标识或通配符：以冒号分隔的各段不能为空，* 只能作为最后一整段（如 admin:*、db:grades:*，或单独的 * 表示全部）
+func ValidatePermissionPattern(pattern string) error {
+	segments := strings.Split(pattern, ":")
+	for i, seg := range segments {
+		if seg == "" {
+			return fmt.Errorf("权限标识 %q 格式错误", pattern)
+		}
+		if strings.Contains(seg, "*") && (seg != "*" || i != len(segments)-1) {
+			return fmt.Errorf("权限标识 %q 中的 * 只能作为最后一段", pattern)
+		}
+	}
+	return nil
+}
+
+// IsPermissionWildcard 是否为通配符（以 * 结尾）
+func IsPermissionWildcard(pattern string) bool {
+	return strings.HasSuffix(pattern, "*")
+}
+
+// matchPermissionPattern 权限是否匹配模式：以 * 结尾的模式按前缀匹配（如 admin:*），否则须完全相同
+func matchPermissionPattern(pattern, permission string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(permission, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == permission
+}
+
+// RoleRef 继承链上的一个角色
+type RoleRef struct {
+	ID       uint   `json:"id"`
+	RoleName string `json:"role_name"`
+}
+
+// PermissionGrant 一条授权或拒绝及其来源
+type PermissionGrant struct {
+	Pattern   string `json:"pattern"`   // 权限标识或通配符
+	Effect    string `json:"effect"`    // allow 或 deny
+	RoleID    uint   `json:"role_id"`   // 规则所在的角色
+	RoleName  string `json:"role_name"` // 规则所在的角色名
+	Inherited bool   `json:"inherited"` // 是否继承自上级角色
+
+	depth int // 所在角色在继承链中的位置，0 为角色本身
+}
+
+// PermissionSet 角色的有效权限：角色及其全部上级角色的授权之和，再去掉任一角色上的显式拒绝（拒绝优先）
+// 由缓存共享，调用方只能读取不能修改
+type PermissionSet struct {
+	roles     []RoleRef                  // 继承链，角色本身在前
+	exact     map[string]PermissionGrant // 精确授权，同一权限保留最近的角色
+	wildcards []PermissionGrant          // 通配符授权，按继承链由近到远
+	denies    []PermissionGrant          // 显式拒绝（精确或通配符）
+}
+
+// Has 是否拥有权限
+func (s *PermissionSet) Has(permission string) bool {
+	allow, deny := s.Explain(permission)
+	return allow != nil && deny == nil
+}
+
+// Explain 匹配权限的授权与拒绝（各取继承链上最近的一条，不存在时为 nil）；拒绝不为 nil 时没有该权限
+func (s *PermissionSet) Explain(permission string) (allow, deny *PermissionGrant) {
+	if s == nil {
+		return nil, nil
+	}
+	for i := range s.denies {
+		if matchPermissionPattern(s.denies[i].Pattern, permission) {
+			deny = &s.denies[i]
+			break
+		}
+	}
+	if g, ok := s.exact[permission]; ok {
+		allow = &g
+	}
+	for i := range s.wildcards {
+		if matchPermissionPattern(s.wildcards[i].Pattern, permission) {
+			// 同一角色上同时有精确授权和通配符时展示精确授权
+			if allow == nil || s.wildcards[i].depth < allow.depth {
+				allow = &s.wildcards[i]
+			}
+			break
+		}
+	}
+	return allow, deny
+}
+
+// Effective 有效权限在 catalog（权限表中的全部标识）中展开后的列表，保持 catalog 的顺序
+func (s *PermissionSet) Effective(catalog []string) []string {
+	permissions := make([]string, 0)
+	for _, p := range catalog {
+		if s.Has(p) {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+// Grants 全部授权与拒绝规则，按继承链由近到远
+func (s *PermissionSet) Grants() []PermissionGrant {
+	if s == nil {
+		return nil
+	}
+	grants := make([]PermissionGrant, 0, len(s.exact)+len(s.wildcards)+len(s.denies))
+	for _, g := range s.exact {
+		grants = append(grants, g)
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if grants[i].depth != grants[j].depth {
+			return grants[i].depth < grants[j].depth
+		}
+		return grants[i].Pattern < grants[j].Pattern
+	})
+	grants = append(grants, s.wildcards...)
+	return append(grants, s.denies...)
+}
+
+// Roles 继承链（角色本身在前）
+func (s *PermissionSet) Roles() []RoleRef {
+	if s == nil {
+		return nil
+	}
+	return s.roles
+}
+
+// dependsOn 权限集合是否受角色 roleID 的修改影响
+func (s *PermissionSet) dependsOn(roleID uint) bool {
+	for _, role := range s.roles {
+		if role.ID == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleChain 角色及其上级角色（角色本身在前）；角色不存在或已删除时为空，上级角色已删除时链在此截断
+func RoleChain(db *gorm.DB, roleID uint) ([]RoleRef, error) {
+	var chain []RoleRef
+	seen := make(map[uint]bool)
+	for id := roleID; id != 0; {
+		if seen[id] || len(chain) >= maxRoleDepth {
+			log.Printf("角色 %d 的继承链存在循环或超过 %d 层，已截断", roleID, maxRoleDepth)
+			break
+		}
+		seen[id] = true
+		var role models.Role
+		err := db.Select("id", "role_name", "parent_id").First(&role, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, RoleRef{ID: role.ID, RoleName: role.RoleName})
+		id = 0
+		if role.ParentID != nil {
+			id = *role.ParentID
+		}
+	}
+	return chain, nil
+}
+
+// ValidateRoleParent 校验角色 roleID 的上级角色（roleID 为 0 表示新建角色）：上级须存在，不能形成循环，继承链不能超过最大层数
+func ValidateRoleParent(db *gorm.DB, roleID, parentID uint) error {
+	if parentID == roleID {
+		return ErrRoleInheritanceCycle
+	}
+	chain, err := RoleChain(db, parentID)
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		return errors.New("上级角色不存在")
+	}
+	for _, role := range chain {
+		if role.ID == roleID {
+			return ErrRoleInheritanceCycle
+		}
+	}
+	if len(chain) >= maxRoleDepth {
+		return fmt.Errorf("角色继承不能超过 %d 层", maxRoleDepth)
+	}
+	return nil
+}
+
+// LoadPermissionSet 从数据库查询角色的有效权限（角色不存在时为空集合）
+func LoadPermissionSet(db *gorm.DB, roleID uint) (*PermissionSet, error) {
+	chain, err := RoleChain(db, roleID)
+	if err != nil {
+		return nil, err
+	}
+	set := &PermissionSet{roles: chain, exact: make(map[string]PermissionGrant)}
+	if len(chain) == 0 {
+		return set, nil
+	}
+	ids := make([]uint, len(chain))
+	depth := make(map[uint]int, len(chain))
+	for i, role := range chain {
+		ids[i] = role.ID
+		depth[role.ID] = i
+	}
+	grant := func(roleID uint, pattern, effect string) PermissionGrant {
+		role := chain[depth[roleID]]
+		return PermissionGrant{Pattern: pattern, Effect: effect, RoleID: role.ID, RoleName: role.RoleName, Inherited: depth[roleID] > 0, depth: depth[roleID]}
+	}
+
+	var exact []struct {
+		RoleID     uint
+		Permission string
+	}
+	if err := db.Table("permissions").
+		Select("rp.role_id, permissions.permission").
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Where("rp.role_id IN ? AND permissions.deleted_at IS NULL", ids).
+		Scan(&exact).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range exact {
+		if g, ok := set.exact[row.Permission]; !ok || depth[row.RoleID] < g.depth {
+			set.exact[row.Permission] = grant(row.RoleID, row.Permission, EffectAllow)
+		}
+	}
+
+	var rules []models.RolePermissionRule
+	if err := db.Where("role_id IN ?", ids).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	// 按继承链由近到远排列，Explain 取第一条匹配的规则
+	for _, role := range chain {
+		for _, rule := range rules {
+			if rule.RoleID != role.ID {
+				continue
+			}
+			switch rule.Effect {
+			case EffectAllow:
+				set.wildcards = append(set.wildcards, grant(rule.RoleID, rule.Pattern, EffectAllow))
+			case EffectDeny:
+				set.denies = append(set.denies, grant(rule.RoleID, rule.Pattern, EffectDeny))
+			}
+		}
+	}
+	return set, nil
+}
+
+// PermissionCatalog 权限表中未废弃的全部权限标识（按 ID 排序），用于把通配符展开为具体权限
+func PermissionCatalog(db *gorm.DB) ([]string, error) {
+	var codes []string
+	err := db.Model(&models.Permission{}).Where("obsolete = ?", false).Order("id ASC").Pluck("permission", &codes).Error
+	return codes, err
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"student-management-system/internal/models"
+)
+
+func TestMatchPermissionPattern(t *testing.T) {
+	cases := []struct {
+		pattern, permission string
+		want                bool
+	}{
+		{"student:read", "student:read", true},
+		{"student:read", "student:update", false},
+		{"student:read", "student:read:all", false},
+		{"*", "student:read", true},
+		{"*", "admin:users:delete", true},
+		{"admin:*", "admin:users", true},
+		{"admin:*", "admin:users:delete", true},
+		{"admin:*", "admin", false},
+		{"admin:*", "administrator:read", false},
+		{"db:grades:*", "db:grades:update", true},
+		{"db:grades:*", "db:students:update", false},
+	}
+	for _, tc := range cases {
+		if got := matchPermissionPattern(tc.pattern, tc.permission); got != tc.want {
+			t.Errorf("matchPermissionPattern(%q, %q) = %v, want %v", tc.pattern, tc.permission, got, tc.want)
+		}
+	}
+}
+
+func TestValidatePermissionPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"student:read", true},
+		{"admin:*", true},
+		{"db:grades:*", true},
+		{"*", true},
+		{"", false},
+		{"student:", false},
+		{":read", false},
+		{"student::read", false},
+		{"admin*", false},
+		{"admin:*:read", false},
+		{"admin:user*", false},
+		{"*:read", false},
+		{"admin:**", false},
+	}
+	for _, tc := range cases {
+		if err := ValidatePermissionPattern(tc.pattern); (err == nil) != tc.valid {
+			t.Errorf("ValidatePermissionPattern(%q) = %v, want valid=%v", tc.pattern, err, tc.valid)
+		}
+	}
+}
+
+// testPermissionSet 按 LoadPermissionSet 的方式组装权限集合：班主任（3）继承教师（2），教师继承基础角色（1）
+func testPermissionSet() *PermissionSet {
+	roles := []RoleRef{{ID: 3, RoleName: "head_teacher"}, {ID: 2, RoleName: "teacher"}, {ID: 1, RoleName: "base"}}
+	grant := func(depth int, pattern, effect string) PermissionGrant {
+		role := roles[depth]
+		return PermissionGrant{Pattern: pattern, Effect: effect, RoleID: role.ID, RoleName: role.RoleName, Inherited: depth > 0, depth: depth}
+	}
+	return &PermissionSet{
+		roles: roles,
+		exact: map[string]PermissionGrant{
+			"student:read":       grant(1, "student:read", EffectAllow), // 基础角色上也有，保留较近的教师
+			"grade:update":       grant(2, "grade:update", EffectAllow),
+			"report:read":        grant(0, "report:read", EffectAllow),
+			"admin:users:delete": grant(2, "admin:users:delete", EffectAllow),
+		},
+		wildcards: []PermissionGrant{
+			grant(0, "grade:*", EffectAllow),
+			grant(0, "report:*", EffectAllow),
+			grant(1, "course:*", EffectAllow),
+			grant(2, "admin:*", EffectAllow),
+		},
+		denies: []PermissionGrant{
+			grant(0, "admin:users:delete", EffectDeny),
+			grant(1, "course:delete", EffectDeny),
+		},
+	}
+}
+
+func TestPermissionSetExplain(t *testing.T) {
+	set := testPermissionSet()
+	cases := []struct {
+		name       string
+		permission string
+		allow      string // 授权的模式及所在角色，nil 时为空
+		deny       string
+		has        bool
+	}{
+		{"继承的精确授权取最近的角色", "student:read", "student:read@teacher", "", true},
+		{"较近角色的通配符优先于较远的精确授权", "grade:update", "grade:*@head_teacher", "", true},
+		{"同一角色上精确授权优先于通配符", "report:read", "report:read@head_teacher", "", true},
+		{"继承的通配符", "course:create", "course:*@teacher", "", true},
+		{"本角色的拒绝覆盖继承的精确授权", "admin:users:delete", "admin:users:delete@base", "admin:users:delete@head_teacher", false},
+		{"拒绝只影响匹配的权限", "admin:users:read", "admin:*@base", "", true},
+		{"继承的拒绝覆盖继承的通配符", "course:delete", "course:*@teacher", "course:delete@teacher", false},
+		{"没有匹配的授权", "student:delete", "", "", false},
+		{"通配符不匹配去掉冒号的前缀", "admin", "", "", false},
+	}
+	describe := func(g *PermissionGrant) string {
+		if g == nil {
+			return ""
+		}
+		return g.Pattern + "@" + g.RoleName
+	}
+	for _, tc := range cases {
+		allow, deny := set.Explain(tc.permission)
+		if got := describe(allow); got != tc.allow {
+			t.Errorf("%s: Explain(%q) allow = %q, want %q", tc.name, tc.permission, got, tc.allow)
+		}
+		if got := describe(deny); got != tc.deny {
+			t.Errorf("%s: Explain(%q) deny = %q, want %q", tc.name, tc.permission, got, tc.deny)
+		}
+		if got := set.Has(tc.permission); got != tc.has {
+			t.Errorf("%s: Has(%q) = %v, want %v", tc.name, tc.permission, got, tc.has)
+		}
+	}
+	if allow, _ := set.Explain("student:read"); allow == nil || !allow.Inherited {
+		t.Errorf("教师上的授权应标记为继承：%+v", allow)
+	}
+}
+
+// * 授予全部权限，显式拒绝仍然优先
+func TestPermissionSetSuperuser(t *testing.T) {
+	set := &PermissionSet{
+		roles:     []RoleRef{{ID: 1, RoleName: "root"}},
+		exact:     map[string]PermissionGrant{},
+		wildcards: []PermissionGrant{{Pattern: "*", Effect: EffectAllow, RoleID: 1, RoleName: "root"}},
+		denies:    []PermissionGrant{{Pattern: "db:*", Effect: EffectDeny, RoleID: 1, RoleName: "root"}},
+	}
+	cases := map[string]bool{"student:read": true, "admin:roles:update": true, "db:sql:execute": false}
+	for permission, want := range cases {
+		if got := set.Has(permission); got != want {
+			t.Errorf("Has(%q) = %v, want %v", permission, got, want)
+		}
+	}
+	if got := set.Effective([]string{"db:sql:execute", "student:read", "admin:roles:update"}); fmt.Sprint(got) != "[student:read admin:roles:update]" {
+		t.Errorf("Effective = %v", got)
+	}
+}
+
+// 继承链存在循环或超过最大层数时截断，不会无限查询
+func TestRoleChainTruncation(t *testing.T) {
+	db := openTestDB(t)
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	setParent := func(role, parent models.Role) {
+		t.Helper()
+		if err := db.Model(&role).Update("parent_id", parent.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(chain []RoleRef) []string {
+		var out []string
+		for _, r := range chain {
+			out = append(out, r.RoleName)
+		}
+		return out
+	}
+
+	a := createTestRole(t, db, "test_cycle_a_"+suffix, false)
+	b := createTestRole(t, db, "test_cycle_b_"+suffix, false)
+	c := createTestRole(t, db, "test_cycle_c_"+suffix, false)
+	setParent(a, b)
+	setParent(b, c)
+	setParent(c, a)
+	chain, err := RoleChain(db, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(names(chain)), fmt.Sprint([]string{a.RoleName, b.RoleName, c.RoleName}); got != want {
+		t.Errorf("循环继承链 = %s, want %s", got, want)
+	}
+	if err := ValidateRoleParent(db, c.ID, a.ID); !errors.Is(err, ErrRoleInheritanceCycle) {
+		t.Errorf("ValidateRoleParent(循环) = %v, want %v", err, ErrRoleInheritanceCycle)
+	}
+
+	var deep []models.Role
+	for i := 0; i < maxRoleDepth+2; i++ {
+		role := createTestRole(t, db, fmt.Sprintf("test_deep_%d_%s", i, suffix), false)
+		if i > 0 {
+			setParent(deep[i-1], role)
+		}
+		deep = append(deep, role)
+	}
+	chain, err = RoleChain(db, deep[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != maxRoleDepth || chain[0].ID != deep[0].ID {
+		t.Errorf("超长继承链 = %v，want 前 %d 层", names(chain), maxRoleDepth)
+	}
+}
+
+// 从数据库加载：下级角色的拒绝覆盖继承自上级角色的通配符授权
+func TestLoadPermissionSetDenyOverridesInherited(t *testing.T) {
+	db := openTestDB(t)
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	parent := createTestRole(t, db, "test_parent_"+suffix, true)
+	child := createTestRole(t, db, "test_child_"+suffix, false)
+	if err := db.Model(&child).Update("parent_id", parent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.RolePermissionRule{RoleID: child.ID, Pattern: "admin:users:*", Effect: EffectDeny}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := LoadPermissionSet(db, child.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allow, deny := set.Explain("admin:users:delete")
+	if allow == nil || allow.RoleID != parent.ID || !allow.Inherited {
+		t.Errorf("allow = %+v，want 继承自 %s 的 admin:*", allow, parent.RoleName)
+	}
+	if deny == nil || deny.RoleID != child.ID || set.Has("admin:users:delete") {
+		t.Errorf("deny = %+v，下级角色的拒绝应覆盖继承的授权", deny)
+	}
+	if !set.Has("admin:roles:read") {
+		t.Error("拒绝不应影响其他继承的权限")
+	}
+
+	parentSet, err := LoadPermissionSet(db, parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parentSet.Has("admin:users:delete") {
+		t.Error("下级角色的拒绝不应影响上级角色")
+	}
+}
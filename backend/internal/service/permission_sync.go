@@ -0,0 +1,95 @@
+package service
+
+import (
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PermissionSyncResult 权限目录同步到 permissions 表的结果
+type PermissionSyncResult struct {
+	Created  []models.Permission // 新增（或恢复已删除）的权限
+	Obsolete []string            // 已不在目录中、本次新标记为废弃的权限
+	Changed  bool                // 权限表有任何变化
+}
+
+// SyncPermissions 把权限目录（由路由表生成）同步到 permissions 表：
+// 新增缺少的权限、按目录更新名称与分组、恢复目录中重新出现的权限；
+// 目录中没有的权限只标记为废弃，不删除，以免丢失仍在使用的角色分配，由管理员确认后清理
+func SyncPermissions(db *gorm.DB, catalog []models.Permission) (*PermissionSyncResult, error) {
+	result := &PermissionSyncResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Permission
+		if err := tx.Unscoped().Find(&existing).Error; err != nil {
+			return err
+		}
+		byCode := make(map[string]models.Permission, len(existing))
+		for _, perm := range existing {
+			byCode[perm.Permission] = perm
+		}
+
+		inCatalog := make(map[string]bool, len(catalog))
+		for _, perm := range catalog {
+			inCatalog[perm.Permission] = true
+			old, ok := byCode[perm.Permission]
+			if !ok {
+				if err := tx.Create(&perm).Error; err != nil {
+					return err
+				}
+				result.Created = append(result.Created, perm)
+				continue
+			}
+			if old.Name == perm.Name && old.Group == perm.Group && !old.Obsolete && !old.DeletedAt.Valid {
+				continue
+			}
+			if err := tx.Unscoped().Model(&old).Updates(map[string]interface{}{
+				"name": perm.Name, "group": perm.Group, "obsolete": false, "deleted_at": nil,
+			}).Error; err != nil {
+				return err
+			}
+			if old.DeletedAt.Valid {
+				result.Created = append(result.Created, old)
+			}
+			result.Changed = true
+		}
+
+		for _, perm := range existing {
+			if inCatalog[perm.Permission] || perm.Obsolete || perm.DeletedAt.Valid {
+				continue
+			}
+			if err := tx.Model(&perm).Update("obsolete", true).Error; err != nil {
+				return err
+			}
+			result.Obsolete = append(result.Obsolete, perm.Permission)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Changed = result.Changed || len(result.Created) > 0 || len(result.Obsolete) > 0
+	return result, nil
+}
+
+// EnsurePermissions 创建权限表中没有的权限（已有的不做任何修改，已删除或废弃的也不恢复），返回新建的权限
+// 用于在同步权限目录之前保证内置角色默认权限引用的权限存在
+func EnsurePermissions(db *gorm.DB, perms []models.Permission) ([]models.Permission, error) {
+	var created []models.Permission
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, perm := range perms {
+			var count int64
+			if err := tx.Unscoped().Model(&models.Permission{}).Where("permission = ?", perm.Permission).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&perm).Error; err != nil {
+				return err
+			}
+			created = append(created, perm)
+		}
+		return nil
+	})
+	return created, err
+}
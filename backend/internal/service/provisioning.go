@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"sort"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 账号与档案的关联是双向的：User.UserID/UserType 指向档案，档案的 UserID 指回账号。
+// 以下函数在同一事务中同时维护两个方向，并锁定相关行，避免并发下出现一个档案绑定多个账号。
+
+var (
+	// ErrProfileAlreadyBound 档案已关联其他账号
+	ErrProfileAlreadyBound = errors.New("该档案已关联其他账号")
+	// ErrProfileNotFound 档案不存在
+	ErrProfileNotFound = errors.New("档案不存在")
+	// ErrNoProfileType 该用户类型没有对应的档案（如管理员）
+	ErrNoProfileType = errors.New("该用户类型不能关联档案")
+	// ErrProfileTypeMismatch 档案类型与用户类型不一致
+	ErrProfileTypeMismatch = errors.New("档案类型与用户类型不一致")
+)
+
+// profileTables 可关联档案的用户类型及其档案表
+var profileTables = map[string]string{
+	"student": "students",
+	"teacher": "teachers",
+	"parent":  "parents",
+}
+
+// HasProfile 判断用户类型是否有对应的档案
+func HasProfile(userType string) bool {
+	_, ok := profileTables[userType]
+	return ok
+}
+
+// profileType 新建档案对应的用户类型及档案ID
+func profileType(profile interface{}) (string, uint) {
+	switch p := profile.(type) {
+	case *models.Student:
+		return "student", p.ID
+	case *models.Teacher:
+		return "teacher", p.ID
+	case *models.Parent:
+		return "parent", p.ID
+	}
+	return "", 0
+}
+
+// CreateAccount 在一个事务中创建账号并关联档案
+// profile 不为 nil 时（*models.Student、*models.Teacher 或 *models.Parent）同时创建该档案；
+// 否则 profileID 大于 0 时关联已有档案
+func CreateAccount(db *gorm.DB, user *models.User, profileID uint, profile interface{}) error {
+	if profile != nil {
+		if t, _ := profileType(profile); t != user.UserType {
+			return ErrProfileTypeMismatch
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if profile != nil {
+			if err := tx.Create(profile).Error; err != nil {
+				return err
+			}
+			_, profileID = profileType(profile)
+		}
+		if profileID == 0 {
+			return nil
+		}
+		return bindProfile(tx, user, profileID)
+	})
+}
+
+// LinkProfile 将已有账号关联到档案；原先关联的档案会被解除
+func LinkProfile(db *gorm.DB, userID, profileID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.UserID == profileID {
+			return bindProfile(tx, &user, profileID)
+		}
+		if err := unbindProfile(tx, &user); err != nil {
+			return err
+		}
+		return bindProfile(tx, &user, profileID)
+	})
+}
+
+// UnlinkProfile 解除账号与档案的关联（两个方向同时清除）
+func UnlinkProfile(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		return unbindProfile(tx, &user)
+	})
+}
+
+// DeleteAccount 删除账号，并清除档案指向该账号的关联
+func DeleteAccount(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if err := unbindProfile(tx, &user); err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+}
+
+// bindProfile 锁定档案并建立双向关联；档案已被其他有效账号关联时返回 ErrProfileAlreadyBound
+func bindProfile(tx *gorm.DB, user *models.User, profileID uint) error {
+	table, ok := profileTables[user.UserType]
+	if !ok {
+		return ErrNoProfileType
+	}
+
+	var profile struct {
+		ID     uint
+		UserID uint
+	}
+	if err := tx.Table(table).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, user_id").
+		Where("id = ? AND deleted_at IS NULL", profileID).Take(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProfileNotFound
+		}
+		return err
+	}
+
+	// 档案指向的账号仍然存在即视为已绑定；账号已删除的悬空关联可以被覆盖
+	if profile.UserID != 0 && profile.UserID != user.ID {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("id = ?", profile.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrProfileAlreadyBound
+		}
+	}
+	// 其他账号指向该档案（反方向的不一致）同样视为已绑定
+	var claimed int64
+	if err := tx.Model(&models.User{}).Where("user_type = ? AND user_id = ? AND id <> ?", user.UserType, profileID, user.ID).
+		Count(&claimed).Error; err != nil {
+		return err
+	}
+	if claimed > 0 {
+		return ErrProfileAlreadyBound
+	}
+
+	if err := tx.Table(table).Where("id = ?", profileID).Update("user_id", user.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(user).Update("user_id", profileID).Error; err != nil {
+		return err
+	}
+	user.UserID = profileID
+	return nil
+}
+
+// unbindProfile 清除账号当前的档案关联（只清除仍指向该账号的档案）
+func unbindProfile(tx *gorm.DB, user *models.User) error {
+	if user.UserID == 0 {
+		return nil
+	}
+	if table, ok := profileTables[user.UserType]; ok {
+		if err := tx.Table(table).Where("id = ? AND user_id = ?", user.UserID, user.ID).Update("user_id", 0).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(user).Update("user_id", 0).Error; err != nil {
+		return err
+	}
+	user.UserID = 0
+	return nil
+}
+
+// LinkIssue 修复账号关联时发现的一处不一致
+type LinkIssue struct {
+	UserType  string `json:"user_type"`
+	UserID    uint   `json:"user_id"`    // 账号ID（users.id），0 表示不涉及账号
+	ProfileID uint   `json:"profile_id"` // 档案ID，0 表示不涉及档案
+	Problem   string `json:"problem"`
+	Action    string `json:"action"`
+}
+
+// errDryRun 用于回滚试运行的事务
+var errDryRun = errors.New("dry run")
+
+// RepairAccountLinks 检查并修复账号与档案之间的不一致，返回发现的问题
+// dryRun 为 true 时只报告问题，不修改数据
+//
+// 修复规则：
+//   - 无档案类型的账号（如管理员）指向档案：清除账号关联
+//   - 账号指向的档案不存在：清除账号关联
+//   - 多个账号指向同一档案：保留档案回指的账号（否则保留ID最小的），清除其余账号的关联
+//   - 档案没有回指唯一指向它的账号：档案改为回指该账号
+//   - 档案指向的账号存在、类型一致且尚未关联档案：为账号补上关联；否则清除档案关联
+func RepairAccountLinks(db *gorm.DB, dryRun bool) ([]LinkIssue, error) {
+	var issues []LinkIssue
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if issues, err = repairLinks(tx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return issues, err
+}
+
+type linkRow struct {
+	ID       uint
+	UserID   uint
+	UserType string
+}
+
+func repairLinks(tx *gorm.DB) ([]LinkIssue, error) {
+	issues := []LinkIssue{}
+
+	var users []*linkRow
+	if err := tx.Model(&models.User{}).Select("id, user_id, user_type").Order("id ASC").
+		Clauses(clause.Locking{Strength: "UPDATE"}).Scan(&users).Error; err != nil {
+		return nil, err
+	}
+	usersByID := make(map[uint]*linkRow, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+
+	clearUser := func(u *linkRow, problem string) error {
+		issues = append(issues, LinkIssue{UserType: u.UserType, UserID: u.ID, ProfileID: u.UserID, Problem: problem, Action: "清除账号关联"})
+		u.UserID = 0
+		return tx.Model(&models.User{}).Where("id = ?", u.ID).Update("user_id", 0).Error
+	}
+
+	for _, u := range users {
+		if u.UserID > 0 && !HasProfile(u.UserType) {
+			if err := clearUser(u, "该用户类型不能关联档案"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	types := make([]string, 0, len(profileTables))
+	for t := range profileTables {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+		table := profileTables[t]
+		var profiles []*linkRow
+		if err := tx.Table(table).Select("id, user_id").Where("deleted_at IS NULL").Order("id ASC").
+			Clauses(clause.Locking{Strength: "UPDATE"}).Scan(&profiles).Error; err != nil {
+			return nil, err
+		}
+		profilesByID := make(map[uint]*linkRow, len(profiles))
+		for _, p := range profiles {
+			profilesByID[p.ID] = p
+		}
+
+		setProfile := func(p *linkRow, userID uint, problem, action string) error {
+			issues = append(issues, LinkIssue{UserType: t, UserID: p.UserID, ProfileID: p.ID, Problem: problem, Action: action})
+			p.UserID = userID
+			return tx.Table(table).Where("id = ?", p.ID).Update("user_id", userID).Error
+		}
+
+		// 账号 -> 档案
+		claims := make(map[uint][]*linkRow)
+		for _, u := range users {
+			if u.UserType != t || u.UserID == 0 {
+				continue
+			}
+			if _, ok := profilesByID[u.UserID]; !ok {
+				if err := clearUser(u, "关联的档案不存在"); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			claims[u.UserID] = append(claims[u.UserID], u)
+		}
+
+		linked := make(map[uint]bool)
+		for _, p := range profiles {
+			claimants := claims[p.ID]
+			if len(claimants) == 0 {
+				continue
+			}
+			owner := claimants[0]
+			for _, u := range claimants {
+				if u.ID == p.UserID {
+					owner = u
+				}
+			}
+			for _, u := range claimants {
+				if u != owner {
+					if err := clearUser(u, "档案已被其他账号关联"); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if p.UserID != owner.ID {
+				if err := setProfile(p, owner.ID, "档案未指回关联它的账号", "档案改为指向该账号"); err != nil {
+					return nil, err
+				}
+			}
+			linked[p.ID] = true
+		}
+
+		// 档案 -> 账号
+		for _, p := range profiles {
+			if p.UserID == 0 || linked[p.ID] {
+				continue
+			}
+			u, ok := usersByID[p.UserID]
+			if ok && u.UserType == t && u.UserID == 0 {
+				issues = append(issues, LinkIssue{UserType: t, UserID: u.ID, ProfileID: p.ID, Problem: "账号未关联指向它的档案", Action: "为账号补上关联"})
+				u.UserID = p.ID
+				if err := tx.Model(&models.User{}).Where("id = ?", u.ID).Update("user_id", p.ID).Error; err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := setProfile(p, 0, "档案指向的账号不存在或已关联其他档案", "清除档案关联"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// TotalScoreType 总评成绩类型，排名时按 FinalScore 同样的口径计算
+const TotalScoreType = "总评"
+
+// 并列处理方式
+const (
+	TieRank  = "rank"  // 并列占位：1, 1, 3
+	TieDense = "dense" // 并列不占位：1, 1, 2
+	TieRow   = "row"   // 不并列，同分按学生ID先后
+)
+
+// ErrInvalidTiePolicy 不支持的并列处理方式
+var ErrInvalidTiePolicy = errors.New("不支持的并列处理方式")
+
+// RankingOptions 排名参数
+type RankingOptions struct {
+	ScoreType  string // 成绩类型，默认总评
+	Tie        string // 并列处理方式，默认 rank
+	Scale      string // 绩点方案（仅全校 GPA 排名使用）
+	SemesterID uint   // 限定学期，0 表示全部
+	CourseID   uint   // 限定课程（仅班级排名使用）
+}
+
+// RankingEntry 排名结果
+type RankingEntry struct {
+	StudentID   uint    `json:"student_id"`
+	Code        string  `json:"code"` // 学号
+	StudentName string  `json:"student_name"`
+	ClassID     uint    `json:"class_id"`
+	Score       float64 `json:"score"`
+	Position    int     `json:"position"`
+	Total       int     `json:"total"`
+	Percentile  float64 `json:"percentile"` // 百分位，第一名为 100
+}
+
+func (o *RankingOptions) normalize() error {
+	if o.ScoreType == "" {
+		o.ScoreType = TotalScoreType
+	}
+	if o.Tie == "" {
+		o.Tie = TieRank
+	}
+	if _, ok := positionExpr[o.Tie]; !ok {
+		return ErrInvalidTiePolicy
+	}
+	return nil
+}
+
+// positionExpr 各并列处理方式对应的窗口函数
+var positionExpr = map[string]string{
+	TieRank:  "RANK() OVER (ORDER BY m.score DESC)",
+	TieDense: "DENSE_RANK() OVER (ORDER BY m.score DESC)",
+	TieRow:   "ROW_NUMBER() OVER (ORDER BY m.score DESC, m.student_id ASC)",
+}
+
+// enrollmentScoreSQL 每条有效选课在指定成绩类型下的分数
+// 与 findScore 一致：同一组别名（如 期末/期末成绩/Final）有多条记录时只取其中最新录入的一条
+func enrollmentScoreSQL(opts RankingOptions) (string, []interface{}) {
+	var expr string
+	var args []interface{}
+	if opts.ScoreType == TotalScoreType {
+		// 与 FinalScore 一致：总评 > 平时/期末加权 > 期末 > 平时
+		expr = `COALESCE(
+            MAX(CASE WHEN g.score_type IN ? THEN g.score END),
+            ROUND(MAX(CASE WHEN g.score_type IN ? THEN g.score END) * ? + MAX(CASE WHEN g.score_type IN ? THEN g.score END) * ?, 2),
+            MAX(CASE WHEN g.score_type IN ? THEN g.score END),
+            MAX(CASE WHEN g.score_type IN ? THEN g.score END))`
+		args = []interface{}{totalScoreTypes, regularScoreTypes, regularWeight, finalScoreTypes, finalWeight,
+			finalScoreTypes, regularScoreTypes}
+	} else {
+		expr = "MAX(CASE WHEN g.score_type IN ? THEN g.score END)"
+		args = []interface{}{scoreTypeAliases(opts.ScoreType)}
+	}
+
+	// lg：每条选课在每组别名中最新录入的成绩
+	query := `SELECT e.id AS enrollment_id, e.student_id, e.course_id, e.semester_id, ` + expr + ` AS score
+        FROM enrollments e
+        JOIN grades g ON g.enrollment_id = e.id AND g.deleted_at IS NULL
+        JOIN (SELECT MAX(id) AS id FROM grades WHERE deleted_at IS NULL
+            GROUP BY enrollment_id, CASE WHEN score_type IN ? THEN 'total' WHEN score_type IN ? THEN 'regular'
+                WHEN score_type IN ? THEN 'final' ELSE score_type END) lg ON lg.id = g.id
+        WHERE e.deleted_at IS NULL`
+	args = append(args, totalScoreTypes, regularScoreTypes, finalScoreTypes)
+	if opts.SemesterID > 0 {
+		query += " AND e.semester_id = ?"
+		args = append(args, opts.SemesterID)
+	}
+	query += " GROUP BY e.id, e.student_id, e.course_id, e.semester_id"
+	return query, args
+}
+
+// scoreTypeAliases 成绩类型所在的一组别名（不属于任何一组时只有它本身）
+func scoreTypeAliases(scoreType string) []string {
+	for _, group := range [][]string{totalScoreTypes, regularScoreTypes, finalScoreTypes} {
+		for _, t := range group {
+			if t == scoreType {
+				return group
+			}
+		}
+	}
+	return []string{scoreType}
+}
+
+// gradePointSQL 按绩点方案（参数）将 fs.score 映射为绩点，低于所有下限时为 0
+const gradePointSQL = `COALESCE((
+                SELECT gps.grade_point FROM grade_point_scales gps
+                WHERE gps.scale = ? AND gps.deleted_at IS NULL AND gps.min_score <= fs.score
+                ORDER BY gps.min_score DESC LIMIT 1
+            ), 0)`
+
+// runRanking 在 metric 子查询（student_id, code, student_name, class_id, score）上计算名次
+func runRanking(db *gorm.DB, opts RankingOptions, metricSQL string, metricArgs []interface{}) ([]RankingEntry, error) {
+	scoreSQL, args := enrollmentScoreSQL(opts)
+	args = append(args, metricArgs...)
+
+	query := `WITH fs AS (` + scoreSQL + `),
+        m AS (` + metricSQL + `)
+        SELECT m.student_id, m.code, m.student_name, m.class_id, m.score,
+            ` + positionExpr[opts.Tie] + ` AS position,
+            COUNT(*) OVER () AS total,
+            ROUND((1 - PERCENT_RANK() OVER (ORDER BY m.score DESC)) * 100, 2) AS percentile
+        FROM m
+        ORDER BY position ASC, m.student_id ASC`
+
+	var entries []RankingEntry
+	if err := db.Raw(query, args...).Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ClassRanking 班级内排名：按学生各课程成绩的平均分（可限定单门课程）
+func ClassRanking(db *gorm.DB, classID uint, opts RankingOptions) ([]RankingEntry, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	metric := `SELECT s.id AS student_id, s.student_id AS code, s.name AS student_name, s.class_id,
+            ROUND(AVG(fs.score), 2) AS score
+        FROM fs JOIN students s ON s.id = fs.student_id AND s.deleted_at IS NULL
+        WHERE fs.score IS NOT NULL AND s.class_id = ?`
+	args := []interface{}{classID}
+	if opts.CourseID > 0 {
+		metric += " AND fs.course_id = ?"
+		args = append(args, opts.CourseID)
+	}
+	metric += " GROUP BY s.id, s.student_id, s.name, s.class_id"
+	return runRanking(db, opts, metric, args)
+}
+
+// CourseRanking 课程内排名
+func CourseRanking(db *gorm.DB, courseID uint, opts RankingOptions) ([]RankingEntry, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	metric := `SELECT s.id AS student_id, s.student_id AS code, s.name AS student_name, s.class_id,
+            fs.score AS score
+        FROM fs JOIN students s ON s.id = fs.student_id AND s.deleted_at IS NULL
+        WHERE fs.score IS NOT NULL AND fs.course_id = ?`
+	return runRanking(db, opts, metric, []interface{}{courseID})
+}
+
+// SchoolRanking 全校 GPA 排名（按学分加权，绩点口径与成绩单一致）
+func SchoolRanking(db *gorm.DB, opts RankingOptions) ([]RankingEntry, error) {
+	opts.ScoreType = TotalScoreType
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	if _, err := LoadGradeScale(db, opts.Scale); err != nil {
+		return nil, err
+	}
+	metric := `SELECT s.id AS student_id, s.student_id AS code, s.name AS student_name, s.class_id,
+            ROUND(SUM(gp.point * c.credits) / SUM(c.credits), 2) AS score
+        FROM (
+            SELECT fs.student_id, fs.course_id, ` + gradePointSQL + ` AS point
+            FROM fs WHERE fs.score IS NOT NULL
+        ) gp
+        JOIN courses c ON c.id = gp.course_id
+        JOIN students s ON s.id = gp.student_id AND s.deleted_at IS NULL
+        GROUP BY s.id, s.student_id, s.name, s.class_id
+        HAVING SUM(c.credits) > 0`
+	return runRanking(db, opts, metric, []interface{}{opts.Scale})
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"student-management-system/internal/models"
+)
+
+// 排名使用的分数与成绩单（FinalScore）一致：同一组别名中取最新录入的一条，而不是分数最高的一条
+func TestEnrollmentScoreMatchesTranscript(t *testing.T) {
+	db := openTestDB(t, &models.Enrollment{}, &models.Grade{})
+
+	enrollment := models.Enrollment{StudentID: 1<<31 - 1, CourseID: 1<<31 - 1}
+	if err := db.Create(&enrollment).Error; err != nil {
+		t.Fatal(err)
+	}
+	grades := []models.Grade{
+		{EnrollmentID: enrollment.ID, ScoreType: "期末", Score: 95},
+		{EnrollmentID: enrollment.ID, ScoreType: "平时成绩", Score: 60},
+		{EnrollmentID: enrollment.ID, ScoreType: "Final", Score: 70}, // 更正后的期末成绩
+		{EnrollmentID: enrollment.ID, ScoreType: "Regular", Score: 80},
+	}
+	for i := range grades {
+		if err := db.Create(&grades[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, _ := FinalScore(grades)
+
+	cases := map[string]float64{TotalScoreType: want, "期末成绩": 70, "平时": 80}
+	for scoreType, want := range cases {
+		query, args := enrollmentScoreSQL(RankingOptions{ScoreType: scoreType})
+		var got *float64
+		if err := db.Raw("SELECT fs.score FROM ("+query+") fs WHERE fs.enrollment_id = ?", append(args, enrollment.ID)...).
+			Scan(&got).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || *got != want {
+			t.Errorf("%s: 排名分数 = %v, want %v", scoreType, got, want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/permission"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 角色直接设置的授权与拒绝：读取、整体替换、与内置角色默认权限清单比较和同步
+
+// ErrInvalidGrant 授权或拒绝列表不合法（格式错误、权限不存在或已废弃、同时授权和拒绝）
+var ErrInvalidGrant = errors.New("权限设置不合法")
+
+// RoleGrants 角色直接设置的授权（精确权限在前、通配符在后）与拒绝，不含继承的权限和已废弃的权限
+type RoleGrants struct {
+	Permissions []string `json:"permissions"`
+	Deny        []string `json:"deny"`
+}
+
+// LoadRoleGrants 查询角色直接设置的授权与拒绝
+func LoadRoleGrants(db *gorm.DB, roleID uint) (*RoleGrants, error) {
+	grants := &RoleGrants{Permissions: make([]string, 0), Deny: make([]string, 0)}
+	var exact []string
+	if err := db.Table("permissions").
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Where("rp.role_id = ? AND permissions.deleted_at IS NULL AND permissions.obsolete = ?", roleID, false).
+		Order("permissions.id ASC").
+		Pluck("permissions.permission", &exact).Error; err != nil {
+		return nil, err
+	}
+	grants.Permissions = append(grants.Permissions, exact...)
+
+	var rules []models.RolePermissionRule
+	if err := db.Where("role_id = ?", roleID).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.Effect == EffectDeny {
+			grants.Deny = append(grants.Deny, rule.Pattern)
+		} else {
+			grants.Permissions = append(grants.Permissions, rule.Pattern)
+		}
+	}
+	return grants, nil
+}
+
+// SetRoleGrants 整体替换角色直接设置的授权与拒绝；deny 为 nil 时保留原有的拒绝规则
+// 精确的权限标识须存在于权限表且未废弃；同一标识不能同时授权和拒绝。校验失败时返回包装了 ErrInvalidGrant 的错误
+func SetRoleGrants(db *gorm.DB, roleID uint, allow []string, deny *[]string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var denyList []string
+		if deny != nil {
+			denyList = *deny
+		}
+		granted, rules, err := resolveGrants(tx, allow, denyList)
+		if err != nil {
+			return err
+		}
+
+		role := models.Role{}
+		role.ID = roleID
+		if err := tx.Model(&role).Association("Permissions").Replace(granted); err != nil {
+			return err
+		}
+		old := tx.Unscoped().Where("role_id = ?", roleID)
+		if deny == nil {
+			old = old.Where("effect = ?", EffectAllow)
+		}
+		if err := old.Delete(&models.RolePermissionRule{}).Error; err != nil {
+			return err
+		}
+		return createRules(tx, roleID, rules)
+	})
+}
+
+// addRoleGrants 在角色现有设置上追加授权与拒绝（不移除任何已有设置）
+func addRoleGrants(tx *gorm.DB, roleID uint, allow, deny []string) error {
+	granted, rules, err := resolveGrants(tx, allow, deny)
+	if err != nil {
+		return err
+	}
+	if len(granted) > 0 {
+		role := models.Role{}
+		role.ID = roleID
+		if err := tx.Model(&role).Association("Permissions").Append(granted); err != nil {
+			return err
+		}
+	}
+	return createRules(tx, roleID, rules)
+}
+
+// resolveGrants 校验授权与拒绝列表，返回精确授权对应的权限记录与需保存为规则的条目
+func resolveGrants(db *gorm.DB, allow, deny []string) ([]models.Permission, []models.RolePermissionRule, error) {
+	exact, rules, err := parseGrantPatterns(allow, EffectAllow)
+	if err != nil {
+		return nil, nil, err
+	}
+	denyExact, denyRules, err := parseGrantPatterns(deny, EffectDeny)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowed := make(map[string]bool)
+	for _, p := range allow {
+		allowed[p] = true
+	}
+	for _, rule := range denyRules {
+		if allowed[rule.Pattern] {
+			return nil, nil, fmt.Errorf("%w：权限 %s 不能同时授权和拒绝", ErrInvalidGrant, rule.Pattern)
+		}
+	}
+	rules = append(rules, denyRules...)
+
+	// 验证权限标识是否存在（已废弃的权限不能再分配）
+	var permissions []models.Permission
+	if err := db.Where("permission IN ? AND obsolete = ?", append(append([]string{}, exact...), denyExact...), false).Find(&permissions).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(permissions) != len(exact)+len(denyExact) {
+		return nil, nil, fmt.Errorf("%w：部分权限标识不存在或已废弃", ErrInvalidGrant)
+	}
+	granted := make([]models.Permission, 0, len(exact))
+	for _, perm := range permissions {
+		if allowed[perm.Permission] {
+			granted = append(granted, perm)
+		}
+	}
+	return granted, rules, nil
+}
+
+// createRules 保存角色的通配符授权与拒绝规则
+func createRules(tx *gorm.DB, roleID uint, rules []models.RolePermissionRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	for i := range rules {
+		rules[i].RoleID = roleID
+	}
+	return tx.Create(&rules).Error
+}
+
+// parseGrantPatterns 校验并去重权限列表，返回精确的权限标识与需保存为规则的条目
+// 授权时只有通配符保存为规则（精确授权保存在 role_permissions）；拒绝全部保存为规则，其中精确的标识一并返回以校验存在
+func parseGrantPatterns(patterns []string, effect string) (exact []string, rules []models.RolePermissionRule, err error) {
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		if err := ValidatePermissionPattern(p); err != nil {
+			return nil, nil, fmt.Errorf("%w：%v", ErrInvalidGrant, err)
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if !IsPermissionWildcard(p) {
+			exact = append(exact, p)
+			if effect == EffectAllow {
+				continue
+			}
+		}
+		rules = append(rules, models.RolePermissionRule{Pattern: p, Effect: effect})
+	}
+	return exact, rules, nil
+}
+
+// RoleGrantsDiff 角色当前的授权与默认清单的差异
+type RoleGrantsDiff struct {
+	Missing RoleGrants `json:"missing"` // 清单中有、角色没有
+	Extra   RoleGrants `json:"extra"`   // 角色有、清单中没有
+}
+
+// Empty 与清单一致
+func (d RoleGrantsDiff) Empty() bool {
+	return len(d.Missing.Permissions)+len(d.Missing.Deny)+len(d.Extra.Permissions)+len(d.Extra.Deny) == 0
+}
+
+// DiffRoleGrants 比较角色当前的授权与默认权限
+func DiffRoleGrants(current *RoleGrants, defaults permission.RoleDefault) RoleGrantsDiff {
+	return RoleGrantsDiff{
+		Missing: RoleGrants{Permissions: subtract(defaults.Allow, current.Permissions), Deny: subtract(defaults.Deny, current.Deny)},
+		Extra:   RoleGrants{Permissions: subtract(current.Permissions, defaults.Allow), Deny: subtract(current.Deny, defaults.Deny)},
+	}
+}
+
+// subtract a 中不在 b 中的项（保持 a 的顺序）
+func subtract(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, s := range b {
+		in[s] = true
+	}
+	diff := make([]string, 0)
+	for _, s := range a {
+		if !in[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// ResetRoleToDefaults 把角色的授权与拒绝恢复为默认权限清单（不改变上级角色），并记录清单各项已应用
+func ResetRoleToDefaults(db *gorm.DB, roleID uint, defaults permission.RoleDefault) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		deny := append([]string{}, defaults.Deny...)
+		if err := SetRoleGrants(tx, roleID, defaults.Allow, &deny); err != nil {
+			return err
+		}
+		return markDefaultsApplied(tx, defaults.Role, defaults.Allow, defaults.Deny)
+	})
+}
+
+// ApplyRoleDefaults 按默认权限清单补齐内置角色的权限，返回权限有变化的角色 ID
+// 清单中的每一项只补一次（记录在 applied_role_defaults），管理员之后撤销的不会再补回；
+// 与角色现有设置冲突的项（如清单授权而管理员已拒绝）以现有设置为准。单个角色失败时记录日志并继续
+func ApplyRoleDefaults(db *gorm.DB, defaults []permission.RoleDefault) []uint {
+	var changed []uint
+	for _, d := range defaults {
+		var role models.Role
+		if err := db.Where("role_name = ?", d.Role).First(&role).Error; err != nil {
+			log.Printf("默认权限：角色 %s 不存在，跳过", d.Role)
+			continue
+		}
+		n, err := applyRoleDefault(db, role.ID, d)
+		if err != nil {
+			log.Printf("默认权限：补齐角色 %s 的权限失败: %v", d.Role, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("默认权限：为角色 %s 补齐 %d 项", d.Role, n)
+			changed = append(changed, role.ID)
+		}
+	}
+	return changed
+}
+
+// applyRoleDefault 补齐一个角色尚未应用过的默认权限，返回补上的项数
+func applyRoleDefault(db *gorm.DB, roleID uint, d permission.RoleDefault) (int, error) {
+	added := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var applied []models.AppliedRoleDefault
+		if err := tx.Where("role_name = ?", d.Role).Find(&applied).Error; err != nil {
+			return err
+		}
+		done := make(map[string]bool, len(applied))
+		for _, a := range applied {
+			done[a.Effect+" "+a.Pattern] = true
+		}
+		pendingAllow := pending(d.Allow, EffectAllow, done)
+		pendingDeny := pending(d.Deny, EffectDeny, done)
+		if len(pendingAllow)+len(pendingDeny) == 0 {
+			return nil
+		}
+
+		current, err := LoadRoleGrants(tx, roleID)
+		if err != nil {
+			return err
+		}
+		newAllow := subtract(subtract(pendingAllow, current.Permissions), current.Deny)
+		newDeny := subtract(subtract(pendingDeny, current.Deny), current.Permissions)
+		added = len(newAllow) + len(newDeny)
+		if err := addRoleGrants(tx, roleID, newAllow, newDeny); err != nil {
+			return err
+		}
+		return markDefaultsApplied(tx, d.Role, pendingAllow, pendingDeny)
+	})
+	return added, err
+}
+
+// pending 清单中尚未应用的项
+func pending(patterns []string, effect string, done map[string]bool) []string {
+	var list []string
+	for _, p := range patterns {
+		if !done[effect+" "+p] {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// markDefaultsApplied 记录清单中的项已应用（已记录的忽略）
+func markDefaultsApplied(tx *gorm.DB, roleName string, allow, deny []string) error {
+	var rows []models.AppliedRoleDefault
+	for _, p := range allow {
+		rows = append(rows, models.AppliedRoleDefault{RoleName: roleName, Pattern: p, Effect: EffectAllow})
+	}
+	for _, p := range deny {
+		rows = append(rows, models.AppliedRoleDefault{RoleName: roleName, Pattern: p, Effect: EffectDeny})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
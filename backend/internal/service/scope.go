@@ -0,0 +1,238 @@
+package service
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// DataScope 请求方可访问的数据范围（行级权限）
+// 教师只能访问：本人授课的课程（Course.TeacherID 或排课中的 Schedule.TeacherID）、
+// 担任班主任的班级（Class.TeacherID）以及排课涉及的班级，及这些课程/班级下的学生
+type DataScope struct {
+	All          bool   // 不受限（管理员等）
+	TeacherID    uint   // 教师ID
+	CourseIDs    []uint // 授课课程
+	HeadClassIDs []uint // 担任班主任的班级
+	ClassIDs     []uint // 班主任班级 + 排课班级
+}
+
+// FullScope 不受限的数据范围
+func FullScope() *DataScope {
+	return &DataScope{All: true}
+}
+
+// EmptyScope 没有任何数据的范围（用于无法确定归属的请求，保证拒绝访问）
+func EmptyScope() *DataScope {
+	return &DataScope{}
+}
+
+// LoadTeacherScope 计算教师的数据范围
+func LoadTeacherScope(db *gorm.DB, teacherID uint) (*DataScope, error) {
+	scope := &DataScope{TeacherID: teacherID}
+	if err := db.Raw(`SELECT id FROM courses WHERE teacher_id = ? AND deleted_at IS NULL
+        UNION SELECT course_id FROM schedules WHERE teacher_id = ? AND deleted_at IS NULL`,
+		teacherID, teacherID).Scan(&scope.CourseIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw(`SELECT id FROM classes WHERE teacher_id = ? AND deleted_at IS NULL`,
+		teacherID).Scan(&scope.HeadClassIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw(`SELECT id FROM classes WHERE teacher_id = ? AND deleted_at IS NULL
+        UNION SELECT class_id FROM schedules WHERE teacher_id = ? AND deleted_at IS NULL`,
+		teacherID, teacherID).Scan(&scope.ClassIDs).Error; err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// rowFilter 生成某张表的行级过滤条件；返回空字符串表示该表不需要过滤
+type rowFilter func(s *DataScope) (string, []interface{})
+
+// tableScope 表的读、写过滤规则；为 nil 时受限用户不能进行该操作
+type tableScope struct {
+	read  rowFilter
+	write rowFilter
+}
+
+// noFilter 受限用户也可以访问整张表（如学期、绩点方案等公共数据）
+func noFilter(*DataScope) (string, []interface{}) { return "", nil }
+
+// studentFilter 范围内的学生：所在班级在范围内，或选修了范围内的课程
+func studentFilter(column string) rowFilter {
+	return func(s *DataScope) (string, []interface{}) {
+		return column + ` IN (SELECT id FROM students WHERE class_id IN ?)
+            OR ` + column + ` IN (SELECT student_id FROM enrollments WHERE course_id IN ? AND deleted_at IS NULL)`,
+			[]interface{}{s.ClassIDs, s.CourseIDs}
+	}
+}
+
+// headClassStudentFilter 班主任班级中的学生
+func headClassStudentFilter(column string) rowFilter {
+	return func(s *DataScope) (string, []interface{}) {
+		return column + " IN (SELECT id FROM students WHERE class_id IN ?)", []interface{}{s.HeadClassIDs}
+	}
+}
+
+func columnIn(column string, ids func(s *DataScope) []uint) rowFilter {
+	return func(s *DataScope) (string, []interface{}) {
+		return column + " IN ?", []interface{}{ids(s)}
+	}
+}
+
+func ownTeacher(column string) rowFilter {
+	return func(s *DataScope) (string, []interface{}) {
+		return column + " = ?", []interface{}{s.TeacherID}
+	}
+}
+
+// notificationFilter 面向全体、本人、范围内班级以及范围内学生及其家长的通知（target 形如 all、class:5、student:12、parent:3）
+func notificationFilter(s *DataScope) (string, []interface{}) {
+	targets := []string{"", "all", fmt.Sprintf("teacher:%d", s.TeacherID)}
+	for _, id := range s.ClassIDs {
+		targets = append(targets, fmt.Sprintf("class:%d", id))
+	}
+	students, args := studentFilter("id")(s)
+	guardians, guardianArgs := studentFilter("student_id")(s)
+	return `target IN ?
+            OR target IN (SELECT CONCAT('student:', id) FROM students WHERE ` + students + `)
+            OR target IN (SELECT CONCAT('parent:', parent_id) FROM student_guardians WHERE ` + guardians + `)`,
+		append(append([]interface{}{targets}, args...), guardianArgs...)
+}
+
+func courses(s *DataScope) []uint     { return s.CourseIDs }
+func classes(s *DataScope) []uint     { return s.ClassIDs }
+func headClasses(s *DataScope) []uint { return s.HeadClassIDs }
+
+// tableScopes 各表的行级规则（列名不带表别名，用于单表查询）
+// 未列出的表对受限用户不可见
+var tableScopes = map[string]tableScope{
+	"students": {read: studentFilter("id"), write: columnIn("class_id", headClasses)},
+	"parents": {
+		read: func(s *DataScope) (string, []interface{}) {
+			cond, args := studentFilter("student_id")(s)
+			return "id IN (SELECT parent_id FROM student_guardians WHERE " + cond + ")", args
+		},
+		write: func(s *DataScope) (string, []interface{}) {
+			cond, args := headClassStudentFilter("student_id")(s)
+			return "id IN (SELECT parent_id FROM student_guardians WHERE " + cond + ")", args
+		},
+	},
+	"teachers": {read: noFilter, write: columnIn("id", func(s *DataScope) []uint { return []uint{s.TeacherID} })},
+	"classes":  {read: columnIn("id", classes), write: columnIn("id", headClasses)},
+	"courses":  {read: columnIn("id", courses), write: columnIn("id", courses)},
+	"enrollments": {
+		read: func(s *DataScope) (string, []interface{}) {
+			return "course_id IN ? OR student_id IN (SELECT id FROM students WHERE class_id IN ?)",
+				[]interface{}{s.CourseIDs, s.ClassIDs}
+		},
+		write: columnIn("course_id", courses),
+	},
+	"grades": {
+		read: func(s *DataScope) (string, []interface{}) {
+			return `enrollment_id IN (SELECT id FROM enrollments WHERE course_id IN ?
+                OR student_id IN (SELECT id FROM students WHERE class_id IN ?))`,
+				[]interface{}{s.CourseIDs, s.ClassIDs}
+		},
+		write: func(s *DataScope) (string, []interface{}) {
+			return "enrollment_id IN (SELECT id FROM enrollments WHERE course_id IN ?)", []interface{}{s.CourseIDs}
+		},
+	},
+	"grade_audit_logs": {
+		read: func(s *DataScope) (string, []interface{}) {
+			return `grade_id IN (SELECT g.id FROM grades g JOIN enrollments e ON e.id = g.enrollment_id
+                WHERE e.course_id IN ?)`, []interface{}{s.CourseIDs}
+		},
+	},
+	"attendances":        {read: studentFilter("student_id"), write: studentFilter("student_id")},
+	"reward_punishments": {read: studentFilter("student_id"), write: headClassStudentFilter("student_id")},
+	"schedules": {
+		read: func(s *DataScope) (string, []interface{}) {
+			return "teacher_id = ? OR class_id IN ? OR course_id IN ?", []interface{}{s.TeacherID, s.ClassIDs, s.CourseIDs}
+		},
+		write: ownTeacher("teacher_id"),
+	},
+	"course_waitlists": {read: columnIn("course_id", courses), write: columnIn("course_id", courses)},
+	"academic_alerts": {
+		read: func(s *DataScope) (string, []interface{}) {
+			cond, args := studentFilter("student_id")(s)
+			return "teacher_id = ? OR " + cond, append([]interface{}{s.TeacherID}, args...)
+		},
+		write: ownTeacher("teacher_id"),
+	},
+	"semesters":               {read: noFilter},
+	"grade_point_scales":      {read: noFilter},
+	"notifications":           {read: notificationFilter},
+	"vw_student_full_profile": {read: studentFilter("student_id")},
+}
+
+// Condition 返回表的行级过滤条件；ok 为 false 表示受限用户无权进行该操作
+func (s *DataScope) Condition(table string, write bool) (cond string, args []interface{}, ok bool) {
+	if s.All {
+		return "", nil, true
+	}
+	rules, found := tableScopes[table]
+	if !found {
+		return "", nil, false
+	}
+	filter := rules.read
+	if write {
+		filter = rules.write
+	}
+	if filter == nil {
+		return "", nil, false
+	}
+	cond, args = filter(s)
+	return cond, args, true
+}
+
+// Apply 为单表查询注入行级过滤条件
+func (s *DataScope) Apply(query *gorm.DB, table string, write bool) (*gorm.DB, bool) {
+	cond, args, ok := s.Condition(table, write)
+	if !ok {
+		return query, false
+	}
+	if cond != "" {
+		query = query.Where("("+cond+")", args...)
+	}
+	return query, true
+}
+
+// Allows 判断指定记录是否在范围内
+func (s *DataScope) Allows(db *gorm.DB, table string, id uint, write bool) bool {
+	if s.All {
+		return true
+	}
+	query, ok := s.Apply(db.Table(table), table, write)
+	if !ok {
+		return false
+	}
+	var count int64
+	query.Where("id = ?", id).Count(&count)
+	return count > 0
+}
+
+// AllowsCourse 判断课程是否为本人授课
+func (s *DataScope) AllowsCourse(courseID uint) bool {
+	return s.All || containsID(s.CourseIDs, courseID)
+}
+
+// AllowsClass 判断班级是否在范围内（班主任班级或排课班级）
+func (s *DataScope) AllowsClass(classID uint) bool {
+	return s.All || containsID(s.ClassIDs, classID)
+}
+
+// AllowsStudent 判断学生是否在范围内
+func (s *DataScope) AllowsStudent(db *gorm.DB, studentID uint) bool {
+	return s.Allows(db, "students", studentID, false)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
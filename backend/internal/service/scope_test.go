@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"student-management-system/internal/models"
+)
+
+// 教师只能读取面向全体、本人、范围内班级及范围内学生和家长的通知
+func TestNotificationScope(t *testing.T) {
+	db := openTestDB(t, &models.Teacher{}, &models.Class{}, &models.Student{}, &models.Parent{},
+		&models.StudentGuardian{}, &models.Enrollment{}, &models.Notification{})
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	teacher := models.Teacher{Name: "test_scope", TeacherID: "test_scope_" + suffix}
+	if err := db.Create(&teacher).Error; err != nil {
+		t.Fatal(err)
+	}
+	own := models.Class{ClassName: "test_scope_own", TeacherID: teacher.ID}
+	other := models.Class{ClassName: "test_scope_other", TeacherID: teacher.ID}
+	if err := db.Create(&own).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	inScope := models.Student{Name: "in", StudentID: "test_scope_in_" + suffix, ClassID: own.ID}
+	outScope := models.Student{Name: "out", StudentID: "test_scope_out_" + suffix, ClassID: other.ID}
+	if err := db.Create(&inScope).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&outScope).Error; err != nil {
+		t.Fatal(err)
+	}
+	inParent := models.Parent{StudentID: inScope.ID, Name: "in", Phone: "1"}
+	outParent := models.Parent{StudentID: outScope.ID, Name: "out", Phone: "2"}
+	if err := db.Create(&inParent).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&outParent).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&[]models.StudentGuardian{
+		{StudentID: inScope.ID, ParentID: inParent.ID},
+		{StudentID: outScope.ID, ParentID: outParent.ID},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	targets := map[string]bool{
+		"all":                                   true,
+		fmt.Sprintf("teacher:%d", teacher.ID):   true,
+		fmt.Sprintf("teacher:%d", teacher.ID+1): false,
+		fmt.Sprintf("class:%d", own.ID):         true,
+		fmt.Sprintf("class:%d", other.ID):       false,
+		fmt.Sprintf("student:%d", inScope.ID):   true,
+		fmt.Sprintf("student:%d", outScope.ID):  false,
+		fmt.Sprintf("parent:%d", inParent.ID):   true,
+		fmt.Sprintf("parent:%d", outParent.ID):  false,
+	}
+	var want []string
+	for target, visible := range targets {
+		if err := db.Create(&models.Notification{Title: "test_scope_" + suffix, Target: target}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if visible {
+			want = append(want, target)
+		}
+	}
+
+	scope := &DataScope{TeacherID: teacher.ID, HeadClassIDs: []uint{own.ID}, ClassIDs: []uint{own.ID}}
+	query, ok := scope.Apply(db.Model(&models.Notification{}), "notifications", false)
+	if !ok {
+		t.Fatal("教师应能读取通知")
+	}
+	var got []string
+	if err := query.Where("title = ?", "test_scope_"+suffix).Pluck("target", &got).Error; err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("教师可见的通知 = %v, want %v", got, want)
+	}
+	if _, ok := scope.Apply(db.Model(&models.Notification{}), "notifications", true); ok {
+		t.Fatal("教师不应能通过通用表接口修改通知")
+	}
+}
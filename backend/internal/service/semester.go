@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 选课时间窗口相关错误
+var (
+	ErrEnrollNotOpen  = errors.New("当前不在选课时间内")
+	ErrEnrollClosed   = errors.New("选课已结束，加退选阶段只能退课")
+	ErrAddDropClosed  = errors.New("已超过加退选截止时间")
+	ErrCourseNotFound = errors.New("课程不存在")
+)
+
+// courseSemester 查询课程的开课学期，未绑定学期时返回 nil
+func courseSemester(db *gorm.DB, courseID uint) (*models.Semester, error) {
+	var course models.Course
+	if err := db.Select("id", "semester_id").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	if course.SemesterID == 0 {
+		return nil, nil
+	}
+
+	var semester models.Semester
+	if err := db.First(&semester, course.SemesterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &semester, nil
+}
+
+// CheckEnrollWindow 检查当前是否可以选课（含加入候补）：选课开始至选课结束
+// 选课结束后至加退选截止只能退课，候补学生仍按顺序递补（见 CheckDropWindow）；未绑定学期的课程不受限制
+func CheckEnrollWindow(db *gorm.DB, courseID uint) error {
+	semester, err := courseSemester(db, courseID)
+	if err != nil || semester == nil {
+		return err
+	}
+	now := time.Now()
+	if now.Before(semester.EnrollStart) {
+		return ErrEnrollNotOpen
+	}
+	if now.After(semester.EnrollEnd) {
+		return ErrEnrollClosed
+	}
+	return nil
+}
+
+// CheckDropWindow 检查当前是否处于课程所属学期的加退选时间内（选课开始至加退选截止）
+func CheckDropWindow(db *gorm.DB, courseID uint) error {
+	semester, err := courseSemester(db, courseID)
+	if err != nil || semester == nil {
+		return err
+	}
+	now := time.Now()
+	if now.Before(semester.EnrollStart) {
+		return ErrEnrollNotOpen
+	}
+	if now.After(semester.AddDropDeadline) {
+		return ErrAddDropClosed
+	}
+	return nil
+}
+
+// SyncEnrollmentSemesters 课程调整学期后，同步其已有选课记录的学期（未指定课程时同步全部）
+func SyncEnrollmentSemesters(db *gorm.DB, courseIDs ...uint) error {
+	query := `UPDATE enrollments e JOIN courses c ON c.id = e.course_id
+        SET e.semester_id = c.semester_id WHERE e.semester_id <> c.semester_id`
+	if len(courseIDs) == 0 {
+		return db.Exec(query).Error
+	}
+	return db.Exec(query+" AND c.id IN ?", courseIDs).Error
+}
+
+// CurrentSemester 获取当前学期，未设置时返回 gorm.ErrRecordNotFound
+func CurrentSemester(db *gorm.DB) (*models.Semester, error) {
+	var semester models.Semester
+	if err := db.Where("is_current = ?", true).Order("start_date DESC").First(&semester).Error; err != nil {
+		return nil, err
+	}
+	return &semester, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"student-management-system/internal/models"
+)
+
+// 选课只在选课开始至选课结束之间；退课（及候补递补）到加退选截止
+func TestEnrollAndDropWindows(t *testing.T) {
+	db := openTestDB(t, &models.Semester{}, &models.Course{})
+	now := time.Now()
+	hour := time.Hour
+
+	cases := []struct {
+		name                 string
+		start, end, deadline time.Time
+		wantEnroll, wantDrop error
+	}{
+		{"选课开始前", now.Add(hour), now.Add(2 * hour), now.Add(3 * hour), ErrEnrollNotOpen, ErrEnrollNotOpen},
+		{"选课期间", now.Add(-hour), now.Add(hour), now.Add(2 * hour), nil, nil},
+		{"加退选阶段", now.Add(-2 * hour), now.Add(-hour), now.Add(hour), ErrEnrollClosed, nil},
+		{"加退选截止后", now.Add(-3 * hour), now.Add(-2 * hour), now.Add(-hour), ErrEnrollClosed, ErrAddDropClosed},
+	}
+	for i, tc := range cases {
+		semester := models.Semester{Name: fmt.Sprintf("test_window_%d_%d", now.UnixNano(), i),
+			EnrollStart: tc.start, EnrollEnd: tc.end, AddDropDeadline: tc.deadline}
+		if err := db.Create(&semester).Error; err != nil {
+			t.Fatal(err)
+		}
+		course := models.Course{CourseName: "test_window", SemesterID: semester.ID}
+		if err := db.Create(&course).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckEnrollWindow(db, course.ID); !errors.Is(err, tc.wantEnroll) {
+			t.Errorf("%s: CheckEnrollWindow = %v, want %v", tc.name, err, tc.wantEnroll)
+		}
+		if err := CheckDropWindow(db, course.ID); !errors.Is(err, tc.wantDrop) {
+			t.Errorf("%s: CheckDropWindow = %v, want %v", tc.name, err, tc.wantDrop)
+		}
+	}
+}
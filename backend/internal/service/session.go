@@ -0,0 +1,184 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌无效、已过期或会话已撤销
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期，请重新登录")
+	// ErrRefreshTokenReused 已轮换掉的刷新令牌被再次使用（可能被盗用），会话已撤销
+	ErrRefreshTokenReused = errors.New("刷新令牌已被使用，会话已撤销，请重新登录")
+	// ErrUserInactive 用户已被禁用
+	ErrUserInactive = errors.New("用户已被禁用")
+)
+
+// lastSeenInterval 最近使用时间的更新间隔，避免每个请求都写会话表
+const lastSeenInterval = time.Minute
+
+// ClientInfo 发起登录的客户端信息
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// CreateSession 为用户创建登录会话，返回会话及刷新令牌原文
+func CreateSession(db *gorm.DB, user *models.User, client ClientInfo, ttl time.Duration) (*models.Session, string, error) {
+	token, hash, err := utils.NewRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(client.UserAgent) > 255 {
+		client.UserAgent = client.UserAgent[:255]
+	}
+	now := time.Now()
+	session := models.Session{
+		UserID:       user.ID,
+		RefreshHash:  hash,
+		TokenVersion: user.TokenVersion,
+		UserAgent:    client.UserAgent,
+		IP:           client.IP,
+		LastSeenAt:   now,
+		ExpiresAt:    now.Add(ttl),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, "", err
+	}
+	return &session, token, nil
+}
+
+// RotateSession 用刷新令牌换取新的刷新令牌（旧令牌随即作废），并返回会话所属用户
+// 已作废的旧令牌再次出现时撤销整个会话，防止被盗用的令牌继续使用
+func RotateSession(db *gorm.DB, refreshToken string, ttl time.Duration) (*models.Session, *models.User, string, error) {
+	hash := utils.HashToken(refreshToken)
+	var session models.Session
+	var user models.User
+	var token string
+	var reusedID uint
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("refresh_hash = ?", hash).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var reused models.Session
+			if tx.Where("previous_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error == nil {
+				reusedID = reused.ID
+				return ErrRefreshTokenReused
+			}
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !user.IsActive {
+			return ErrUserInactive
+		}
+		if user.TokenVersion != session.TokenVersion {
+			return ErrInvalidRefreshToken
+		}
+
+		var newHash string
+		if token, newHash, err = utils.NewRefreshToken(); err != nil {
+			return err
+		}
+		session.PreviousHash = session.RefreshHash
+		session.RefreshHash = newHash
+		session.LastSeenAt = time.Now()
+		session.ExpiresAt = session.LastSeenAt.Add(ttl)
+		return tx.Save(&session).Error
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		// 在事务外撤销，避免随错误一起回滚
+		if revokeErr := RevokeSession(db, reusedID); revokeErr != nil {
+			return nil, nil, "", revokeErr
+		}
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return &session, &user, token, nil
+}
+
+// TouchSession 判断会话是否仍然有效（未撤销），有效时更新最近使用时间
+func TouchSession(db *gorm.DB, sessionID uint) bool {
+	var session models.Session
+	if err := db.Select("id", "revoked_at", "last_seen_at").First(&session, sessionID).Error; err != nil {
+		return false
+	}
+	if session.RevokedAt != nil {
+		return false
+	}
+	if time.Since(session.LastSeenAt) > lastSeenInterval {
+		db.Model(&session).UpdateColumn("last_seen_at", time.Now())
+	}
+	return true
+}
+
+// ListActiveSessions 用户未撤销且未过期的会话，按最近使用时间倒序
+func ListActiveSessions(db *gorm.DB, userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeUserSession 撤销用户本人的某个会话；会话不属于该用户或已撤销时返回 gorm.ErrRecordNotFound
+func RevokeUserSession(db *gorm.DB, userID, sessionID uint) error {
+	res := db.Model(&models.Session{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions 撤销用户除 keepID 之外的全部会话（keepID 为 0 时全部撤销），返回撤销数量
+func RevokeOtherSessions(db *gorm.DB, userID, keepID uint) (int64, error) {
+	res := db.Model(&models.Session{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+// RevokeSession 撤销会话（登出）
+func RevokeSession(db *gorm.DB, sessionID uint) error {
+	return revokeSessions(db, "id = ?", sessionID)
+}
+
+// RevokeSessionByRefreshToken 按刷新令牌撤销会话
+func RevokeSessionByRefreshToken(db *gorm.DB, refreshToken string) error {
+	return revokeSessions(db, "refresh_hash = ?", utils.HashToken(refreshToken))
+}
+
+// RevokeUserTokens 使用户已签发的全部令牌失效：令牌版本递增并撤销全部会话
+// 用于修改密码、禁用账号、变更角色等场景
+func RevokeUserTokens(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", userID)
+	})
+}
+
+// revokeSessions 撤销符合条件且尚未撤销的会话
+func revokeSessions(db *gorm.DB, query string, args ...interface{}) error {
+	return db.Model(&models.Session{}).Where(query, args...).Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
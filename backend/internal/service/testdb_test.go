@@ -0,0 +1,76 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"student-management-system/config"
+	"student-management-system/internal/models"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 连接 TEST_DATABASE_DSN 指定的 MySQL 测试库（如 root:pass@tcp(127.0.0.1:3306)/sms_test?parseTime=true），
+// 迁移账号、权限与选课相关的表并创建主程序使用的触发器和存储过程；未设置时跳过测试。返回的是事务，测试结束时回滚，不在测试库中留下数据
+func openTestDB(t testing.TB, extra ...interface{}) *gorm.DB {
+	t.Helper()
+	tx := connectTestDB(t, extra...).Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// connectTestDB 同 openTestDB，但返回数据库连接本身（用于并发测试，数据须由测试自行清理）
+func connectTestDB(t testing.TB, extra ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("未设置 TEST_DATABASE_DSN，跳过需要数据库的测试")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatalf("连接测试数据库失败: %v", err)
+	}
+	tables := append([]interface{}{
+		&models.Permission{}, &models.Role{}, &models.RolePermission{}, &models.RolePermissionRule{},
+		&models.User{}, &models.Session{}, &models.UserIdentity{},
+		&models.Course{}, &models.Enrollment{}, &models.Parent{}, &models.StudentGuardian{},
+	}, extra...)
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	if err := config.InitDatabaseObjects(db); err != nil {
+		t.Fatalf("创建测试数据库的触发器与存储过程失败: %v", err)
+	}
+	return db
+}
+
+// createTestRole 创建测试角色；admin 为 true 时授予全部管理员必备权限
+func createTestRole(t testing.TB, db *gorm.DB, name string, admin bool) models.Role {
+	t.Helper()
+	role := models.Role{RoleName: name}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatalf("创建角色 %s 失败: %v", name, err)
+	}
+	if admin {
+		rule := models.RolePermissionRule{RoleID: role.ID, Pattern: "admin:*", Effect: EffectAllow}
+		if err := db.Create(&rule).Error; err != nil {
+			t.Fatalf("为角色 %s 授权失败: %v", name, err)
+		}
+	}
+	return role
+}
+
+// createTestUser 创建启用的测试账号
+func createTestUser(t testing.TB, db *gorm.DB, username string, role models.Role, userType string) models.User {
+	t.Helper()
+	user := models.User{Username: username, Password: "-", RoleID: role.ID, IsActive: true, UserType: userType}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建账号 %s 失败: %v", username, err)
+	}
+	return user
+}
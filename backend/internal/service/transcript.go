@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"student-management-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 成绩类型别名（兼容中文与导入数据中的英文写法）
+var (
+	totalScoreTypes   = []string{"总评", "Total"}
+	regularScoreTypes = []string{"平时成绩", "平时", "Regular"}
+	finalScoreTypes   = []string{"期末成绩", "期末", "Final"}
+)
+
+// 缺少总评时，由平时成绩与期末成绩加权计算
+const (
+	regularWeight = 0.3
+	finalWeight   = 0.7
+	passingScore  = 60
+)
+
+// DefaultGradeScale 默认绩点方案名称（启动时初始化，见 app.InitDB）
+const DefaultGradeScale = "standard"
+
+// ErrScaleNotFound 绩点方案不存在
+var ErrScaleNotFound = errors.New("绩点方案不存在")
+
+// GradeScale 绩点对照规则，按分数下限从高到低排列
+type GradeScale struct {
+	Name  string
+	Rules []models.GradePointScale
+}
+
+// LoadGradeScale 加载指定名称的绩点方案
+func LoadGradeScale(db *gorm.DB, name string) (*GradeScale, error) {
+	var rules []models.GradePointScale
+	if err := db.Where("scale = ?", name).Order("min_score DESC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, ErrScaleNotFound
+	}
+	return &GradeScale{Name: name, Rules: rules}, nil
+}
+
+// Lookup 将分数映射为绩点与等级，低于所有下限时返回 0 绩点
+func (s *GradeScale) Lookup(score float64) (float64, string) {
+	for _, rule := range s.Rules {
+		if score >= rule.MinScore {
+			return rule.GradePoint, rule.Letter
+		}
+	}
+	return 0, ""
+}
+
+// FinalScore 计算一门课程的总评成绩
+// 优先使用“总评”；否则按平时 30%、期末 70% 加权；只有一项时直接使用该项
+func FinalScore(grades []models.Grade) (float64, bool) {
+	if score, ok := findScore(grades, totalScoreTypes); ok {
+		return score, true
+	}
+	regular, hasRegular := findScore(grades, regularScoreTypes)
+	final, hasFinal := findScore(grades, finalScoreTypes)
+	switch {
+	case hasRegular && hasFinal:
+		return round2(regular*regularWeight + final*finalWeight), true
+	case hasFinal:
+		return final, true
+	case hasRegular:
+		return regular, true
+	}
+	return 0, false
+}
+
+// findScore 取指定类型中最新录入的一条成绩
+func findScore(grades []models.Grade, types []string) (float64, bool) {
+	var latest *models.Grade
+	for i := range grades {
+		for _, t := range types {
+			if grades[i].ScoreType == t && (latest == nil || grades[i].ID > latest.ID) {
+				latest = &grades[i]
+			}
+		}
+	}
+	if latest == nil {
+		return 0, false
+	}
+	return latest.Score, true
+}
+
+// TranscriptCourse 成绩单中的单门课程
+type TranscriptCourse struct {
+	EnrollmentID uint               `json:"enrollment_id"`
+	CourseID     uint               `json:"course_id"`
+	CourseName   string             `json:"course_name"`
+	Credits      float64            `json:"credits"`
+	Components   map[string]float64 `json:"components"`  // 各项成绩（按成绩类型）
+	FinalScore   *float64           `json:"final_score"` // 总评，尚无成绩时为 null
+	GradePoint   *float64           `json:"grade_point"`
+	Letter       string             `json:"letter"`
+	Passed       bool               `json:"passed"`
+}
+
+// TranscriptTerm 成绩单中的一个学期
+type TranscriptTerm struct {
+	SemesterID    uint               `json:"semester_id"`
+	SemesterName  string             `json:"semester_name"`
+	Courses       []TranscriptCourse `json:"courses"`
+	Credits       float64            `json:"credits"`        // 已出成绩课程的学分
+	EarnedCredits float64            `json:"earned_credits"` // 及格课程的学分
+	GPA           float64            `json:"gpa"`            // 学期加权绩点
+
+	startDate time.Time
+}
+
+// Transcript 学生成绩单
+type Transcript struct {
+	Student       models.Student   `json:"student"`
+	Scale         string           `json:"scale"`
+	Terms         []TranscriptTerm `json:"terms"`
+	TotalCredits  float64          `json:"total_credits"`
+	EarnedCredits float64          `json:"earned_credits"`
+	CumulativeGPA float64          `json:"cumulative_gpa"` // 累计加权绩点
+	GeneratedAt   time.Time        `json:"generated_at"`
+}
+
+// BuildTranscript 汇总学生全部有效选课及成绩，生成按学期分组的成绩单
+func BuildTranscript(db *gorm.DB, studentID uint, scaleName string) (*Transcript, error) {
+	scale, err := LoadGradeScale(db, scaleName)
+	if err != nil {
+		return nil, err
+	}
+
+	var student models.Student
+	if err := db.Preload("Class").First(&student, studentID).Error; err != nil {
+		return nil, err
+	}
+
+	var enrollments []models.Enrollment
+	if err := db.Preload("Course").Preload("Grades").
+		Where("student_id = ?", studentID).Order("id ASC").Find(&enrollments).Error; err != nil {
+		return nil, err
+	}
+
+	semesterIDs := make([]uint, 0)
+	for _, e := range enrollments {
+		semesterIDs = append(semesterIDs, e.SemesterID)
+	}
+	var semesters []models.Semester
+	if len(semesterIDs) > 0 {
+		db.Where("id IN ?", semesterIDs).Find(&semesters)
+	}
+	semesterByID := make(map[uint]models.Semester, len(semesters))
+	for _, s := range semesters {
+		semesterByID[s.ID] = s
+	}
+
+	terms := make(map[uint]*TranscriptTerm)
+	var order []uint
+	for _, e := range enrollments {
+		term, ok := terms[e.SemesterID]
+		if !ok {
+			term = &TranscriptTerm{SemesterID: e.SemesterID, SemesterName: "未分学期"}
+			if s, found := semesterByID[e.SemesterID]; found {
+				term.SemesterName = s.Name
+				term.startDate = s.StartDate
+			}
+			terms[e.SemesterID] = term
+			order = append(order, e.SemesterID)
+		}
+
+		course := TranscriptCourse{
+			EnrollmentID: e.ID,
+			CourseID:     e.CourseID,
+			CourseName:   e.Course.CourseName,
+			Credits:      e.Course.Credits,
+			Components:   make(map[string]float64),
+		}
+		for _, g := range e.Grades {
+			course.Components[g.ScoreType] = g.Score
+		}
+		if score, ok := FinalScore(e.Grades); ok {
+			gp, letter := scale.Lookup(score)
+			course.FinalScore = &score
+			course.GradePoint = &gp
+			course.Letter = letter
+			course.Passed = score >= passingScore
+		}
+		term.Courses = append(term.Courses, course)
+	}
+
+	transcript := &Transcript{
+		Student:     student,
+		Scale:       scale.Name,
+		GeneratedAt: time.Now(),
+	}
+	var totalPoints float64
+	for _, id := range order {
+		term := terms[id]
+		var termPoints float64
+		for _, course := range term.Courses {
+			if course.GradePoint == nil {
+				continue
+			}
+			term.Credits += course.Credits
+			termPoints += *course.GradePoint * course.Credits
+			if course.Passed {
+				term.EarnedCredits += course.Credits
+			}
+		}
+		term.GPA = weightedGPA(termPoints, term.Credits)
+
+		transcript.TotalCredits += term.Credits
+		transcript.EarnedCredits += term.EarnedCredits
+		totalPoints += termPoints
+		transcript.Terms = append(transcript.Terms, *term)
+	}
+	transcript.CumulativeGPA = weightedGPA(totalPoints, transcript.TotalCredits)
+
+	// 按学期开始时间排序，未分学期的记录排在最前
+	sort.SliceStable(transcript.Terms, func(i, j int) bool {
+		return transcript.Terms[i].startDate.Before(transcript.Terms[j].startDate)
+	})
+	return transcript, nil
+}
+
+func weightedGPA(points, credits float64) float64 {
+	if credits == 0 {
+		return 0
+	}
+	return round2(points / credits)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
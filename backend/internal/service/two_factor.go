@@ -0,0 +1,298 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"student-management-system/internal/models"
+	"student-management-system/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证（TOTP，RFC 6238）
+
+const (
+	// BackupCodeCount 每次生成的备用码数量
+	BackupCodeCount = 10
+	// EnrollmentCodeTTL 管理员签发的两步验证设置码的有效期
+	EnrollmentCodeTTL = 24 * time.Hour
+	// totpSkew 允许的时钟误差（前后各一个 30 秒时间步）
+	totpSkew = 1
+
+	backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	backupCodeLength   = 10
+)
+
+var (
+	// ErrTOTPAlreadyEnabled 已启用两步验证
+	ErrTOTPAlreadyEnabled = errors.New("已启用两步验证")
+	// ErrTOTPNotSetUp 尚未生成两步验证密钥或尚未启用
+	ErrTOTPNotSetUp = errors.New("尚未设置两步验证")
+	// ErrInvalidSecondFactor 验证码或备用码错误（或验证码已使用过）
+	ErrInvalidSecondFactor = errors.New("验证码错误或已使用")
+	// ErrTwoFactorRequired 所在角色要求启用两步验证
+	ErrTwoFactorRequired = errors.New("所在角色要求启用两步验证")
+	// ErrInvalidEnrollmentCode 设置码错误、已使用或已过期
+	ErrInvalidEnrollmentCode = errors.New("设置码错误或已过期，请联系管理员重新签发")
+)
+
+// TwoFactorRequired 角色是否拥有任一须两步验证的权限（patterns 如 admin:*；包括继承和通配符授权得到的权限）
+func TwoFactorRequired(db *gorm.DB, roleID uint, patterns []string) (bool, error) {
+	if len(patterns) == 0 {
+		return false, nil
+	}
+	set, err := LoadPermissionSet(db, roleID)
+	if err != nil {
+		return false, err
+	}
+	catalog, err := PermissionCatalog(db)
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range set.Effective(catalog) {
+		for _, pattern := range patterns {
+			if matchPermissionPattern(pattern, permission) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// IssueEnrollmentCode 为尚未启用两步验证的用户签发一次性设置码（替换之前签发的），返回设置码及过期时间。
+// 所在角色要求两步验证的用户只凭密码不能自行设置，须同时提交管理员经其他渠道交给本人的设置码
+func IssueEnrollmentCode(db *gorm.DB, userID uint) (string, time.Time, error) {
+	var user models.User
+	if err := db.Select("id", "totp_enabled").First(&user, userID).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	if user.TOTPEnabled {
+		return "", time.Time{}, ErrTOTPAlreadyEnabled
+	}
+	code, err := newBackupCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(EnrollmentCodeTTL)
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"totp_enroll_code":       hashBackupCode(code),
+		"totp_enroll_expires_at": expires,
+	}).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expires, nil
+}
+
+// CheckEnrollmentCode 校验设置码（不作废，启用时由 ConsumeEnrollmentCode 作废）
+func CheckEnrollmentCode(user *models.User, code string) error {
+	if user.TOTPEnrollCode == "" || user.TOTPEnrollExpiresAt == nil || !time.Now().Before(*user.TOTPEnrollExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(user.TOTPEnrollCode), []byte(hashBackupCode(code))) != 1 {
+		return ErrInvalidEnrollmentCode
+	}
+	return nil
+}
+
+// ConsumeEnrollmentCode 作废设置码；设置码错误、已被使用或已过期时返回 ErrInvalidEnrollmentCode
+func ConsumeEnrollmentCode(db *gorm.DB, userID uint, code string) error {
+	res := db.Model(&models.User{}).
+		Where("id = ? AND totp_enroll_code = ? AND totp_enroll_expires_at > ?", userID, hashBackupCode(code), time.Now()).
+		Updates(map[string]interface{}{"totp_enroll_code": "", "totp_enroll_expires_at": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ErrInvalidEnrollmentCode
+	}
+	return nil
+}
+
+// BeginTOTPSetup 为用户生成新的 TOTP 密钥（启用前须用验证码确认）
+func BeginTOTPSetup(db *gorm.DB, user *models.User) (string, error) {
+	if user.TOTPEnabled {
+		return "", ErrTOTPAlreadyEnabled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := utils.SealSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	if err := db.Model(user).Update("totp_secret", sealed).Error; err != nil {
+		return "", err
+	}
+	user.TOTPSecret = sealed
+	return secret, nil
+}
+
+// EnableTOTP 用验证器 App 上的验证码确认密钥并启用两步验证，返回新生成的备用码（只显示这一次）
+func EnableTOTP(db *gorm.DB, user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotSetUp
+	}
+	secret, err := utils.OpenSecret(user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.VerifyTOTP(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidSecondFactor
+	}
+
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":           true,
+			"totp_last_step":         step,
+			"totp_enroll_code":       "",
+			"totp_enroll_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceBackupCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	return codes, nil
+}
+
+// VerifySecondFactor 校验验证码或备用码；返回是否使用了备用码
+// 验证码只能使用一次（时间步须大于上次使用的时间步），备用码使用后作废
+func VerifySecondFactor(db *gorm.DB, user *models.User, code string) (bool, error) {
+	if !user.TOTPEnabled || user.TOTPSecret == "" {
+		return false, ErrTOTPNotSetUp
+	}
+	secret, err := utils.OpenSecret(user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := utils.VerifyTOTP(secret, code, time.Now(), totpSkew); ok {
+		res := db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if res.Error != nil {
+			return false, res.Error
+		}
+		if res.RowsAffected == 1 {
+			user.TOTPLastStep = step
+			return false, nil
+		}
+		return false, ErrInvalidSecondFactor
+	}
+
+	res := db.Model(&models.BackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashBackupCode(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+	return false, ErrInvalidSecondFactor
+}
+
+// SealTOTPSecrets 加密存储之前以明文保存的 TOTP 密钥（启动时调用），返回加密的数量
+func SealTOTPSecrets(db *gorm.DB) (int, error) {
+	var users []models.User
+	if err := db.Select("id", "totp_secret").
+		Where("totp_secret <> '' AND totp_secret NOT LIKE ?", "enc:%").Find(&users).Error; err != nil {
+		return 0, err
+	}
+	for _, user := range users {
+		sealed, err := utils.SealSecret(user.TOTPSecret)
+		if err != nil {
+			return 0, err
+		}
+		if err := db.Model(&models.User{}).Where("id = ? AND totp_secret = ?", user.ID, user.TOTPSecret).
+			Update("totp_secret", sealed).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(users), nil
+}
+
+// RegenerateBackupCodes 作废旧的备用码并生成一组新的
+func RegenerateBackupCodes(db *gorm.DB, userID uint) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceBackupCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RemainingBackupCodes 未使用的备用码数量
+func RemainingBackupCodes(db *gorm.DB, userID uint) int64 {
+	var count int64
+	db.Model(&models.BackupCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// DisableTOTP 关闭两步验证，清除密钥与备用码
+func DisableTOTP(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.BackupCode{}).Error
+	})
+}
+
+func replaceBackupCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.BackupCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, BackupCodeCount)
+	rows := make([]models.BackupCode, 0, BackupCodeCount)
+	for i := 0; i < BackupCodeCount; i++ {
+		code, err := newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.BackupCode{UserID: userID, CodeHash: hashBackupCode(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newBackupCode 生成形如 abcde-fgh23 的备用码
+func newBackupCode() (string, error) {
+	buf := make([]byte, backupCodeLength)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(backupCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		buf[i] = backupCodeAlphabet[n.Int64()]
+	}
+	return string(buf[:backupCodeLength/2]) + "-" + string(buf[backupCodeLength/2:]), nil
+}
+
+// hashBackupCode 备用码哈希（忽略大小写、空格和连字符）
+func hashBackupCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utils.HashToken(normalized)
+}
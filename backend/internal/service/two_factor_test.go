@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"student-management-system/internal/models"
+)
+
+// 设置码只能由管理员签发、只能使用一次、过期作废；已启用两步验证的账号不签发
+func TestEnrollmentCode(t *testing.T) {
+	db := openTestDB(t, &models.BackupCode{})
+	role := createTestRole(t, db, "test_2fa_admin", true)
+	user := createTestUser(t, db, "test_2fa_root", role, "admin")
+
+	reload := func() *models.User {
+		var u models.User
+		if err := db.First(&u, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return &u
+	}
+
+	if err := CheckEnrollmentCode(reload(), ""); !errors.Is(err, ErrInvalidEnrollmentCode) {
+		t.Fatalf("未签发时 CheckEnrollmentCode err = %v, want ErrInvalidEnrollmentCode", err)
+	}
+
+	code, _, err := IssueEnrollmentCode(db, user.ID)
+	if err != nil {
+		t.Fatalf("IssueEnrollmentCode: %v", err)
+	}
+	if err := CheckEnrollmentCode(reload(), "wrong-code"); !errors.Is(err, ErrInvalidEnrollmentCode) {
+		t.Fatalf("错误的设置码 err = %v, want ErrInvalidEnrollmentCode", err)
+	}
+	if err := CheckEnrollmentCode(reload(), code); err != nil {
+		t.Fatalf("CheckEnrollmentCode: %v", err)
+	}
+	if err := ConsumeEnrollmentCode(db, user.ID, code); err != nil {
+		t.Fatalf("ConsumeEnrollmentCode: %v", err)
+	}
+	if err := ConsumeEnrollmentCode(db, user.ID, code); !errors.Is(err, ErrInvalidEnrollmentCode) {
+		t.Fatalf("重复使用 err = %v, want ErrInvalidEnrollmentCode", err)
+	}
+
+	// 过期的设置码不能使用
+	code, _, err = IssueEnrollmentCode(db, user.ID)
+	if err != nil {
+		t.Fatalf("IssueEnrollmentCode: %v", err)
+	}
+	db.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_enroll_expires_at", time.Now().Add(-time.Minute))
+	if err := CheckEnrollmentCode(reload(), code); !errors.Is(err, ErrInvalidEnrollmentCode) {
+		t.Fatalf("过期后 CheckEnrollmentCode err = %v, want ErrInvalidEnrollmentCode", err)
+	}
+	if err := ConsumeEnrollmentCode(db, user.ID, code); !errors.Is(err, ErrInvalidEnrollmentCode) {
+		t.Fatalf("过期后 ConsumeEnrollmentCode err = %v, want ErrInvalidEnrollmentCode", err)
+	}
+
+	db.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_enabled", true)
+	if _, _, err := IssueEnrollmentCode(db, user.ID); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Fatalf("已启用时 IssueEnrollmentCode err = %v, want ErrTOTPAlreadyEnabled", err)
+	}
+}
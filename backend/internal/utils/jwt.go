@@ -1,46 +1,88 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your_jwt_secret_key_change_in_production")
+// tokenIssuer 令牌签发方（iss），解析时校验
+const tokenIssuer = "student-management-system"
+
+// keySet 当前使用的签名密钥集合，由 SetKeySet 设置
+var keySet *KeySet
+
+// SetKeySet 设置签名密钥集合（启动时调用；轮换密钥时先加入新密钥，旧密钥保留到其签发的令牌全部过期）
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
+
+// CurrentKeySet 当前签名密钥集合（未设置时为 nil）
+func CurrentKeySet() *KeySet {
+	return keySet
+}
+
+// 临时令牌的用途（Claims.Purpose）
+const (
+	PurposeTwoFactor       = "2fa"        // 已通过密码验证，只能用于提交两步验证码
+	PurposeTwoFactorEnroll = "2fa_enroll" // 所在角色要求两步验证但尚未启用，凭管理员签发的设置码才能用于设置两步验证
+)
 
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	RoleID   uint   `json:"role_id"`
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	RoleID       uint   `json:"role_id"`
+	SessionID    uint   `json:"sid"`               // 所属登录会话，会话被撤销后令牌立即失效
+	TokenVersion uint   `json:"ver"`               // 签发时用户的令牌版本，改密、禁用、换角色后版本递增，旧令牌失效
+	Purpose      string `json:"purpose,omitempty"` // 为空表示正常访问令牌；否则为只能用于两步验证流程的临时令牌
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(userID uint, username string, roleID uint) (string, error) {
+// GenerateToken 生成JWT访问令牌（短期有效，过期后用刷新令牌换取新的访问令牌）
+// 使用当前签名密钥（RS256 或 EdDSA）签名，JWT 头中带 kid
+func GenerateToken(claims Claims, ttl time.Duration) (string, error) {
+	if keySet == nil {
+		return "", errors.New("签名密钥尚未设置")
+	}
 	nowTime := time.Now()
-	expireTime := nowTime.Add(24 * time.Hour)
-
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RoleID:   roleID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expireTime),
-			IssuedAt:  jwt.NewNumericDate(nowTime),
-			Issuer:    "student-management-system",
-		},
+	expireTime := nowTime.Add(ttl)
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expireTime),
+		IssuedAt:  jwt.NewNumericDate(nowTime),
+		Issuer:    tokenIssuer,
 	}
 
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := tokenClaims.SignedString(jwtSecret)
-	return token, err
+	return keySet.Active.SignWith(claims)
 }
 
 // ParseToken 解析JWT token
+// 严格校验：必须带 kid 且对应已知密钥，alg 必须与该密钥的算法一致（拒绝 none、HS256 等），并校验签发方与过期时间
 func ParseToken(token string) (*Claims, error) {
+	if keySet == nil {
+		return nil, errors.New("签名密钥尚未设置")
+	}
 	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keySet.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("未知的密钥 kid %q", kid)
+		}
+		if token.Method.Alg() != key.Alg {
+			return nil, fmt.Errorf("签名算法 %s 与密钥 %s 不符", token.Method.Alg(), kid)
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
 
 	if tokenClaims != nil {
 		if claims, ok := tokenClaims.Claims.(*Claims); ok && tokenClaims.Valid {
@@ -51,7 +93,18 @@ func ParseToken(token string) (*Claims, error) {
 	return nil, err
 }
 
-// SetJWTSecret 设置JWT密钥
-func SetJWTSecret(secret string) {
-	jwtSecret = []byte(secret)
+// NewRefreshToken 生成随机刷新令牌，返回令牌原文及其哈希（数据库中只保存哈希）
+func NewRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken 计算刷新令牌的 SHA-256 哈希（十六进制）
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的签名算法（JWT 头中的 alg）
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSABits RSA 密钥的最小长度
+const minRSABits = 2048
+
+// SigningKey 以 kid 标识的签名密钥；只有公钥的密钥仅用于验证（已轮换下线、等待旧令牌过期的密钥）
+type SigningKey struct {
+	KID     string
+	Alg     string
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// CanSign 是否持有私钥
+func (k *SigningKey) CanSign() bool {
+	return k.private != nil
+}
+
+// NewSigningKey 由私钥创建签名密钥（RSA 对应 RS256，Ed25519 对应 EdDSA）
+func NewSigningKey(kid string, private crypto.PrivateKey) (*SigningKey, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("密钥 %s：不支持的私钥类型 %T", kid, private)
+	}
+	key, err := NewVerificationKey(kid, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.private = signer
+	return key, nil
+}
+
+// NewVerificationKey 由公钥创建仅用于验证的密钥
+func NewVerificationKey(kid string, public crypto.PublicKey) (*SigningKey, error) {
+	if kid == "" {
+		return nil, errors.New("密钥 kid 不能为空")
+	}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("密钥 %s：RSA 密钥长度至少 %d 位", kid, minRSABits)
+		}
+		return &SigningKey{KID: kid, Alg: AlgRS256, public: pub}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{KID: kid, Alg: AlgEdDSA, public: pub}, nil
+	default:
+		return nil, fmt.Errorf("密钥 %s：不支持的公钥类型 %T（仅支持 RSA 与 Ed25519）", kid, public)
+	}
+}
+
+// GenerateSigningKey 生成新的签名密钥（alg 为 RS256 或 EdDSA）
+func GenerateSigningKey(kid, alg string) (*SigningKey, error) {
+	switch alg {
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, private)
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, private)
+	default:
+		return nil, fmt.Errorf("不支持的签名算法 %q（可选 %s、%s）", alg, AlgRS256, AlgEdDSA)
+	}
+}
+
+// PrivateKeyPEM 私钥的 PKCS#8 PEM 编码
+func (k *SigningKey) PrivateKeyPEM() ([]byte, error) {
+	if k.private == nil {
+		return nil, fmt.Errorf("密钥 %s 没有私钥", k.KID)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// PublicKeyPEM 公钥的 PKIX PEM 编码
+func (k *SigningKey) PublicKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.public)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// KeySet 签名密钥集合：用 Active 签发新令牌，集合中的全部密钥都可用于验证
+type KeySet struct {
+	Active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeySet 创建密钥集合，active 必须持有私钥；others 为其余验证密钥
+func NewKeySet(active *SigningKey, others ...*SigningKey) (*KeySet, error) {
+	if active == nil || !active.CanSign() {
+		return nil, errors.New("当前签名密钥必须包含私钥")
+	}
+	ks := &KeySet{Active: active, keys: map[string]*SigningKey{active.KID: active}}
+	for _, k := range others {
+		if _, dup := ks.keys[k.KID]; dup {
+			return nil, fmt.Errorf("重复的密钥 kid %q", k.KID)
+		}
+		ks.keys[k.KID] = k
+	}
+	return ks, nil
+}
+
+// Key 按 kid 查找验证密钥
+func (ks *KeySet) Key(kid string) (*SigningKey, bool) {
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// Keys 全部密钥（按 kid 排序）
+func (ks *KeySet) Keys() []*SigningKey {
+	list := make([]*SigningKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].KID < list[j].KID })
+	return list
+}
+
+// LoadKeySet 从目录加载密钥：<kid>.pem 为 PKCS#8 私钥，<kid>.pub.pem 为仅用于验证的公钥
+// activeKID 为空时以 kid 最大（按字典序，建议用日期命名，如 2026-10）的私钥签名
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥目录失败: %w", err)
+	}
+
+	var private, public []*SigningKey
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+		block, err := readPEM(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if kid := strings.TrimSuffix(name, ".pub.pem"); kid != name {
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("解析公钥 %s 失败: %w", name, err)
+			}
+			key, err := NewVerificationKey(kid, pub)
+			if err != nil {
+				return nil, err
+			}
+			public = append(public, key)
+			continue
+		}
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析私钥 %s 失败（须为 PKCS#8 格式）: %w", name, err)
+		}
+		key, err := NewSigningKey(strings.TrimSuffix(name, ".pem"), priv)
+		if err != nil {
+			return nil, err
+		}
+		private = append(private, key)
+	}
+	if len(private) == 0 {
+		return nil, fmt.Errorf("密钥目录 %s 中没有私钥（<kid>.pem）", dir)
+	}
+
+	sort.Slice(private, func(i, j int) bool { return private[i].KID < private[j].KID })
+	active := private[len(private)-1]
+	if activeKID != "" {
+		active = nil
+		for _, k := range private {
+			if k.KID == activeKID {
+				active = k
+			}
+		}
+		if active == nil {
+			return nil, fmt.Errorf("密钥目录 %s 中没有 kid 为 %q 的私钥", dir, activeKID)
+		}
+	}
+
+	var others []*SigningKey
+	for _, k := range append(private, public...) {
+		if k != active {
+			others = append(others, k)
+		}
+	}
+	return NewKeySet(active, others...)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是 PEM 文件", path)
+	}
+	return block, nil
+}
+
+// JWK JSON Web Key（RFC 7517）中的公钥部分
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 指数
+	Crv string `json:"crv,omitempty"` // OKP 曲线
+	X   string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JWKS 密钥集合的公钥（供其他服务验证本系统签发的令牌）
+func (ks *KeySet) JWKS() []JWK {
+	list := make([]JWK, 0, len(ks.keys))
+	for _, k := range ks.Keys() {
+		jwk := JWK{Kid: k.KID, Use: "sig", Alg: k.Alg}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		list = append(list, jwk)
+	}
+	return list
+}
+
+// PublicKey 将 JWK 转换为公钥（用于验证外部身份提供方签发的令牌；支持 RSA 与 Ed25519）
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("JWK %s：无效的 n", j.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("JWK %s：无效的 e", j.Kid)
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("JWK %s：RSA 密钥长度至少 %d 位", j.Kid, minRSABits)
+		}
+		return pub, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("JWK %s：无效的 Ed25519 公钥", j.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("JWK %s：不支持的密钥类型 %q", j.Kid, j.Kty)
+	}
+}
+
+// SignWith 用该密钥签名任意声明（JWT 头带 kid），供测试用的身份提供方等工具使用
+func (k *SigningKey) SignWith(claims jwt.Claims) (string, error) {
+	if k.private == nil {
+		return "", fmt.Errorf("密钥 %s 没有私钥", k.KID)
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Alg), claims)
+	token.Header["kid"] = k.KID
+	return token.SignedString(k.private)
+}
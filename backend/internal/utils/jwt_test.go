@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeySet 当前密钥为 RS256，另有一个轮换下线的 EdDSA 验证密钥；返回全部密钥和一个不在集合中的密钥
+func testKeySet(t *testing.T) (rsaKey, oldKey, unknown *SigningKey) {
+	t.Helper()
+	var err error
+	if rsaKey, err = GenerateSigningKey("rsa-1", AlgRS256); err != nil {
+		t.Fatal(err)
+	}
+	if oldKey, err = GenerateSigningKey("ed-0", AlgEdDSA); err != nil {
+		t.Fatal(err)
+	}
+	if unknown, err = GenerateSigningKey("ed-x", AlgEdDSA); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewKeySet(rsaKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeySet(ks)
+	t.Cleanup(func() { SetKeySet(nil) })
+	return rsaKey, oldKey, unknown
+}
+
+func testClaims(issuer string) Claims {
+	now := time.Now()
+	return Claims{
+		UserID:   1,
+		Username: "alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+// signHS256 用 secret 以 HS256 签名，kid 为空时不带 kid
+func signHS256(t *testing.T, kid string, secret []byte) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(tokenIssuer))
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestParseTokenRejects(t *testing.T) {
+	rsaKey, oldKey, unknown := testKeySet(t)
+	sign := func(key *SigningKey, claims Claims) string {
+		t.Helper()
+		s, err := key.SignWith(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	rsaPEM, err := rsaKey.PublicKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPEM, err := oldKey.PublicKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims(tokenIssuer))
+	none.Header["kid"] = rsaKey.KID
+	noneToken, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noKID := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(tokenIssuer))
+	noKIDToken, err := noKID.SignedString(rsaKey.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := testClaims(tokenIssuer)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := testClaims(tokenIssuer)
+	noExpiry.ExpiresAt = nil
+
+	cases := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"当前密钥签发", sign(rsaKey, testClaims(tokenIssuer)), true},
+		{"轮换下线的密钥签发", sign(oldKey, testClaims(tokenIssuer)), true},
+		{"alg none", noneToken, false},
+		{"HS256 以 RSA 公钥为密钥", signHS256(t, rsaKey.KID, rsaPEM), false},
+		{"HS256 以 Ed25519 公钥为密钥", signHS256(t, oldKey.KID, edPEM), false},
+		{"HS256 不带 kid", signHS256(t, "", rsaPEM), false},
+		{"未知 kid", sign(unknown, testClaims(tokenIssuer)), false},
+		{"缺少 kid", noKIDToken, false},
+		{"kid 与签名密钥不符", func() string {
+			forged := *unknown
+			forged.KID = oldKey.KID
+			return sign(&forged, testClaims(tokenIssuer))
+		}(), false},
+		{"签发方错误", sign(rsaKey, testClaims("another-system")), false},
+		{"缺少签发方", sign(rsaKey, testClaims("")), false},
+		{"已过期", sign(rsaKey, expired), false},
+		{"缺少过期时间", sign(rsaKey, noExpiry), false},
+	}
+	for _, tc := range cases {
+		claims, err := ParseToken(tc.token)
+		if tc.ok && (err != nil || claims.Username != "alice") {
+			t.Errorf("%s: ParseToken = %v, %v，应通过", tc.name, claims, err)
+		}
+		if !tc.ok && (err == nil || claims != nil) {
+			t.Errorf("%s: ParseToken 应拒绝", tc.name)
+		}
+	}
+}
+
+// 算法与 kid 对应密钥不一致时拒绝：用 EdDSA 密钥的 kid 声明 RS256
+func TestParseTokenAlgMismatch(t *testing.T) {
+	rsaKey, oldKey, _ := testKeySet(t)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(tokenIssuer))
+	token.Header["kid"] = oldKey.KID
+	s, err := token.SignedString(rsaKey.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(s); err == nil {
+		t.Fatal("alg 与密钥不符的令牌通过了校验")
+	}
+}
@@ -1,6 +1,11 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"math/big"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // HashPassword 对密码进行哈希处理
 func HashPassword(password string) (string, error) {
@@ -13,3 +18,49 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// 随机密码字符集（去掉了易混淆的 0/O、1/l/I）
+const (
+	passwordLower  = "abcdefghijkmnpqrstuvwxyz"
+	passwordUpper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigits = "23456789"
+)
+
+// RandomPassword 生成随机密码，至少包含一个小写字母、大写字母和数字
+func RandomPassword(length int) (string, error) {
+	if length < 3 {
+		length = 3
+	}
+	sets := []string{passwordLower, passwordUpper, passwordDigits}
+	all := passwordLower + passwordUpper + passwordDigits
+
+	buf := make([]byte, length)
+	for i := range buf {
+		set := all
+		if i < len(sets) {
+			set = sets[i]
+		}
+		ch, err := randomChar(set)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = ch
+	}
+	// 打乱顺序，避免前几位的字符类型固定
+	for i := len(buf) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		buf[i], buf[j.Int64()] = buf[j.Int64()], buf[i]
+	}
+	return string(buf), nil
+}
+
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
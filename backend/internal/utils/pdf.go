@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// A4 页面尺寸与页边距（单位：pt）
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// PDFWriter 极简 PDF 生成器
+// 仅支持文本输出，使用 PDF 阅读器内置的 Adobe 中文字体 STSong-Light，无需嵌入字体文件
+type PDFWriter struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// NewPDFWriter 创建 PDF 生成器并开始第一页
+func NewPDFWriter() *PDFWriter {
+	w := &PDFWriter{}
+	w.newPage()
+	return w
+}
+
+func (w *PDFWriter) newPage() {
+	w.pages = append(w.pages, &bytes.Buffer{})
+	w.y = pdfPageHeight - pdfMargin
+}
+
+// Row 在当前行按给定横坐标输出多列文本，然后换行；空间不足时自动分页
+func (w *PDFWriter) Row(size float64, cells []string, xs []float64) {
+	lineHeight := size * 1.6
+	if w.y-lineHeight < pdfMargin {
+		w.newPage()
+	}
+	w.y -= lineHeight
+
+	page := w.pages[len(w.pages)-1]
+	for i, cell := range cells {
+		if i >= len(xs) || cell == "" {
+			continue
+		}
+		fmt.Fprintf(page, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, pdfMargin+xs[i], w.y, pdfHexUCS2(cell))
+	}
+}
+
+// Text 输出单行文本
+func (w *PDFWriter) Text(size float64, text string) {
+	w.Row(size, []string{text}, []float64{0})
+}
+
+// Gap 插入空白
+func (w *PDFWriter) Gap(height float64) {
+	w.y -= height
+}
+
+// Bytes 生成完整的 PDF 文件内容
+func (w *PDFWriter) Bytes() []byte {
+	var objects []string
+	// 1: Catalog, 2: Pages, 3: Type0 字体, 4: CIDFont, 5: 字体描述，之后每页两个对象（页面 + 内容流）
+	pageCount := len(w.pages)
+	kids := ""
+	for i := 0; i < pageCount; i++ {
+		kids += fmt.Sprintf("%d 0 R ", 6+i*2)
+	}
+
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pageCount),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] "+
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	)
+	for i, page := range w.pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+				"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 7+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfHexUCS2 将文本编码为 UCS-2 大端十六进制字符串（超出 BMP 的字符替换为 ?）
+func pdfHexUCS2(text string) string {
+	var buf bytes.Buffer
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		for _, u := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&buf, "%04X", u)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// 敏感数据（如两步验证密钥）的加密存储：AES-256-GCM，密文形如 enc:v1:<base64(nonce||ciphertext)>
+
+const sealedPrefix = "enc:v1:"
+
+// ErrSecretKeyNotSet 尚未设置加密密钥
+var ErrSecretKeyNotSet = errors.New("未设置数据加密密钥")
+
+// secretAEAD 当前使用的加密器，由 SetSecretKey 设置
+var secretAEAD cipher.AEAD
+
+// SetSecretKey 设置数据加密密钥（启动时调用）；任意长度的密钥经 SHA-256 得到 AES-256 密钥
+func SetSecretKey(key string) error {
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	secretAEAD = aead
+	return nil
+}
+
+// IsSealed 是否为 SealSecret 生成的密文
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// SealSecret 加密敏感数据
+func SealSecret(plain string) (string, error) {
+	if secretAEAD == nil {
+		return "", ErrSecretKeyNotSet
+	}
+	nonce := make([]byte, secretAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := secretAEAD.Seal(nonce, nonce, []byte(plain), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSecret 解密 SealSecret 生成的密文；不带密文前缀的值是加密存储之前写入的明文，原样返回
+func OpenSecret(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if secretAEAD == nil {
+		return "", ErrSecretKeyNotSet
+	}
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(data) < secretAEAD.NonceSize() {
+		return "", errors.New("密文格式无效")
+	}
+	nonce, ciphertext := data[:secretAEAD.NonceSize()], data[secretAEAD.NonceSize():]
+	plain, err := secretAEAD.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("解密失败（数据加密密钥不一致或密文被篡改）")
+	}
+	return string(plain), nil
+}
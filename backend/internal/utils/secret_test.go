@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSealSecret(t *testing.T) {
+	if err := SetSecretKey("test-encryption-key"); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("SealSecret = %q，不应包含明文", sealed)
+	}
+	if again, _ := SealSecret("JBSWY3DPEHPK3PXP"); again == sealed {
+		t.Fatal("两次加密的密文相同（nonce 未随机）")
+	}
+	if plain, err := OpenSecret(sealed); err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("OpenSecret = %q, %v", plain, err)
+	}
+
+	// 加密存储之前的明文原样返回
+	if plain, err := OpenSecret("JBSWY3DPEHPK3PXP"); err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("OpenSecret(明文) = %q, %v", plain, err)
+	}
+
+	// 密文被篡改或密钥不一致时解密失败
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := OpenSecret(tampered); err == nil {
+		t.Fatal("篡改后的密文解密成功")
+	}
+	if err := SetSecretKey("other-key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSecret(sealed); err == nil {
+		t.Fatal("用其他密钥解密成功")
+	}
+}
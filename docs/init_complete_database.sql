@@ -29,7 +29,9 @@ CREATE TABLE IF NOT EXISTS roles (
     updated_at DATETIME(3) NULL DEFAULT NULL,
     deleted_at DATETIME(3) NULL DEFAULT NULL,
     role_name VARCHAR(50) NOT NULL UNIQUE COMMENT '角色名称',
-    KEY idx_roles_deleted_at (deleted_at)
+    parent_id BIGINT UNSIGNED NULL COMMENT '继承的上级角色ID',
+    KEY idx_roles_deleted_at (deleted_at),
+    KEY idx_roles_parent_id (parent_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='角色表';
 
 -- 3. 权限表
@@ -41,6 +43,7 @@ CREATE TABLE IF NOT EXISTS permissions (
     name VARCHAR(100) NOT NULL COMMENT '权限名称',
     permission VARCHAR(100) NOT NULL UNIQUE COMMENT '权限标识',
     `group` VARCHAR(50) COMMENT '权限分组',
+    obsolete BOOLEAN DEFAULT FALSE COMMENT '已不被任何路由引用（启动时由路由表同步）',
     KEY idx_permissions_deleted_at (deleted_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='权限表';
 
@@ -58,6 +61,30 @@ CREATE TABLE IF NOT EXISTS role_permissions (
     FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='角色权限关联表';
 
+-- 4.1 角色权限规则表（通配符授权与显式拒绝；精确授权保存在 role_permissions）
+CREATE TABLE IF NOT EXISTS role_permission_rules (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    updated_at DATETIME(3) NULL DEFAULT NULL,
+    deleted_at DATETIME(3) NULL DEFAULT NULL,
+    role_id BIGINT UNSIGNED NOT NULL COMMENT '角色ID',
+    pattern VARCHAR(100) NOT NULL COMMENT '权限标识或通配符，如 admin:*',
+    effect VARCHAR(10) NOT NULL COMMENT 'allow（通配符授权）或 deny（拒绝）',
+    UNIQUE KEY idx_role_rule (role_id, pattern),
+    KEY idx_role_permission_rules_deleted_at (deleted_at),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='角色权限规则表';
+
+-- 4.2 已应用的内置角色默认权限（清单中的每一项只自动补一次）
+CREATE TABLE IF NOT EXISTS applied_role_defaults (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    role_name VARCHAR(50) NOT NULL COMMENT '角色名',
+    pattern VARCHAR(100) NOT NULL COMMENT '权限标识或通配符',
+    effect VARCHAR(10) NOT NULL COMMENT 'allow 或 deny',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    UNIQUE KEY idx_applied_role_default (role_name, pattern, effect)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='已应用的角色默认权限表';
+
 -- 5. 用户表
 CREATE TABLE IF NOT EXISTS users (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
@@ -70,10 +97,120 @@ CREATE TABLE IF NOT EXISTS users (
     is_active BOOLEAN DEFAULT TRUE COMMENT '是否激活',
     user_id BIGINT UNSIGNED COMMENT '关联的实体ID（学生/教师/家长）',
     user_type VARCHAR(20) COMMENT '用户类型：student/teacher/admin/parent',
+    must_change_password BOOLEAN DEFAULT FALSE COMMENT '下次登录须修改密码',
+    token_version BIGINT UNSIGNED DEFAULT 0 COMMENT '令牌版本（改密、禁用、换角色时递增）',
+    totp_secret VARCHAR(255) COMMENT '两步验证（TOTP）密钥（加密存储）',
+    totp_enabled BOOLEAN DEFAULT FALSE COMMENT '是否已启用两步验证',
+    totp_last_step BIGINT DEFAULT 0 COMMENT '最近一次使用的验证码时间步（防止重放）',
+    totp_enroll_code VARCHAR(64) COMMENT '管理员签发的两步验证设置码哈希',
+    totp_enroll_expires_at DATETIME(3) NULL COMMENT '两步验证设置码过期时间',
+    auth_methods VARCHAR(100) COMMENT '认证方式顺序（逗号分隔，如 ldap,local），为空时按用户类型或默认顺序',
     KEY idx_users_deleted_at (deleted_at),
     FOREIGN KEY (role_id) REFERENCES roles(id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户表';
 
+-- 5.1 密码历史表（禁止重复使用最近用过的密码）
+CREATE TABLE IF NOT EXISTS password_histories (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    password VARCHAR(255) NOT NULL COMMENT '曾经使用过的密码哈希',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    KEY idx_password_histories_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='密码历史表';
+
+-- 5.2 登录会话表（每次登录一条，保存轮换中的刷新令牌哈希）
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    refresh_hash CHAR(64) NOT NULL COMMENT '当前刷新令牌的SHA-256哈希',
+    previous_hash CHAR(64) COMMENT '上一个刷新令牌哈希（用于发现令牌盗用）',
+    token_version BIGINT UNSIGNED COMMENT '创建会话时用户的令牌版本',
+    user_agent VARCHAR(255) COMMENT '登录设备（User-Agent）',
+    ip VARCHAR(45) COMMENT '登录IP',
+    last_seen_at DATETIME(3) NULL DEFAULT NULL COMMENT '最近使用时间',
+    expires_at DATETIME(3) NULL DEFAULT NULL COMMENT '刷新令牌过期时间',
+    revoked_at DATETIME(3) NULL DEFAULT NULL COMMENT '撤销时间（登出、改密等）',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    updated_at DATETIME(3) NULL DEFAULT NULL,
+    UNIQUE KEY idx_sessions_refresh_hash (refresh_hash),
+    KEY idx_sessions_previous_hash (previous_hash),
+    KEY idx_sessions_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='登录会话表';
+
+-- 5.3 登录审计表（记录每一次登录尝试）
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(50) COMMENT '提交的用户名',
+    user_id BIGINT UNSIGNED COMMENT '匹配到的用户ID，0表示用户不存在',
+    ip VARCHAR(45) COMMENT '来源IP',
+    user_agent VARCHAR(255) COMMENT 'User-Agent',
+    success BOOLEAN COMMENT '是否成功',
+    result VARCHAR(30) COMMENT '结果：success/bad_password/bad_second_factor/unknown_user/inactive/locked/throttled',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    KEY idx_login_attempts_username (username),
+    KEY idx_login_attempts_user_id (user_id),
+    KEY idx_login_attempts_ip (ip),
+    KEY idx_login_attempts_success (success),
+    KEY idx_login_attempts_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='登录审计表';
+
+-- 5.4 登录限制表（按用户名、IP 统计连续失败次数与锁定状态）
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key VARCHAR(100) NOT NULL PRIMARY KEY COMMENT 'user:<用户名> 或 ip:<IP>',
+    failures BIGINT COMMENT '当前窗口内的连续失败次数',
+    lock_count BIGINT COMMENT '已被锁定的次数（锁定时长随之翻倍）',
+    last_failed_at DATETIME(3) NULL DEFAULT NULL,
+    locked_until DATETIME(3) NULL DEFAULT NULL COMMENT '锁定截止时间',
+    updated_at DATETIME(3) NULL DEFAULT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='登录限制表';
+
+-- 5.5 两步验证备用码表（只保存哈希，使用后作废）
+CREATE TABLE IF NOT EXISTS backup_codes (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    code_hash CHAR(64) NOT NULL COMMENT '备用码的SHA-256哈希',
+    used_at DATETIME(3) NULL DEFAULT NULL COMMENT '使用时间',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    KEY idx_backup_codes_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='两步验证备用码表';
+
+-- 5.6 外部身份表（统一身份认证账号与本系统用户的关联）
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    provider VARCHAR(50) NOT NULL COMMENT '身份提供方标识',
+    subject VARCHAR(255) NOT NULL COMMENT '身份提供方的用户标识（sub）',
+    email VARCHAR(100) COMMENT '身份提供方返回的邮箱',
+    last_login_at DATETIME(3) NULL DEFAULT NULL COMMENT '最近一次统一身份认证登录时间',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    UNIQUE KEY idx_user_identities_provider_subject (provider, subject),
+    KEY idx_user_identities_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部身份表';
+
+-- 5.7 权限缓存失效记录表（多实例部署时广播角色权限变化，各实例轮询，保留 1 小时）
+CREATE TABLE IF NOT EXISTS permission_invalidations (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    role_id BIGINT UNSIGNED COMMENT '权限变化的角色ID，0 表示全部角色',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    KEY idx_permission_invalidations_role_id (role_id),
+    KEY idx_permission_invalidations_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='权限缓存失效记录表';
+
+-- 5.8 初始密码清单表（批量开通账号后加密暂存，下载一次或过期后删除）
+CREATE TABLE IF NOT EXISTS credential_sheets (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL COMMENT '下载令牌的SHA-256哈希',
+    data MEDIUMTEXT NOT NULL COMMENT '加密后的清单（含初始密码）',
+    expires_at DATETIME(3) NULL DEFAULT NULL COMMENT '过期时间',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    UNIQUE KEY idx_credential_sheets_token_hash (token_hash),
+    KEY idx_credential_sheets_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='初始密码清单表';
+
 -- 6. 教师表
 CREATE TABLE IF NOT EXISTS teachers (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
@@ -136,6 +273,35 @@ CREATE TABLE IF NOT EXISTS parents (
     FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='家长表';
 
+-- 9.1 学期表（选课时间窗口与加退选截止时间）
+CREATE TABLE IF NOT EXISTS semesters (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    updated_at DATETIME(3) NULL DEFAULT NULL,
+    deleted_at DATETIME(3) NULL DEFAULT NULL,
+    name VARCHAR(50) NOT NULL UNIQUE COMMENT '学期名称（如 2025-Fall）',
+    start_date DATE COMMENT '学期开始日期',
+    end_date DATE COMMENT '学期结束日期',
+    enroll_start DATETIME(3) COMMENT '选课开始时间',
+    enroll_end DATETIME(3) COMMENT '选课结束时间',
+    add_drop_deadline DATETIME(3) COMMENT '加退选截止时间',
+    is_current BOOLEAN DEFAULT FALSE COMMENT '是否为当前学期',
+    KEY idx_semesters_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='学期表';
+
+-- 9.2 学生-监护人关联表（一个家长可监护多个学生）
+-- 说明：parents.student_id 表示主要关联学生，由 trg_parent_guardian_* 触发器同步到本表
+CREATE TABLE IF NOT EXISTS student_guardians (
+    student_id BIGINT UNSIGNED NOT NULL COMMENT '学生ID',
+    parent_id BIGINT UNSIGNED NOT NULL COMMENT '家长ID',
+    relation VARCHAR(20) COMMENT '关系（父亲/母亲等）',
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    PRIMARY KEY (student_id, parent_id),
+    KEY idx_student_guardians_parent_id (parent_id),
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES parents(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='学生-监护人关联表';
+
 -- 10. 课程表
 CREATE TABLE IF NOT EXISTS courses (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
@@ -147,7 +313,9 @@ CREATE TABLE IF NOT EXISTS courses (
     credits DECIMAL(3,1) COMMENT '学分',
     capacity INT DEFAULT 50 COMMENT '课程最大容量',
     enrolled_count INT DEFAULT 0 COMMENT '当前已选人数',
+    semester_id BIGINT UNSIGNED DEFAULT 0 COMMENT '开课学期ID（0 表示不限学期）',
     KEY idx_courses_deleted_at (deleted_at),
+    KEY idx_courses_semester_id (semester_id),
     FOREIGN KEY (teacher_id) REFERENCES teachers(id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='课程表';
 
@@ -159,8 +327,10 @@ CREATE TABLE IF NOT EXISTS enrollments (
     deleted_at DATETIME(3) NULL DEFAULT NULL,
     student_id BIGINT UNSIGNED NOT NULL COMMENT '学生ID',
     course_id BIGINT UNSIGNED NOT NULL COMMENT '课程ID',
+    semester_id BIGINT UNSIGNED DEFAULT 0 COMMENT '所属学期ID（由触发器根据课程填充）',
     UNIQUE KEY idx_student_course (student_id, course_id),
     KEY idx_enrollments_deleted_at (deleted_at),
+    KEY idx_enrollments_semester_id (semester_id),
     FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
     FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='选课表';
@@ -238,7 +408,9 @@ CREATE TABLE IF NOT EXISTS schedules (
     end_time VARCHAR(20) COMMENT '结束时间',
     location VARCHAR(100) COMMENT '上课地点',
     semester VARCHAR(50) COMMENT '学期',
+    semester_id BIGINT UNSIGNED DEFAULT 0 COMMENT '学期ID',
     KEY idx_schedules_deleted_at (deleted_at),
+    KEY idx_schedules_semester_id (semester_id),
     KEY idx_schedules_course_id (course_id),
     KEY idx_schedules_class_id (class_id),
     KEY idx_schedules_teacher_id (teacher_id),
@@ -280,6 +452,64 @@ CREATE TABLE IF NOT EXISTS grade_audit_logs (
     KEY idx_updated_at (updated_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='成绩修改审计日志表';
 
+-- 18.1 课程候补表
+-- 功能：课程已满时学生按先到先得排队，有名额空出时由后端按 position 顺序自动递补
+CREATE TABLE IF NOT EXISTS course_waitlists (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    updated_at DATETIME(3) NULL DEFAULT NULL,
+    deleted_at DATETIME(3) NULL DEFAULT NULL,
+    course_id BIGINT UNSIGNED NOT NULL COMMENT '课程ID',
+    student_id BIGINT UNSIGNED NOT NULL COMMENT '学生ID',
+    position INT NOT NULL COMMENT '候补顺序（越小越靠前）',
+    status VARCHAR(20) DEFAULT 'waiting' COMMENT '状态: waiting/enrolled/skipped/cancelled',
+    remark VARCHAR(255) COMMENT '转正/跳过原因',
+    KEY idx_waitlist_course_pos (course_id, position),
+    KEY idx_course_waitlists_student_id (student_id),
+    KEY idx_course_waitlists_status (status),
+    KEY idx_course_waitlists_deleted_at (deleted_at),
+    FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='课程候补表';
+
+-- 18.2 学业预警规则表
+-- 功能：规则类型固定（failing_score/absence_streak/gpa_drop/discipline），阈值、统计范围、级别可由学校调整
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    updated_at DATETIME(3) NULL DEFAULT NULL,
+    deleted_at DATETIME(3) NULL DEFAULT NULL,
+    code VARCHAR(50) NOT NULL UNIQUE COMMENT '规则类型',
+    name VARCHAR(100) COMMENT '规则名称',
+    threshold DOUBLE COMMENT '阈值（含义随规则类型而定）',
+    window_days BIGINT COMMENT '统计时间范围（天），0 表示不限',
+    severity VARCHAR(20) DEFAULT 'warning' COMMENT '级别: info/warning/critical',
+    enabled BOOLEAN DEFAULT TRUE COMMENT '是否启用',
+    KEY idx_alert_rules_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='学业预警规则表';
+
+-- 18.3 学业预警记录表
+-- 功能：规则命中后生成，指派给班主任跟进；dedup_key 保证同一问题只预警一次
+CREATE TABLE IF NOT EXISTS academic_alerts (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL DEFAULT NULL,
+    updated_at DATETIME(3) NULL DEFAULT NULL,
+    deleted_at DATETIME(3) NULL DEFAULT NULL,
+    student_id BIGINT UNSIGNED NOT NULL COMMENT '学生ID',
+    rule_code VARCHAR(50) COMMENT '规则类型',
+    severity VARCHAR(20) COMMENT '级别',
+    message VARCHAR(500) COMMENT '预警内容',
+    teacher_id BIGINT UNSIGNED COMMENT '负责跟进的班主任ID',
+    status VARCHAR(20) DEFAULT 'open' COMMENT '状态: open/acknowledged/resolved',
+    dedup_key VARCHAR(191) NOT NULL UNIQUE COMMENT '去重键',
+    KEY idx_academic_alerts_student_id (student_id),
+    KEY idx_academic_alerts_rule_code (rule_code),
+    KEY idx_academic_alerts_teacher_id (teacher_id),
+    KEY idx_academic_alerts_status (status),
+    KEY idx_academic_alerts_deleted_at (deleted_at),
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='学业预警记录表';
+
 -- ============================================
 -- 第四部分：创建触发器
 -- ============================================
@@ -306,6 +536,119 @@ END //
 
 DELIMITER ;
 
+-- 19.1 创建选课人数维护触发器
+-- 功能：courses.enrolled_count 随 enrollments 的插入、删除、软删除（deleted_at）自动增减
+-- 亮点：存储过程、通用表管理、直接 SQL 等所有写入路径都会在同一事务内同步计数
+
+DROP TRIGGER IF EXISTS trg_enrollment_count_insert;
+DROP TRIGGER IF EXISTS trg_enrollment_count_update;
+DROP TRIGGER IF EXISTS trg_enrollment_count_delete;
+
+DELIMITER //
+
+CREATE TRIGGER trg_enrollment_count_insert
+AFTER INSERT ON enrollments
+FOR EACH ROW
+BEGIN
+    IF NEW.deleted_at IS NULL THEN
+        UPDATE courses SET enrolled_count = enrolled_count + 1 WHERE id = NEW.course_id;
+    END IF;
+END //
+
+CREATE TRIGGER trg_enrollment_count_update
+AFTER UPDATE ON enrollments
+FOR EACH ROW
+BEGIN
+    -- 原记录有效，且被软删除或换了课程：原课程人数 -1
+    IF OLD.deleted_at IS NULL AND (NEW.deleted_at IS NOT NULL OR NEW.course_id <> OLD.course_id) THEN
+        UPDATE courses SET enrolled_count = GREATEST(enrolled_count - 1, 0) WHERE id = OLD.course_id;
+    END IF;
+    -- 新记录有效，且是从软删除恢复或换了课程：新课程人数 +1
+    IF NEW.deleted_at IS NULL AND (OLD.deleted_at IS NOT NULL OR NEW.course_id <> OLD.course_id) THEN
+        UPDATE courses SET enrolled_count = enrolled_count + 1 WHERE id = NEW.course_id;
+    END IF;
+END //
+
+CREATE TRIGGER trg_enrollment_count_delete
+AFTER DELETE ON enrollments
+FOR EACH ROW
+BEGIN
+    IF OLD.deleted_at IS NULL THEN
+        UPDATE courses SET enrolled_count = GREATEST(enrolled_count - 1, 0) WHERE id = OLD.course_id;
+    END IF;
+END //
+
+DELIMITER ;
+
+-- 19.2 创建选课学期填充触发器
+-- 功能：选课记录的 semester_id 始终与课程的开课学期一致
+
+DROP TRIGGER IF EXISTS trg_enrollment_semester_insert;
+DROP TRIGGER IF EXISTS trg_enrollment_semester_update;
+
+DELIMITER //
+
+CREATE TRIGGER trg_enrollment_semester_insert
+BEFORE INSERT ON enrollments
+FOR EACH ROW
+BEGIN
+    SET NEW.semester_id = COALESCE((SELECT semester_id FROM courses WHERE id = NEW.course_id), 0);
+END //
+
+CREATE TRIGGER trg_enrollment_semester_update
+BEFORE UPDATE ON enrollments
+FOR EACH ROW
+BEGIN
+    IF NEW.course_id <> OLD.course_id THEN
+        SET NEW.semester_id = COALESCE((SELECT semester_id FROM courses WHERE id = NEW.course_id), 0);
+    END IF;
+END //
+
+DELIMITER ;
+
+-- 19.3 家长监护关系同步触发器
+-- 功能：parents.student_id（主要关联学生）变化时同步 student_guardians，家长删除时移除其监护关系
+DROP TRIGGER IF EXISTS trg_parent_guardian_insert;
+DROP TRIGGER IF EXISTS trg_parent_guardian_update;
+DROP TRIGGER IF EXISTS trg_parent_guardian_delete;
+
+DELIMITER //
+
+CREATE TRIGGER trg_parent_guardian_insert
+AFTER INSERT ON parents
+FOR EACH ROW
+BEGIN
+    IF NEW.student_id > 0 AND NEW.deleted_at IS NULL THEN
+        INSERT IGNORE INTO student_guardians (student_id, parent_id, relation, created_at)
+        VALUES (NEW.student_id, NEW.id, NEW.relation, NOW(3));
+    END IF;
+END //
+
+CREATE TRIGGER trg_parent_guardian_update
+AFTER UPDATE ON parents
+FOR EACH ROW
+BEGIN
+    IF NEW.student_id <> OLD.student_id THEN
+        DELETE FROM student_guardians WHERE student_id = OLD.student_id AND parent_id = OLD.id;
+    END IF;
+    IF NEW.student_id > 0 AND NEW.deleted_at IS NULL THEN
+        INSERT IGNORE INTO student_guardians (student_id, parent_id, relation, created_at)
+        VALUES (NEW.student_id, NEW.id, NEW.relation, NOW(3));
+    END IF;
+    IF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        DELETE FROM student_guardians WHERE parent_id = NEW.id;
+    END IF;
+END //
+
+CREATE TRIGGER trg_parent_guardian_delete
+AFTER DELETE ON parents
+FOR EACH ROW
+BEGIN
+    DELETE FROM student_guardians WHERE parent_id = OLD.id;
+END //
+
+DELIMITER ;
+
 -- ============================================
 -- 第五部分：创建存储过程
 -- ============================================
@@ -313,6 +656,7 @@ DELIMITER ;
 -- 20. 创建选课存储过程
 -- 功能：智能选课，检查先修课程、课程容量等
 -- 优势：事务安全、业务逻辑下沉数据库层
+-- 说明：已选人数由第四部分的 trg_enrollment_count_* 触发器维护，存储过程不再直接修改计数
 
 -- 先删除已存在的存储过程（如果存在）
 DROP PROCEDURE IF EXISTS sp_enroll_student;
@@ -322,7 +666,7 @@ DELIMITER //
 CREATE PROCEDURE sp_enroll_student(
     IN p_student_id BIGINT UNSIGNED,
     IN p_course_id BIGINT UNSIGNED,
-    OUT p_status INT,       -- 0: 成功, 1: 失败
+    OUT p_status INT,       -- 0: 成功, 1: 已选/其他失败, 2: 先修未满足, 3: 课程已满
     OUT p_message VARCHAR(255)
 )
 BEGIN
@@ -331,11 +675,26 @@ BEGIN
     DECLARE v_already_enrolled INT;
     DECLARE v_prereq_count INT;
     DECLARE v_prereq_met INT;
+    DECLARE v_deleted_id BIGINT UNSIGNED DEFAULT NULL;
+
+    -- 任何 SQL 异常（如并发插入触发唯一索引冲突）都回滚并返回失败
+    DECLARE EXIT HANDLER FOR SQLEXCEPTION
+    BEGIN
+        ROLLBACK;
+        SET p_status = 1;
+        SET p_message = '选课失败';
+    END;
 
     -- 开始事务
     START TRANSACTION;
 
-    -- 1. 检查是否已经选课
+    -- 1. 先锁定课程行，同一课程的选课请求在此串行化
+    SELECT capacity, enrolled_count INTO v_capacity, v_enrolled
+    FROM courses
+    WHERE id = p_course_id
+    FOR UPDATE;  -- 行锁，防止并发选课超额
+
+    -- 2. 检查是否已经选课
     SELECT COUNT(*) INTO v_already_enrolled 
     FROM enrollments 
     WHERE student_id = p_student_id AND course_id = p_course_id AND deleted_at IS NULL;
@@ -345,14 +704,14 @@ BEGIN
         SET p_message = '已经选过该课程';
         ROLLBACK;
     ELSE
-        -- 2. 检查先修课程要求
+        -- 3. 检查先修课程要求
         -- 统计该课程有多少先修课程
         SELECT COUNT(*) INTO v_prereq_count 
         FROM course_prerequisites 
         WHERE course_id = p_course_id;
 
         -- 统计学生已经完成且及格的先修课程数量（分数 >= 60）
-        SELECT COUNT(*) INTO v_prereq_met
+        SELECT COUNT(DISTINCT cp.prereq_id) INTO v_prereq_met
         FROM course_prerequisites cp
         JOIN enrollments e ON cp.prereq_id = e.course_id
         JOIN grades g ON e.id = g.enrollment_id
@@ -362,34 +721,31 @@ BEGIN
           AND e.deleted_at IS NULL; 
 
         IF v_prereq_met < v_prereq_count THEN
-            SET p_status = 1;
+            SET p_status = 2;
             SET p_message = '未完成先修课程要求';
             ROLLBACK;
+        ELSEIF v_enrolled >= v_capacity THEN
+            -- 4. 检查课程容量
+            SET p_status = 3;
+            SET p_message = '课程已满';
+            ROLLBACK;
         ELSE
-            -- 3. 检查并锁定课程容量
-            SELECT capacity, enrolled_count INTO v_capacity, v_enrolled
-            FROM courses
-            WHERE id = p_course_id
-            FOR UPDATE;  -- 行锁，防止并发选课超额
-
-            IF v_enrolled >= v_capacity THEN
-                SET p_status = 1;
-                SET p_message = '课程已满';
-                ROLLBACK;
+            -- 5. 执行选课操作（曾经退选的软删除记录直接恢复，避免唯一索引冲突）
+            SELECT id INTO v_deleted_id
+            FROM enrollments
+            WHERE student_id = p_student_id AND course_id = p_course_id AND deleted_at IS NOT NULL
+            LIMIT 1;
+
+            IF v_deleted_id IS NOT NULL THEN
+                UPDATE enrollments SET deleted_at = NULL, updated_at = NOW(3) WHERE id = v_deleted_id;
             ELSE
-                -- 4. 执行选课操作
                 INSERT INTO enrollments (created_at, updated_at, student_id, course_id)
                 VALUES (NOW(3), NOW(3), p_student_id, p_course_id);
-
-                -- 更新已选人数
-                UPDATE courses 
-                SET enrolled_count = enrolled_count + 1 
-                WHERE id = p_course_id;
-
-                SET p_status = 0;
-                SET p_message = '选课成功';
-                COMMIT;
             END IF;
+
+            SET p_status = 0;
+            SET p_message = '选课成功';
+            COMMIT;
         END IF;
     END IF;
 END //
@@ -445,11 +801,12 @@ SELECT
     c.class_name,
     u.username AS login_account,
     -- 聚合家长信息 (如果有多个家长，用逗号连接)
-    GROUP_CONCAT(CONCAT(p.relation, ':', p.name, '(', p.phone, ')') SEPARATOR '; ') AS parents_info
+    GROUP_CONCAT(CONCAT(COALESCE(NULLIF(sg.relation, ''), p.relation), ':', p.name, '(', p.phone, ')') SEPARATOR '; ') AS parents_info
 FROM students s
 LEFT JOIN classes c ON s.class_id = c.id
 LEFT JOIN users u ON s.user_id = u.id
-LEFT JOIN parents p ON s.id = p.student_id AND p.deleted_at IS NULL
+LEFT JOIN student_guardians sg ON sg.student_id = s.id
+LEFT JOIN parents p ON p.id = sg.parent_id AND p.deleted_at IS NULL
 WHERE s.deleted_at IS NULL
 GROUP BY s.id, s.student_id, s.name, s.gender, s.email, s.phone, s.address, c.class_name, u.username;
 
@@ -477,6 +834,11 @@ ON DUPLICATE KEY UPDATE updated_at = NOW(3);
 -- CALL sp_enroll_student(1, 2, @status, @message);
 -- SELECT @status AS status, @message AS message;
 
+-- 校验选课人数是否与实际选课记录一致（结果应为空）：
+-- SELECT c.id, c.enrolled_count, COUNT(e.id) AS actual
+-- FROM courses c LEFT JOIN enrollments e ON e.course_id = c.id AND e.deleted_at IS NULL
+-- GROUP BY c.id, c.enrolled_count HAVING c.enrolled_count <> actual;
+
 -- 查询班级成绩统计：
 -- SELECT * FROM vw_class_performance WHERE class_name = '计算机1班';
 
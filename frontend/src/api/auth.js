@@ -14,10 +14,11 @@ export const login = (data) => {
 /**
  * 用户登出
  */
-export const logout = () => {
+export const logout = (refreshToken) => {
     return request({
         url: '/api/v1/auth/logout',
-        method: 'post'
+        method: 'post',
+        data: refreshToken ? { refresh_token: refreshToken } : undefined
     })
 }
 
@@ -41,3 +42,72 @@ export const updatePassword = (data) => {
         data
     })
 }
+
+/**
+ * 两步验证：提交验证码或备用码完成登录（使用登录返回的临时令牌）
+ */
+export const verifyTwoFactor = (partialToken, code) => {
+    return request({
+        url: '/api/v1/auth/2fa/verify',
+        method: 'post',
+        headers: { Authorization: `Bearer ${partialToken}` },
+        data: { code }
+    })
+}
+
+/**
+ * 两步验证：生成密钥（partialToken 为空时使用当前登录令牌；使用临时令牌时须提交管理员签发的设置码）
+ */
+export const setupTwoFactor = (partialToken, enrollmentCode) => {
+    return request({
+        url: '/api/v1/auth/2fa/setup',
+        method: 'post',
+        headers: partialToken ? { Authorization: `Bearer ${partialToken}` } : undefined,
+        data: partialToken ? { enrollment_code: enrollmentCode } : undefined
+    })
+}
+
+/**
+ * 两步验证：提交验证码启用，返回备用码（使用临时令牌时须同时提交设置码）
+ */
+export const enableTwoFactor = (partialToken, code, enrollmentCode) => {
+    return request({
+        url: '/api/v1/auth/2fa/enable',
+        method: 'post',
+        headers: partialToken ? { Authorization: `Bearer ${partialToken}` } : undefined,
+        data: partialToken ? { code, enrollment_code: enrollmentCode } : { code }
+    })
+}
+
+/**
+ * 统一身份认证：是否启用及按钮名称
+ */
+export const getOidcConfig = () => {
+    return request({
+        url: '/api/v1/auth/oidc/config',
+        method: 'get'
+    })
+}
+
+/**
+ * 统一身份认证：获取身份提供方登录地址
+ */
+export const getOidcAuthorizeUrl = () => {
+    return request({
+        url: '/api/v1/auth/oidc/authorize',
+        method: 'get',
+        withCredentials: true // 登录状态保存在 Cookie 中
+    })
+}
+
+/**
+ * 统一身份认证：提交回调中的授权码完成登录
+ */
+export const oidcCallback = (data) => {
+    return request({
+        url: '/api/v1/auth/oidc/callback',
+        method: 'post',
+        withCredentials: true // 登录状态保存在 Cookie 中,
+        data
+    })
+}
@@ -5,6 +5,7 @@ import router from '@/router'
 export const useUserStore = defineStore('user', () => {
     // 状态
     const token = ref(localStorage.getItem('token') || '')
+    const refreshToken = ref(localStorage.getItem('refreshToken') || '')
     const userInfo = ref(JSON.parse(localStorage.getItem('userInfo') || 'null'))
     const permissions = ref(JSON.parse(localStorage.getItem('permissions') || '[]'))
 
@@ -14,6 +15,12 @@ export const useUserStore = defineStore('user', () => {
         localStorage.setItem('token', newToken)
     }
 
+    // 设置刷新令牌（每次刷新后都会轮换）
+    const setRefreshToken = (newToken) => {
+        refreshToken.value = newToken || ''
+        localStorage.setItem('refreshToken', refreshToken.value)
+    }
+
     // 设置用户信息
     const setUserInfo = (info) => {
         userInfo.value = info
@@ -29,6 +36,7 @@ export const useUserStore = defineStore('user', () => {
     // 登录
     const login = (loginData) => {
         setToken(loginData.token)
+        setRefreshToken(loginData.refresh_token)
         setUserInfo(loginData.user)
         setPermissions(loginData.permissions || [])
     }
@@ -36,9 +44,11 @@ export const useUserStore = defineStore('user', () => {
     // 登出
     const logout = () => {
         token.value = ''
+        refreshToken.value = ''
         userInfo.value = null
         permissions.value = []
         localStorage.removeItem('token')
+        localStorage.removeItem('refreshToken')
         localStorage.removeItem('userInfo')
         localStorage.removeItem('permissions')
         router.push('/login')
@@ -51,9 +61,11 @@ export const useUserStore = defineStore('user', () => {
 
     return {
         token,
+        refreshToken,
         userInfo,
         permissions,
         setToken,
+        setRefreshToken,
         setUserInfo,
         setPermissions,
         login,
@@ -9,6 +9,24 @@ const request = axios.create({
     timeout: 15000
 })
 
+// 刷新访问令牌；并发的多个 401 请求共用同一次刷新
+let refreshing = null
+const refreshAccessToken = () => {
+    if (!refreshing) {
+        const userStore = useUserStore()
+        refreshing = axios.post(`${request.defaults.baseURL}/api/v1/auth/refresh`, {
+            refresh_token: userStore.refreshToken
+        }).then(({ data }) => {
+            userStore.setToken(data.data.token)
+            userStore.setRefreshToken(data.data.refresh_token)
+            return data.data.token
+        }).finally(() => {
+            refreshing = null
+        })
+    }
+    return refreshing
+}
+
 // 请求拦截器
 request.interceptors.request.use(
     config => {
@@ -37,7 +55,21 @@ request.interceptors.response.use(
 
         return res
     },
-    error => {
+    async error => {
+        // 访问令牌过期时用刷新令牌换取新令牌，并重试原请求一次
+        const original = error.config
+        if (error.response?.status === 401 && original && !original._retried &&
+            !original.url.includes('/auth/') && useUserStore().refreshToken) {
+            original._retried = true
+            try {
+                const token = await refreshAccessToken()
+                original.headers['Authorization'] = `Bearer ${token}`
+                return request(original)
+            } catch {
+                // 刷新失败，按未授权处理
+            }
+        }
+
         console.error('响应错误:', error)
 
         if (error.response) {